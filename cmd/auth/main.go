@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -16,13 +18,18 @@ import (
 	"instant/internal/email"
 	kafkapkg "instant/internal/kafka"
 	"instant/internal/logger"
+	"instant/internal/metrics"
+	"instant/internal/migrate"
 	"instant/internal/session"
+	"instant/internal/tracing"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/joho/godotenv/autoload"
 )
 
 func main() {
+	logger.SetDefault(logger.ForService("auth-service"))
+
 	// Load configuration from environment
 	port := getEnv("AUTH_SERVICE_PORT", "8081")
 	host := getEnv("AUTH_SERVICE_HOST", "localhost")
@@ -38,17 +45,37 @@ func main() {
 	log.Printf("Consul: %s", consulAddr)
 	log.Printf("Redis: %s", redisAddr)
 
+	// Tracing: OTEL_TRACES_EXPORTER=otlp|stdout|none
+	shutdownTracing, err := tracing.Setup(context.Background(), "auth-service")
+	if err != nil {
+		log.Fatalf("tracing config error: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
 	// Initialize database
 	db := database.New()
 	log.Println("Connected to database")
 
+	// Apply pending schema migrations when DB_AUTO_MIGRATE=true
+	if err := migrate.AutoMigrate(context.Background(), db.DB()); err != nil {
+		log.Fatalf("Failed to run database migrations: %v", err)
+	}
+
 	// Initialize Redis for verification codes and sessions
+	sessionConfig, err := session.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load session config: %v", err)
+	}
+	cookies, err := session.LoadCookieSigner()
+	if err != nil {
+		log.Fatalf("Invalid session secret: %v", err)
+	}
 	store := session.NewRedisStore(redisAddr, redisPassword, redisDB)
-	sessionMgr := session.NewManager(store)
+	sessionMgr := session.NewManager(store, sessionConfig)
 	log.Println("Connected to Redis")
 
 	// Initialize logger
-	lgr := logger.New()
+	lgr := slog.Default()
 
 	// Initialize email sender
 	emailConfig := email.NewConfig()
@@ -83,26 +110,39 @@ func main() {
 		authService = auth.NewService(db, store, emailSender)
 	}
 
-	authHandler := auth.NewHandler(authService, sessionMgr)
+	authHandler := auth.NewHandler(authService, sessionMgr, cookies)
 
 	// Setup Gin router
 	r := gin.Default()
+	r.Use(logger.Middleware())
+	r.Use(tracing.Middleware())
+	metrics.Instrument(r) // request metrics and /metrics
 
 	// Public auth endpoints
 	r.POST("/request-code", authHandler.RequestCode)
 	r.POST("/verify-code", authHandler.VerifyCode)
 	r.POST("/logout", authHandler.Logout)
 	r.GET("/health", authHandler.Health)
+	r.GET("/csrf", sessionAuthMiddleware(sessionMgr, cookies), authHandler.CSRFToken)
 
 	// Protected user management endpoints (require session)
 	users := r.Group("/users")
-	users.Use(sessionAuthMiddleware(sessionMgr))
+	users.Use(sessionAuthMiddleware(sessionMgr, cookies))
 	{
 		users.PATCH("/:id", authHandler.UpdateUser)
 		users.GET("/:id/request-delete-code", authHandler.RequestDeleteCode)
 		users.POST("/:id/delete", authHandler.DeleteUser)
 	}
 
+	// Session management for the signed-in user
+	sessions := r.Group("/sessions")
+	sessions.Use(sessionAuthMiddleware(sessionMgr, cookies))
+	{
+		sessions.GET("", authHandler.ListSessions)
+		sessions.DELETE("/:id", authHandler.RevokeSession)
+		sessions.POST("/revoke-all", authHandler.RevokeAllSessions)
+	}
+
 	// Initialize Consul client
 	consulClient, err := consul.NewClientWithToken(consulAddr, consulToken)
 	if err != nil {
@@ -208,21 +248,27 @@ func mustAtoi(s string) int {
 }
 
 // sessionAuthMiddleware validates session and injects user context
-func sessionAuthMiddleware(sessionMgr session.Manager) gin.HandlerFunc {
+func sessionAuthMiddleware(sessionMgr session.Manager, cookies *session.CookieSigner) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get session ID from cookie
-		sessionID, err := c.Cookie("session_id")
-		if err != nil {
+		// Get session ID from the signed cookie
+		sessionID, err := cookies.SessionID(c.Request)
+		if errors.Is(err, http.ErrNoCookie) {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 				"error": "unauthorized: no session cookie",
 			})
 			return
 		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "unauthorized: invalid session",
+			})
+			return
+		}
 
 		// Validate and get session
 		sess, err := sessionMgr.Get(c.Request.Context(), sessionID)
 		if err != nil {
-			log.Printf("Invalid session %s: %v", sessionID, err)
+			logger.FromContext(c.Request.Context()).Warn("Invalid session", "session_id", sessionID, "error", err)
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 				"error": "unauthorized: invalid session",
 			})
@@ -239,7 +285,9 @@ func sessionAuthMiddleware(sessionMgr session.Manager) gin.HandlerFunc {
 
 		// Inject user context into Gin context
 		c.Set("user_id", sess.UserID)
+		c.Request = c.Request.WithContext(logger.WithUserID(c.Request.Context(), sess.UserID))
 		c.Set("email", sess.Email)
+		c.Set("session_id", sess.ID)
 
 		c.Next()
 	}
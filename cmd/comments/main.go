@@ -13,9 +13,15 @@ import (
     "instant/internal/comments"
     "instant/internal/consul"
     "instant/internal/database"
+    "instant/internal/identity"
+    "instant/internal/logger"
+    "instant/internal/migrate"
+    "instant/internal/tracing"
 )
 
 func main() {
+    logger.SetDefault(logger.ForService("comments-service"))
+
     port := getEnv("COMMENTS_SERVICE_PORT", "8085")
     host := getEnv("COMMENTS_SERVICE_HOST", "comments-service")
     consulAddr := getEnv("CONSUL_HTTP_ADDR", "localhost:8500")
@@ -24,11 +30,27 @@ func main() {
     log.Println("Starting Comments Service...")
     log.Printf("Host: %s Port: %s Consul: %s", host, port, consulAddr)
 
+    // Tracing: OTEL_TRACES_EXPORTER=otlp|stdout|none
+    shutdownTracing, err := tracing.Setup(context.Background(), "comments-service")
+    if err != nil {
+        log.Fatalf("tracing config error: %v", err)
+    }
+    defer shutdownTracing(context.Background())
+
     db := database.New()
     defer db.Close()
 
+    // Apply pending schema migrations when DB_AUTO_MIGRATE=true
+    if err := migrate.AutoMigrate(context.Background(), db.DB()); err != nil {
+        log.Fatalf("migration error: %v", err)
+    }
+
     svc := comments.NewService(db)
-    router := comments.SetupRouter(svc)
+    signer, err := identity.LoadSigner()
+    if err != nil {
+        log.Fatalf("identity config error: %v", err)
+    }
+    router := comments.SetupRouter(svc, signer, db.WriteTracker())
 
     cClient, err := consul.NewClientWithToken(consulAddr, consulToken)
     if err != nil {
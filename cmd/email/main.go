@@ -14,6 +14,8 @@ import (
 	"instant/internal/consul"
 	"instant/internal/email"
 	"instant/internal/logger"
+	"instant/internal/metrics"
+	"instant/internal/tracing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
@@ -22,7 +24,8 @@ import (
 
 func main() {
 	// Initialize logger
-	lgr := logger.New()
+	lgr := logger.ForService("email-service")
+	logger.SetDefault(lgr)
 	lgr.Info("Starting Email Service...")
 
 	// Load configuration from environment
@@ -48,12 +51,21 @@ func main() {
 		"kafka", kafkaBrokers,
 		"topic", kafkaTopic)
 
+	// Tracing: OTEL_TRACES_EXPORTER=otlp|stdout|none
+	shutdownTracing, err := tracing.Setup(context.Background(), "email-service")
+	if err != nil {
+		lgr.Error("Invalid tracing configuration", "error", err)
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
+
 	// Initialize Redis for idempotency store
 	redisClient := redis.NewClient(&redis.Options{
 		Addr:     redisAddr,
 		Password: redisPassword,
 		DB:       redisDB,
 	})
+	tracing.InstrumentRedis(redisClient)
 
 	// Test Redis connection
 	ctx := context.Background()
@@ -101,6 +113,9 @@ func main() {
 
 	// Setup HTTP server for health checks
 	r := gin.Default()
+	r.Use(logger.Middleware())
+	r.Use(tracing.Middleware())
+	metrics.Instrument(r) // request metrics and /metrics
 
 	handler := email.NewHandler(redisClient, idempotencyStore, lgr)
 	r.GET("/health", handler.HealthCheck)
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"instant/internal/consul"
+	"instant/internal/database"
+	"instant/internal/feed"
+	"instant/internal/identity"
+	"instant/internal/logger"
+	"instant/internal/migrate"
+	"instant/internal/tracing"
+
+	_ "github.com/joho/godotenv/autoload"
+	"github.com/redis/go-redis/v9"
+)
+
+func main() {
+	logger.SetDefault(logger.ForService("feed-service"))
+
+	port := getEnv("FEED_SERVICE_PORT", "8088")
+	host := getEnv("FEED_SERVICE_HOST", "feed-service")
+	consulAddr := getEnv("CONSUL_HTTP_ADDR", "localhost:8500")
+	consulToken := getEnv("CONSUL_HTTP_TOKEN", "")
+	redisAddr := getEnv("REDIS_ADDR", "localhost:6379")
+	redisPassword := getEnv("REDIS_PASSWORD", "")
+
+	// Timeline configuration
+	timelineMax := mustAtoi(getEnv("FEED_TIMELINE_MAX", "800"))
+	celebrityThreshold := mustAtoi(getEnv("FEED_CELEBRITY_THRESHOLD", "10000"))
+
+	// Kafka configuration
+	kafkaBrokers := getEnv("KAFKA_BROKERS", "")
+	enableKafka := getEnv("ENABLE_KAFKA", "true")
+	kafkaTopic := getEnv("KAFKA_TOPIC_POST_EVENTS", "post-created")
+	kafkaFollowTopic := getEnv("KAFKA_TOPIC_FOLLOW_EVENTS", "follow-changed")
+	kafkaConsumerGroup := getEnv("KAFKA_FEED_CONSUMER_GROUP", "feed-service-group")
+
+	log.Println("Starting Feed Service...")
+	log.Printf("Host: %s Port: %s Consul: %s", host, port, consulAddr)
+
+	// Tracing: OTEL_TRACES_EXPORTER=otlp|stdout|none
+	shutdownTracing, err := tracing.Setup(context.Background(), "feed-service")
+	if err != nil {
+		log.Fatalf("tracing config error: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	db := database.New()
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Printf("db close error: %v", err)
+		}
+	}()
+
+	// Apply pending schema migrations when DB_AUTO_MIGRATE=true
+	if err := migrate.AutoMigrate(context.Background(), db.DB()); err != nil {
+		log.Fatalf("migration error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Redis timelines (optional): without them every feed is read from the database
+	var svc feed.Service
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     redisAddr,
+		Password: redisPassword,
+		DB:       0,
+	})
+	tracing.InstrumentRedis(rdb)
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		log.Printf("Warning: Redis connection failed: %v. Timelines disabled.", err)
+		svc = feed.NewService(db)
+	} else {
+		log.Println("Redis connected for feed timelines")
+		defer rdb.Close()
+		store := feed.NewTimelineStore(rdb, timelineMax, feed.DefaultTimelineTTL)
+		svc = feed.NewServiceWithTimelines(db, store, int64(celebrityThreshold))
+
+		// Fan out post-created events from posts-service and rebuild
+		// timelines on follow-changed events from follow-service
+		if kafkaBrokers != "" && enableKafka == "true" {
+			consumer, err := feed.NewConsumer(&feed.ConsumerConfig{
+				Brokers:       kafkaBrokers,
+				Topic:         kafkaTopic,
+				FollowTopic:   kafkaFollowTopic,
+				ConsumerGroup: kafkaConsumerGroup,
+				MaxRetries:    3,
+			}, svc, slog.Default())
+			if err != nil {
+				log.Fatalf("kafka consumer error: %v", err)
+			}
+			defer consumer.Close()
+
+			go func() {
+				if err := consumer.Start(ctx); err != nil {
+					log.Printf("Consumer error: %v", err)
+				}
+			}()
+		} else {
+			log.Println("Kafka disabled, timelines are rebuilt from the database on read")
+		}
+	}
+
+	signer, err := identity.LoadSigner()
+	if err != nil {
+		log.Fatalf("identity config error: %v", err)
+	}
+	router := feed.SetupRouter(svc, signer)
+
+	// Consul
+	consulClient, err := consul.NewClientWithToken(consulAddr, consulToken)
+	if err != nil {
+		log.Fatalf("consul client error: %v", err)
+	}
+	serviceID := fmt.Sprintf("feed-service-%s", host)
+	_ = consulClient.Deregister(serviceID)
+
+	if err := consulClient.Register(&consul.ServiceConfig{
+		ID:      serviceID,
+		Name:    "feed-service",
+		Address: host,
+		Port:    mustAtoi(port),
+		Tags:    []string{"feed", "social"},
+		Check: &consul.HealthCheck{
+			HTTP:     fmt.Sprintf("http://%s:%s/health", host, port),
+			Interval: "10s",
+			Timeout:  "3s",
+		},
+	}); err != nil {
+		log.Fatalf("consul register error: %v", err)
+	}
+	log.Printf("Registered in Consul as %s", serviceID)
+
+	// HTTP server
+	srv := &http.Server{
+		Addr:         ":" + port,
+		Handler:      router,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+
+	go func() {
+		log.Printf("Feed Service listening on :%s", port)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("listen error: %v", err)
+		}
+	}()
+
+	// Graceful shutdown
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	log.Println("Shutting down Feed Service...")
+
+	if err := consulClient.Deregister(serviceID); err != nil {
+		log.Printf("Consul deregister error: %v", err)
+	}
+
+	// Stop consuming before the server goes away
+	cancel()
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shutdownCancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Fatalf("forced shutdown: %v", err)
+	}
+	log.Println("Feed Service stopped")
+}
+
+func getEnv(k, def string) string {
+	if v := os.Getenv(k); v != "" {
+		return v
+	}
+	return def
+}
+
+func mustAtoi(s string) int {
+	var n int
+	if _, err := fmt.Sscanf(s, "%d", &n); err != nil {
+		panic("invalid int: " + s)
+	}
+	return n
+}
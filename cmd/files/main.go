@@ -12,12 +12,17 @@ import (
 
 	"instant/internal/consul"
 	"instant/internal/files"
+	"instant/internal/identity"
+	"instant/internal/logger"
 	"instant/internal/storage"
+	"instant/internal/tracing"
 
 	_ "github.com/joho/godotenv/autoload"
 )
 
 func main() {
+	logger.SetDefault(logger.ForService("files-service"))
+
 	// Load configuration from environment
 	port := getEnv("FILES_SERVICE_PORT", "8084")
 	host := getEnv("FILES_SERVICE_HOST", "files-service")
@@ -29,6 +34,13 @@ func main() {
 	log.Printf("Host: %s", host)
 	log.Printf("Consul: %s", consulAddr)
 
+	// Tracing: OTEL_TRACES_EXPORTER=otlp|stdout|none
+	shutdownTracing, err := tracing.Setup(context.Background(), "files-service")
+	if err != nil {
+		log.Fatalf("tracing config error: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
 	// Initialize storage service (MinIO/S3)
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -42,8 +54,14 @@ func main() {
 	// Initialize files service
 	filesService := files.NewService(storageService)
 
+	// Identity headers are signed by the gateway
+	signer, err := identity.LoadSigner()
+	if err != nil {
+		log.Fatalf("Failed to load identity config: %v", err)
+	}
+
 	// Setup router
-	server := files.NewServer(filesService)
+	server := files.NewServer(filesService, signer)
 	router := server.RegisterRoutes()
 
 	// Initialize Consul client
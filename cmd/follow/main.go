@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -13,9 +14,16 @@ import (
 	"instant/internal/consul"
 	"instant/internal/database"
 	"instant/internal/follow"
+	"instant/internal/identity"
+	kafkapkg "instant/internal/kafka"
+	"instant/internal/logger"
+	"instant/internal/migrate"
+	"instant/internal/tracing"
 )
 
 func main() {
+	logger.SetDefault(logger.ForService("follow-service"))
+
 	port := getEnv("FOLLOW_SERVICE_PORT", "8087")
 	host := getEnv("FOLLOW_SERVICE_HOST", "follow-service")
 	consulAddr := getEnv("CONSUL_HTTP_ADDR", "localhost:8500")
@@ -24,11 +32,42 @@ func main() {
 	log.Println("Starting Follow Service...")
 	log.Printf("Host: %s Port: %s Consul: %s", host, port, consulAddr)
 
+	// Tracing: OTEL_TRACES_EXPORTER=otlp|stdout|none
+	shutdownTracing, err := tracing.Setup(context.Background(), "follow-service")
+	if err != nil {
+		log.Fatalf("tracing config error: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
 	db := database.New()
 	defer db.Close()
 
+	// Apply pending schema migrations when DB_AUTO_MIGRATE=true
+	if err := migrate.AutoMigrate(context.Background(), db.DB()); err != nil {
+		log.Fatalf("migration error: %v", err)
+	}
+
+	// Kafka (optional): follow-changed events let feed-service rebuild timelines
 	svc := follow.NewService(db)
-	router := follow.SetupRouter(svc)
+	if getEnv("KAFKA_BROKERS", "") != "" && getEnv("ENABLE_KAFKA", "true") == "true" {
+		kafkaConfig, err := kafkapkg.LoadConfig()
+		if err != nil {
+			log.Printf("Failed to load Kafka config, follow events disabled: %v", err)
+		} else if producer, err := kafkapkg.NewProducer(kafkaConfig, slog.Default()); err != nil {
+			log.Printf("Failed to create Kafka producer, follow events disabled: %v", err)
+		} else {
+			defer producer.Close()
+			svc = follow.NewServiceWithKafka(db, producer, kafkaConfig.FollowEventsTopic)
+		}
+	} else {
+		log.Println("Kafka disabled, timelines pick up follow changes when they expire")
+	}
+
+	signer, err := identity.LoadSigner()
+	if err != nil {
+		log.Fatalf("identity config error: %v", err)
+	}
+	router := follow.SetupRouter(svc, signer, db.WriteTracker())
 
 	// CONSUL
 	consulClient, err := consul.NewClientWithToken(consulAddr, consulToken)
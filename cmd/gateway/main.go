@@ -10,13 +10,17 @@ import (
 	"syscall"
 	"time"
 
+	"instant/internal/config"
 	"instant/internal/consul"
 	"instant/internal/gateway"
+	"instant/internal/identity"
 	"instant/internal/logger"
 	"instant/internal/session"
+	"instant/internal/tracing"
 
 	_ "instant/docs/swagger"
 	_ "github.com/joho/godotenv/autoload"
+	"github.com/redis/go-redis/v9"
 )
 
 // @title Instant API Gateway
@@ -40,7 +44,7 @@ import (
 
 func main() {
 	// Initialize structured logger
-	log := logger.New()
+	log := logger.ForService("gateway")
 	logger.SetDefault(log)
 
 	// Load configuration from environment
@@ -57,6 +61,14 @@ func main() {
 		"redis_addr", redisAddr,
 	)
 
+	// Tracing: OTEL_TRACES_EXPORTER=otlp|stdout|none
+	shutdownTracing, err := tracing.Setup(context.Background(), "gateway")
+	if err != nil {
+		slog.Error("Invalid tracing configuration", "error", err)
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
+
 	// Initialize Consul client
 	consulClient, err := consul.NewClientWithToken(consulAddr, consulToken)
 	if err != nil {
@@ -65,13 +77,116 @@ func main() {
 	}
 	slog.Info("Connected to Consul")
 
+	// Cache healthy instances locally and keep them updated with blocking
+	// queries, so proxied requests don't query Consul
+	discovery := consul.NewCachedDiscovery(consulClient, 0)
+	defer discovery.Close()
+
 	// Initialize Redis session store
+	sessionConfig, err := session.ConfigFromEnv()
+	if err != nil {
+		slog.Error("Failed to load session config", "error", err)
+		os.Exit(1)
+	}
+	cookies, err := session.LoadCookieSigner()
+	if err != nil {
+		slog.Error("Invalid session secret", "error", err)
+		os.Exit(1)
+	}
 	store := session.NewRedisStore(redisAddr, redisPassword, redisDB)
-	sessionMgr := session.NewManager(store)
+	sessionMgr := session.NewManager(store, sessionConfig)
 	slog.Info("Connected to Redis")
 
+	// Load the secret used to sign identity headers for backend services
+	signer, err := identity.LoadSigner()
+	if err != nil {
+		slog.Error("Invalid identity configuration", "error", err)
+		os.Exit(1)
+	}
+
+	// Initialize Redis-backed rate limiter
+	rateLimits, err := gateway.LoadRateLimitConfig()
+	if err != nil {
+		slog.Error("Invalid rate limit configuration", "error", err)
+		os.Exit(1)
+	}
+	limiterClient := redis.NewClient(&redis.Options{
+		Addr:     redisAddr,
+		Password: redisPassword,
+		DB:       redisDB,
+	})
+	tracing.InstrumentRedis(limiterClient)
+	limiter := gateway.NewRedisRateLimiter(limiterClient)
+	slog.Info("Rate limiting enabled",
+		"request_code", rateLimits.RequestCode.String(),
+		"verify_code", rateLimits.VerifyCode.String(),
+		"api", rateLimits.API.String(),
+	)
+
+	// Upstream timeouts, retries and circuit breakers
+	proxyConfig, err := gateway.LoadProxyConfig()
+	if err != nil {
+		slog.Error("Invalid proxy configuration", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("Upstream proxy configured",
+		"timeout", proxyConfig.Timeout.String(),
+		"stream_idle_timeout", proxyConfig.StreamIdleTimeout.String(),
+		"max_retries", proxyConfig.MaxRetries,
+		"breaker_failure_threshold", proxyConfig.Breaker.FailureThreshold,
+		"breaker_open_timeout", proxyConfig.Breaker.OpenTimeout.String(),
+	)
+
+	// One CORS policy for the public API, applied only here
+	corsConfig, err := config.LoadCORSConfig()
+	if err != nil {
+		slog.Error("Invalid CORS configuration", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("CORS configured",
+		"allowed_origins", corsConfig.Default.AllowedOrigins,
+		"route_overrides", len(corsConfig.Routes),
+	)
+
+	// Route table: built in, or from a file or Consul KV and reloaded on change
+	routeSource, err := gateway.LoadRouteSource()
+	if err != nil {
+		slog.Error("Invalid route table configuration", "error", err)
+		os.Exit(1)
+	}
+	routes, err := routeSource.Load(consulClient)
+	if err != nil {
+		slog.Error("Failed to load route table", "source", routeSource.String(), "error", err)
+		os.Exit(1)
+	}
+	slog.Info("Route table loaded", "source", routeSource.String(), "routes", len(routes.Routes))
+
 	// Setup router
-	router := gateway.SetupRouter(consulClient, sessionMgr)
+	trustedProxies, err := gateway.LoadTrustedProxies()
+	if err != nil {
+		slog.Error("Invalid trusted proxies", "error", err)
+		os.Exit(1)
+	}
+
+	router, err := gateway.NewRouter(discovery, sessionMgr, gateway.RouterConfig{
+		Routes:         routes,
+		Signer:         signer,
+		Cookies:        cookies,
+		RateLimiter:    limiter,
+		RateLimits:     rateLimits,
+		Proxy:          proxyConfig,
+		AdminToken:     getEnv("GATEWAY_ADMIN_TOKEN", ""),
+		CORS:           corsConfig,
+		TrustedProxies: trustedProxies,
+	})
+	if err != nil {
+		slog.Error("Invalid route table", "source", routeSource.String(), "error", err)
+		os.Exit(1)
+	}
+
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	go routeSource.Watch(watchCtx, consulClient, router.Reload)
 
 	// Create HTTP server
 	server := &http.Server{
@@ -81,6 +196,9 @@ func main() {
 		WriteTimeout: 30 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
+	// WebSocket and SSE streams lift the read and write timeouts; close them
+	// on shutdown rather than waiting
+	server.RegisterOnShutdown(router.CloseStreams)
 
 	// Start server in a goroutine
 	go func() {
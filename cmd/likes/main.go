@@ -12,10 +12,16 @@ import (
 
 	"instant/internal/consul"
 	"instant/internal/database"
+	"instant/internal/identity"
 	"instant/internal/likes"
+	"instant/internal/logger"
+	"instant/internal/migrate"
+	"instant/internal/tracing"
 )
 
 func main() {
+	logger.SetDefault(logger.ForService("likes-service"))
+
 	// ENV
 	port := getEnv("LIKES_SERVICE_PORT", "8084")
 	host := getEnv("LIKES_SERVICE_HOST", "likes-service")
@@ -25,6 +31,13 @@ func main() {
 	log.Println("Starting Likes Service...")
 	log.Printf("Host: %s Port: %s Consul: %s", host, port, consulAddr)
 
+	// Tracing: OTEL_TRACES_EXPORTER=otlp|stdout|none
+	shutdownTracing, err := tracing.Setup(context.Background(), "likes-service")
+	if err != nil {
+		log.Fatalf("tracing config error: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
 	db := database.New()
 	defer func() {
 		if err := db.Close(); err != nil {
@@ -32,8 +45,17 @@ func main() {
 		}
 	}()
 
+	// Apply pending schema migrations when DB_AUTO_MIGRATE=true
+	if err := migrate.AutoMigrate(context.Background(), db.DB()); err != nil {
+		log.Fatalf("migration error: %v", err)
+	}
+
 	svc := likes.NewService(db)
-	router := likes.SetupRouter(svc)
+	signer, err := identity.LoadSigner()
+	if err != nil {
+		log.Fatalf("identity config error: %v", err)
+	}
+	router := likes.SetupRouter(svc, signer, db.WriteTracker())
 
 	// Consul
 	consulClient, err := consul.NewClientWithToken(consulAddr, consulToken)
@@ -0,0 +1,103 @@
+// Command migrate applies the database schema migrations embedded in the binary.
+//
+// Usage:
+//
+//	migrate up              apply all pending migrations
+//	migrate down [N]        roll back the last N migrations (default 1)
+//	migrate status          list migrations and whether they are applied
+//	migrate force VERSION   mark migrations up to VERSION as applied without running them
+//
+// The database connection is configured with the same DB_* variables as the services.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"instant/internal/database"
+	"instant/internal/migrate"
+
+	_ "github.com/joho/godotenv/autoload"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	db := database.New()
+	defer db.Close()
+
+	migrator, err := migrate.NewEmbedded(db.DB())
+	if err != nil {
+		log.Fatalf("Invalid migrations: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	switch os.Args[1] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		log.Printf("Applied %d migration(s)", applied)
+
+	case "down":
+		n := 1
+		if len(os.Args) > 2 {
+			n, err = strconv.Atoi(os.Args[2])
+			if err != nil || n < 1 {
+				log.Fatalf("Invalid number of migrations: %s", os.Args[2])
+			}
+		}
+		rolledBack, err := migrator.Down(ctx, n)
+		if err != nil {
+			log.Fatalf("Rollback failed after %d migration(s): %v", rolledBack, err)
+		}
+		log.Printf("Rolled back %d migration(s)", rolledBack)
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatalf("Failed to read migration status: %v", err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			name := s.Name
+			if s.Missing {
+				name = "(no migration file)"
+			}
+			fmt.Printf("%03d  %-30s  %s\n", s.Version, name, state)
+		}
+
+	case "force":
+		if len(os.Args) < 3 {
+			usage()
+		}
+		version, err := strconv.ParseInt(os.Args[2], 10, 64)
+		if err != nil || version < 0 {
+			log.Fatalf("Invalid version: %s", os.Args[2])
+		}
+		if err := migrator.Force(ctx, version); err != nil {
+			log.Fatalf("Force failed: %v", err)
+		}
+		log.Printf("Schema version forced to %d", version)
+
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: migrate up | down [N] | status | force VERSION")
+	os.Exit(2)
+}
@@ -11,7 +11,9 @@ import (
 	"time"
 
 	"instant/internal/consul"
+	"instant/internal/logger"
 	"instant/internal/posts"
+	"instant/internal/tracing"
 
 	_ "github.com/joho/godotenv/autoload"
 )
@@ -50,6 +52,8 @@ func gracefulShutdown(apiServer *http.Server, consulClient *consul.Client, servi
 }
 
 func main() {
+	logger.SetDefault(logger.ForService("posts-service"))
+
 	// Load configuration
 	port := getEnv("PORT", "8082")
 	host := getEnv("POSTS_SERVICE_HOST", "localhost")
@@ -61,6 +65,13 @@ func main() {
 	log.Printf("Host: %s", host)
 	log.Printf("Consul: %s", consulAddr)
 
+	// Tracing: OTEL_TRACES_EXPORTER=otlp|stdout|none
+	shutdownTracing, err := tracing.Setup(context.Background(), "posts-service")
+	if err != nil {
+		log.Fatalf("tracing config error: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
 	// Initialize Consul client
 	consulClient, err := consul.NewClientWithToken(consulAddr, consulToken)
 	if err != nil {
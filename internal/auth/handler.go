@@ -1,11 +1,9 @@
 package auth
 
 import (
-	"log"
 	"net/http"
-	"os"
-	"strconv"
 
+	"instant/internal/logger"
 	"instant/internal/session"
 
 	"github.com/gin-gonic/gin"
@@ -15,13 +13,15 @@ import (
 type Handler struct {
 	service    Service
 	sessionMgr session.Manager
+	cookies    *session.CookieSigner
 }
 
 // NewHandler creates a new authentication handler
-func NewHandler(service Service, sessionMgr session.Manager) *Handler {
+func NewHandler(service Service, sessionMgr session.Manager, cookies *session.CookieSigner) *Handler {
 	return &Handler{
 		service:    service,
 		sessionMgr: sessionMgr,
+		cookies:    cookies,
 	}
 }
 
@@ -33,6 +33,7 @@ func NewHandler(service Service, sessionMgr session.Manager) *Handler {
 // @Param request body RequestCodeRequest true "Email address"
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /request-code [post]
 func (h *Handler) RequestCode(c *gin.Context) {
@@ -44,8 +45,12 @@ func (h *Handler) RequestCode(c *gin.Context) {
 	}
 
 	err := h.service.RequestCode(c.Request.Context(), req.Email)
+	if err == ErrTooManyAttempts {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many attempts, try again later"})
+		return
+	}
 	if err != nil {
-		log.Printf("Failed to request code for %s: %v", req.Email, err)
+		logger.FromContext(c.Request.Context()).Error("Failed to request code", "email", req.Email, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send verification code"})
 		return
 	}
@@ -64,6 +69,7 @@ func (h *Handler) RequestCode(c *gin.Context) {
 // @Success 200 {object} AuthResponse
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /verify-code [post]
 func (h *Handler) VerifyCode(c *gin.Context) {
@@ -83,12 +89,14 @@ func (h *Handler) VerifyCode(c *gin.Context) {
 	// Verify the code and get user
 	user, err := h.service.VerifyCode(c.Request.Context(), req.Email, req.Code, req.Username)
 	if err != nil {
-		log.Printf("Failed to verify code for %s: %v", req.Email, err)
+		logger.FromContext(c.Request.Context()).Error("Failed to verify code", "email", req.Email, "error", err)
 		
 		// Handle specific errors
 		switch err {
 		case ErrInvalidCode:
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid verification code"})
+		case ErrTooManyAttempts:
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many attempts, request a new code later"})
 		case ErrUsernameExists:
 			c.JSON(http.StatusConflict, gin.H{
 				"error":   "username_taken",
@@ -107,41 +115,40 @@ func (h *Handler) VerifyCode(c *gin.Context) {
 		return
 	}
 
-	// Get session max age from environment or use default
-	const defaultSessionMaxAge = 3600 // 1 hour
-	maxAge := defaultSessionMaxAge
-	if maxAgeStr := os.Getenv("SESSION_MAX_AGE"); maxAgeStr != "" {
-		if parsed, err := strconv.Atoi(maxAgeStr); err == nil {
-			maxAge = parsed
-		}
-	}
-
-	// Create session
-	sessionID, err := h.sessionMgr.Create(c.Request.Context(), user.ID, user.Email, maxAge)
-	if err != nil {
-		log.Printf("Failed to create session for user %s: %v", user.ID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session"})
+	sessionID, csrfToken, ok := h.startSession(c, user)
+	if !ok {
 		return
 	}
 
-	// Set session cookie
-	secure := os.Getenv("APP_ENV") == "production"
-	c.SetCookie(
-		"session_id",
-		sessionID,
-		maxAge,
-		"/",
-		"",
-		secure,
-		true, // httpOnly
-	)
-
 	c.JSON(http.StatusOK, AuthResponse{
 		User:      user,
 		SessionID: sessionID,
+		CSRFToken: csrfToken,
 	})
 }
 
+// startSession creates a session for user and sets the signed session cookie.
+// The CSRF token of the new session is sent in the X-CSRF-Token header.
+// It returns the cookie value and CSRF token; on failure it writes the error
+// response and returns false.
+func (h *Handler) startSession(c *gin.Context, user *User) (string, string, bool) {
+	client := session.ClientInfo{UserAgent: c.Request.UserAgent(), IP: c.ClientIP()}
+	sess, err := h.sessionMgr.Create(c.Request.Context(), user.ID, user.Email, client)
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to create session", "user_id", user.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session"})
+		return "", "", false
+	}
+
+	// The cookie lives as long as the idle timeout; the gateway issues it
+	// again whenever it extends the session
+	cookie := h.cookies.NewCookie(sess)
+	http.SetCookie(c.Writer, cookie)
+	c.Header(session.CSRFHeader, sess.CSRFToken)
+
+	return cookie.Value, sess.CSRFToken, true
+}
+
 // Logout handles POST /logout
 // @Summary Logout user
 // @Description Invalidates the current session
@@ -149,20 +156,21 @@ func (h *Handler) VerifyCode(c *gin.Context) {
 // @Success 200 {object} map[string]string
 // @Router /logout [post]
 func (h *Handler) Logout(c *gin.Context) {
-	// Get session ID from cookie
-	sessionID, err := c.Cookie("session_id")
+	// Get session ID from cookie; unsigned cookies name no session
+	sessionID, err := h.cookies.SessionID(c.Request)
 	if err != nil {
+		http.SetCookie(c.Writer, session.ClearCookie())
 		c.JSON(http.StatusOK, gin.H{"message": "already logged out"})
 		return
 	}
 
 	// Delete session
 	if err := h.sessionMgr.Delete(c.Request.Context(), sessionID); err != nil {
-		log.Printf("Failed to delete session %s: %v", sessionID, err)
+		logger.FromContext(c.Request.Context()).Error("Failed to delete session", "session_id", sessionID, "error", err)
 	}
 
 	// Clear cookie
-	c.SetCookie("session_id", "", -1, "/", "", false, true)
+	http.SetCookie(c.Writer, session.ClearCookie())
 
 	c.JSON(http.StatusOK, gin.H{"message": "logged out successfully"})
 }
@@ -217,7 +225,7 @@ func (h *Handler) UpdateUser(c *gin.Context) {
 	// Update user
 	user, err := h.service.UpdateUser(c.Request.Context(), userID, req)
 	if err != nil {
-		log.Printf("Failed to update user %s: %v", userID, err)
+		logger.FromContext(c.Request.Context()).Error("Failed to update user", "target_user_id", userID, "error", err)
 
 		// Handle specific errors
 		switch err {
@@ -241,6 +249,20 @@ func (h *Handler) UpdateUser(c *gin.Context) {
 		return
 	}
 
+	// Sessions carry the email they were created with, so an email change
+	// signs the user out everywhere and starts a fresh session here. Its CSRF
+	// token is returned in the X-CSRF-Token header.
+	if user.Email != c.GetString("email") {
+		if _, err := h.sessionMgr.RevokeAll(c.Request.Context(), userID); err != nil {
+			logger.FromContext(c.Request.Context()).Error("Failed to revoke sessions after email change", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke sessions"})
+			return
+		}
+		if _, _, ok := h.startSession(c, user); !ok {
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, user)
 }
 
@@ -277,7 +299,7 @@ func (h *Handler) RequestDeleteCode(c *gin.Context) {
 	// Get user to retrieve email
 	user, err := h.service.GetUserByID(c.Request.Context(), userID)
 	if err != nil {
-		log.Printf("Failed to get user %s: %v", userID, err)
+		logger.FromContext(c.Request.Context()).Error("Failed to get user", "target_user_id", userID, "error", err)
 		if err == ErrUserNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 		} else {
@@ -288,8 +310,12 @@ func (h *Handler) RequestDeleteCode(c *gin.Context) {
 
 	// Request verification code
 	err = h.service.RequestCode(c.Request.Context(), user.Email)
+	if err == ErrTooManyAttempts {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many attempts, try again later"})
+		return
+	}
 	if err != nil {
-		log.Printf("Failed to request delete code for %s: %v", user.Email, err)
+		logger.FromContext(c.Request.Context()).Error("Failed to request delete code", "email", user.Email, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send verification code"})
 		return
 	}
@@ -341,7 +367,7 @@ func (h *Handler) DeleteUser(c *gin.Context) {
 	// Get user email
 	user, err := h.service.GetUserByID(c.Request.Context(), userID)
 	if err != nil {
-		log.Printf("Failed to get user %s: %v", userID, err)
+		logger.FromContext(c.Request.Context()).Error("Failed to get user", "target_user_id", userID, "error", err)
 		if err == ErrUserNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 		} else {
@@ -353,11 +379,13 @@ func (h *Handler) DeleteUser(c *gin.Context) {
 	// Delete user (verifies code internally)
 	err = h.service.DeleteUser(c.Request.Context(), userID, user.Email, req.Code)
 	if err != nil {
-		log.Printf("Failed to delete user %s: %v", userID, err)
+		logger.FromContext(c.Request.Context()).Error("Failed to delete user", "target_user_id", userID, "error", err)
 
 		switch err {
 		case ErrInvalidCode:
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid verification code"})
+		case ErrTooManyAttempts:
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many attempts, request a new code later"})
 		case ErrUserNotFound:
 			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 		case ErrUnauthorized:
@@ -368,18 +396,136 @@ func (h *Handler) DeleteUser(c *gin.Context) {
 		return
 	}
 
-	// Delete session
-	sessionID, err := c.Cookie("session_id")
-	if err == nil {
-		if err := h.sessionMgr.Delete(c.Request.Context(), sessionID); err != nil {
-			log.Printf("Failed to delete session %s: %v", sessionID, err)
-		}
+	// Revoke every session of the deleted user
+	if _, err := h.sessionMgr.RevokeAll(c.Request.Context(), userID); err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to revoke sessions", "error", err)
 	}
 
 	// Clear cookie
-	c.SetCookie("session_id", "", -1, "/", "", false, true)
+	http.SetCookie(c.Writer, session.ClearCookie())
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "account deleted successfully",
 	})
 }
+
+// CSRFToken handles GET /csrf
+// @Summary Get CSRF token
+// @Description Returns the CSRF token of the current session. It must be sent in the X-CSRF-Token header of POST, PUT, PATCH and DELETE requests.
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /csrf [get]
+func (h *Handler) CSRFToken(c *gin.Context) {
+	sess, err := h.sessionMgr.Get(c.Request.Context(), c.GetString("session_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized: invalid session"})
+		return
+	}
+
+	token, err := h.sessionMgr.CSRFToken(c.Request.Context(), sess)
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to issue CSRF token", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue CSRF token"})
+		return
+	}
+
+	c.Header(session.CSRFHeader, token)
+	c.JSON(http.StatusOK, gin.H{"csrf_token": token})
+}
+
+// ListSessions handles GET /sessions
+// @Summary List active sessions
+// @Description Lists the signed-in user's active sessions, newest first
+// @Produce json
+// @Success 200 {array} SessionInfo
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /sessions [get]
+func (h *Handler) ListSessions(c *gin.Context) {
+	sessions, err := h.sessionMgr.List(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to list sessions", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list sessions"})
+		return
+	}
+
+	current := c.GetString("session_id")
+	infos := make([]SessionInfo, len(sessions))
+	for i, sess := range sessions {
+		infos[i] = NewSessionInfo(sess, sess.ID == current)
+	}
+
+	c.JSON(http.StatusOK, infos)
+}
+
+// RevokeSession handles DELETE /sessions/:id
+// @Summary Revoke a session
+// @Description Signs out one of the user's sessions by its public ID
+// @Produce json
+// @Param id path string true "Session ID from GET /sessions"
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /sessions/{id} [delete]
+func (h *Handler) RevokeSession(c *gin.Context) {
+	sessions, err := h.sessionMgr.List(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to list sessions", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke session"})
+		return
+	}
+
+	// Only the user's own sessions can be found, so no ownership check is needed
+	publicID := c.Param("id")
+	for _, sess := range sessions {
+		if session.PublicID(sess.ID) != publicID {
+			continue
+		}
+
+		if err := h.sessionMgr.Delete(c.Request.Context(), sess.ID); err != nil {
+			logger.FromContext(c.Request.Context()).Error("Failed to delete session", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke session"})
+			return
+		}
+		if sess.ID == c.GetString("session_id") {
+			http.SetCookie(c.Writer, session.ClearCookie())
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "session revoked"})
+		return
+	}
+
+	c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+}
+
+// RevokeAllSessions handles POST /sessions/revoke-all
+// @Summary Sign out everywhere
+// @Description Revokes every session of the user, including the current one
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /sessions/revoke-all [post]
+func (h *Handler) RevokeAllSessions(c *gin.Context) {
+	revoked, err := h.sessionMgr.RevokeAll(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to revoke sessions", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke sessions"})
+		return
+	}
+
+	// Sessions created before the per-user index existed are not in it
+	if err := h.sessionMgr.Delete(c.Request.Context(), c.GetString("session_id")); err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to delete session", "error", err)
+	}
+
+	http.SetCookie(c.Writer, session.ClearCookie())
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "all sessions revoked",
+		"revoked": revoked,
+	})
+}
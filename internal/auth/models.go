@@ -1,6 +1,10 @@
 package auth
 
-import "time"
+import (
+	"time"
+
+	"instant/internal/session"
+)
 
 // User represents a user in the system
 type User struct {
@@ -34,6 +38,9 @@ type VerifyCodeRequest struct {
 type AuthResponse struct {
 	User      *User  `json:"user"`
 	SessionID string `json:"session_id"`
+	// CSRFToken must be sent in the X-CSRF-Token header of state-changing
+	// requests made with the session
+	CSRFToken string `json:"csrf_token"`
 }
 
 // UpdateUserRequest is the request payload for updating user information
@@ -46,3 +53,28 @@ type UpdateUserRequest struct {
 type DeleteUserRequest struct {
 	Code string `json:"code" binding:"required,len=6"`
 }
+
+// SessionInfo describes one of the user's sessions. ID is the public session
+// identifier, never the session cookie itself.
+type SessionInfo struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// NewSessionInfo describes sess; current marks the session of the request
+func NewSessionInfo(sess *session.Session, current bool) SessionInfo {
+	return SessionInfo{
+		ID:         session.PublicID(sess.ID),
+		UserAgent:  sess.UserAgent,
+		IP:         sess.IP,
+		CreatedAt:  sess.CreatedAt,
+		LastSeenAt: sess.LastSeenAt,
+		ExpiresAt:  sess.ExpiresAt,
+		Current:    current,
+	}
+}
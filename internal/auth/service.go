@@ -5,16 +5,19 @@ package auth
 import (
 	"context"
 	"crypto/rand"
+	"crypto/subtle"
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
 	"math/big"
+	"os"
+	"strconv"
 	"time"
 
 	"instant/internal/database"
 	emailpkg "instant/internal/email"
 	kafkapkg "instant/internal/kafka"
+	"instant/internal/logger"
 	"instant/internal/session"
 
 	"github.com/google/uuid"
@@ -23,11 +26,19 @@ import (
 const (
 	// VerificationCodeTTL defines how long verification codes remain valid
 	VerificationCodeTTL = 10 * time.Minute
+
+	// DefaultMaxCodeAttempts is how many wrong codes are accepted per email
+	// before the code is invalidated and the email is locked out
+	DefaultMaxCodeAttempts = 5
+	// DefaultCodeLockout is how long an email stays locked out, unless a new code is requested
+	DefaultCodeLockout = 15 * time.Minute
 )
 
 var (
 	// ErrInvalidCode is returned when verification code is invalid
 	ErrInvalidCode = errors.New("invalid or expired verification code")
+	// ErrTooManyAttempts is returned when an email is locked out after too many wrong codes
+	ErrTooManyAttempts = errors.New("too many verification attempts")
 	// ErrUserNotFound is returned when user is not found
 	ErrUserNotFound = errors.New("user not found")
 	// ErrUsernameExists is returned when username is already taken
@@ -55,6 +66,9 @@ type service struct {
 	emailSender   emailpkg.Sender // Kept for backwards compatibility
 	kafkaProducer *kafkapkg.Producer
 	useKafka      bool // Flag to enable/disable Kafka
+
+	maxCodeAttempts int
+	codeLockout     time.Duration
 }
 
 // NewService creates a new authentication service (legacy, without Kafka)
@@ -65,6 +79,9 @@ func NewService(db database.Service, codeStore session.Store, emailSender emailp
 		emailSender:   emailSender,
 		kafkaProducer: nil,
 		useKafka:      false,
+
+		maxCodeAttempts: maxCodeAttemptsFromEnv(),
+		codeLockout:     codeLockoutFromEnv(),
 	}
 }
 
@@ -76,6 +93,9 @@ func NewServiceWithKafka(db database.Service, codeStore session.Store, emailSend
 		emailSender:   emailSender, // Fallback if Kafka fails
 		kafkaProducer: kafkaProducer,
 		useKafka:      kafkaProducer != nil,
+
+		maxCodeAttempts: maxCodeAttemptsFromEnv(),
+		codeLockout:     codeLockoutFromEnv(),
 	}
 }
 
@@ -84,6 +104,12 @@ func (s *service) RequestCode(ctx context.Context, email string) error {
 	// Generate 6-digit verification code
 	code := generateSixDigitCode()
 
+	// A fresh code gets a fresh budget of attempts, lifting any lockout:
+	// the request-code rate limit already bounds how many codes can be guessed at
+	if err := s.codeStore.Delete(ctx, codeAttemptsKey(email), codeLockKey(email)); err != nil {
+		return fmt.Errorf("failed to reset verification attempts: %w", err)
+	}
+
 	// Store code in Redis with TTL
 	key := fmt.Sprintf("code:%s", email)
 	err := s.codeStore.Set(ctx, key, code, VerificationCodeTTL)
@@ -106,17 +132,17 @@ func (s *service) RequestCode(ctx context.Context, email string) error {
 		}
 
 		// Publish asynchronously - this returns immediately after queuing
-		err = s.kafkaProducer.PublishEmailEvent(s.getKafkaTopic(), event)
+		err = s.kafkaProducer.PublishEmailEvent(ctx, s.getKafkaTopic(), event)
 		if err != nil {
 			// Only fails if message couldn't be queued (e.g., producer buffer full)
-			log.Printf("Failed to queue message to Kafka, falling back to direct email: %v", err)
+			logger.FromContext(ctx).Warn("Failed to queue message to Kafka, falling back to direct email", "error", err)
 			// Fallback to direct email
 			err = s.emailSender.SendVerificationCode(email, code)
 			if err != nil {
 				return fmt.Errorf("failed to send verification code: %w", err)
 			}
 		} else {
-			log.Printf("Verification code queued to Kafka for email: %s", email)
+			logger.FromContext(ctx).Info("Verification code queued to Kafka", "email", email)
 		}
 	} else {
 		// Send directly via email (legacy mode)
@@ -137,21 +163,8 @@ func (s *service) getKafkaTopic() string {
 
 // VerifyCode verifies the provided code and returns the user
 func (s *service) VerifyCode(ctx context.Context, email, code, username string) (*User, error) {
-	// Get stored code from Redis
-	key := fmt.Sprintf("code:%s", email)
-	storedCode, err := s.codeStore.Get(ctx, key)
-	if err != nil {
-		return nil, ErrInvalidCode
-	}
-
-	// Compare codes
-	if storedCode != code {
-		return nil, ErrInvalidCode
-	}
-
-	// Delete used code immediately (best effort, log if fails)
-	if err := s.codeStore.Delete(ctx, key); err != nil {
-		log.Printf("Warning: failed to delete verification code for %s: %v", email, err)
+	if err := s.checkCode(ctx, email, code); err != nil {
+		return nil, err
 	}
 
 	// Get or create user with username
@@ -165,6 +178,28 @@ func (s *service) VerifyCode(ctx context.Context, email, code, username string)
 
 // VerifyCodeOnly verifies the provided code without creating or updating a user
 func (s *service) VerifyCodeOnly(ctx context.Context, email, code string) error {
+	return s.checkCode(ctx, email, code)
+}
+
+// checkCode compares the code against the stored one and consumes it on success.
+// Every attempt is counted per email before the comparison, so concurrent
+// guesses cannot get past the limit; once maxCodeAttempts is used up the
+// code is invalidated and the email is locked out for codeLockout, or until
+// a new code is requested.
+func (s *service) checkCode(ctx context.Context, email, code string) error {
+	if locked, err := s.codeStore.Exists(ctx, codeLockKey(email)); err == nil && locked {
+		return ErrTooManyAttempts
+	}
+
+	attempts, err := s.codeStore.Incr(ctx, codeAttemptsKey(email), VerificationCodeTTL)
+	if err != nil {
+		return fmt.Errorf("failed to count verification attempt: %w", err)
+	}
+	if attempts > int64(s.maxCodeAttempts) {
+		s.lockOut(ctx, email)
+		return ErrTooManyAttempts
+	}
+
 	// Get stored code from Redis
 	key := fmt.Sprintf("code:%s", email)
 	storedCode, err := s.codeStore.Get(ctx, key)
@@ -172,19 +207,69 @@ func (s *service) VerifyCodeOnly(ctx context.Context, email, code string) error
 		return ErrInvalidCode
 	}
 
-	// Compare codes
-	if storedCode != code {
+	// Compare codes in constant time
+	if subtle.ConstantTimeCompare([]byte(storedCode), []byte(code)) != 1 {
+		if attempts == int64(s.maxCodeAttempts) {
+			s.lockOut(ctx, email)
+			return ErrTooManyAttempts
+		}
 		return ErrInvalidCode
 	}
 
-	// Delete used code immediately (best effort, log if fails)
+	// Delete used code and attempt counter immediately (best effort, log if fails)
 	if err := s.codeStore.Delete(ctx, key); err != nil {
-		log.Printf("Warning: failed to delete verification code for %s: %v", email, err)
+		logger.FromContext(ctx).Warn("Failed to delete verification code", "email", email, "error", err)
+	}
+	if err := s.codeStore.Delete(ctx, codeAttemptsKey(email)); err != nil {
+		logger.FromContext(ctx).Warn("Failed to reset verification attempts", "email", email, "error", err)
 	}
 
 	return nil
 }
 
+// lockOut invalidates the pending code and blocks the email for codeLockout
+func (s *service) lockOut(ctx context.Context, email string) {
+	logger.FromContext(ctx).Warn("Too many verification attempts, locking out", "email", email, "lockout", s.codeLockout)
+
+	if err := s.codeStore.Set(ctx, codeLockKey(email), "1", s.codeLockout); err != nil {
+		logger.FromContext(ctx).Warn("Failed to lock out", "email", email, "error", err)
+	}
+	if err := s.codeStore.Delete(ctx, fmt.Sprintf("code:%s", email)); err != nil {
+		logger.FromContext(ctx).Warn("Failed to invalidate verification code", "email", email, "error", err)
+	}
+	if err := s.codeStore.Delete(ctx, codeAttemptsKey(email)); err != nil {
+		logger.FromContext(ctx).Warn("Failed to reset verification attempts", "email", email, "error", err)
+	}
+}
+
+func codeAttemptsKey(email string) string {
+	return fmt.Sprintf("code_attempts:%s", email)
+}
+
+func codeLockKey(email string) string {
+	return fmt.Sprintf("code_lock:%s", email)
+}
+
+// maxCodeAttemptsFromEnv reads AUTH_CODE_MAX_ATTEMPTS or returns the default
+func maxCodeAttemptsFromEnv() int {
+	if v := os.Getenv("AUTH_CODE_MAX_ATTEMPTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return DefaultMaxCodeAttempts
+}
+
+// codeLockoutFromEnv reads AUTH_CODE_LOCKOUT (e.g. "15m") or returns the default
+func codeLockoutFromEnv() time.Duration {
+	if v := os.Getenv("AUTH_CODE_LOCKOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+	}
+	return DefaultCodeLockout
+}
+
 // getOrCreateUser retrieves a user by email or creates a new one if not exists
 func (s *service) getOrCreateUser(ctx context.Context, email, username string) (*User, error) {
 	// Try to get existing user
@@ -252,7 +337,7 @@ func (s *service) createUser(ctx context.Context, email, username string) (*User
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
-	log.Printf("Created new user: %s (ID: %s, Username: %s)", createdUser.Email, createdUser.ID, createdUser.Username)
+	logger.FromContext(ctx).Info("Created new user", "email", createdUser.Email, "target_user_id", createdUser.ID, "username", createdUser.Username)
 
 	return &createdUser, nil
 }
@@ -278,7 +363,7 @@ func (s *service) updateUserUsername(ctx context.Context, userID, username strin
 		return nil, fmt.Errorf("failed to update username: %w", err)
 	}
 
-	log.Printf("Updated username for user: %s (ID: %s, New Username: %s)", user.Email, user.ID, user.Username)
+	logger.FromContext(ctx).Info("Updated username", "email", user.Email, "target_user_id", user.ID, "username", user.Username)
 
 	return &user, nil
 }
@@ -374,12 +459,21 @@ func (s *service) UpdateUser(ctx context.Context, userID string, updates UpdateU
 		return nil, fmt.Errorf("failed to update user: %w", err)
 	}
 
-	log.Printf("Updated user: %s (ID: %s)", updatedUser.Email, updatedUser.ID)
+	logger.FromContext(ctx).Info("Updated user", "email", updatedUser.Email, "target_user_id", updatedUser.ID)
 
 	return &updatedUser, nil
 }
 
-// DeleteUser deletes a user account after verifying the code
+// deleteUserQueries remove everything owned by a user, children first
+var deleteUserQueries = []string{
+	`DELETE FROM follow WHERE follower_id = $1 OR followee_id = $1`,
+	`DELETE FROM comments WHERE user_id = $1`,
+	`DELETE FROM likes WHERE user_id = $1`,
+	`DELETE FROM posts WHERE user_id = $1`,
+	`DELETE FROM users WHERE id = $1`,
+}
+
+// DeleteUser deletes a user account and all of its data after verifying the code
 func (s *service) DeleteUser(ctx context.Context, userID, email, code string) error {
 	// Verify the code first
 	err := s.VerifyCodeOnly(ctx, email, code)
@@ -387,24 +481,37 @@ func (s *service) DeleteUser(ctx context.Context, userID, email, code string) er
 		return err
 	}
 
-	// Verify user exists and email matches
-	user, err := s.GetUserByID(ctx, userID)
-	if err != nil {
-		return err
-	}
+	// Check and delete in one transaction, with the user row locked so the
+	// email cannot change in between
+	err = s.db.WithTx(ctx, nil, func(tx database.Tx) error {
+		// Verify user exists and email matches
+		var currentEmail string
+		err := tx.QueryRow(ctx, `SELECT email FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&currentEmail)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
 
-	if user.Email != email {
-		return ErrUnauthorized
-	}
+		if currentEmail != email {
+			return ErrUnauthorized
+		}
 
-	// Delete user
-	query := `DELETE FROM users WHERE id = $1`
-	_, err = s.db.Exec(ctx, query, userID)
+		// Delete the user's data, then the user. Foreign keys cascade too, but
+		// explicit deletes keep this all-or-nothing whatever the constraints say.
+		for _, query := range deleteUserQueries {
+			if _, err := tx.Exec(ctx, query, userID); err != nil {
+				return fmt.Errorf("failed to delete user: %w", err)
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to delete user: %w", err)
+		return err
 	}
 
-	log.Printf("Deleted user: %s (ID: %s)", email, userID)
+	logger.FromContext(ctx).Info("Deleted user", "email", email, "target_user_id", userID)
 
 	return nil
 }
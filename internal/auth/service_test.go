@@ -0,0 +1,265 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	emailpkg "instant/internal/email"
+)
+
+// fakeStore is an in-memory session.Store. TTLs are ignored.
+type fakeStore struct {
+	mu       sync.Mutex
+	values   map[string]string
+	counters map[string]int64
+	sets     map[string]map[string]bool
+	codeGets int // verification codes read, i.e. guesses compared
+
+	// lockChecked, when set, holds lockout checks until it is closed
+	lockChecked chan struct{}
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		values:   make(map[string]string),
+		counters: make(map[string]int64),
+		sets:     make(map[string]map[string]bool),
+	}
+}
+
+func (f *fakeStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.values[key] = value
+	return nil
+}
+
+func (f *fakeStore) Get(ctx context.Context, key string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	value, ok := f.values[key]
+	if !ok {
+		return "", errors.New("not found")
+	}
+	if strings.HasPrefix(key, "code:") {
+		f.codeGets++
+	}
+	return value, nil
+}
+
+func (f *fakeStore) SetIfExists(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.values[key]; !ok {
+		return false, nil
+	}
+	f.values[key] = value
+	return true, nil
+}
+
+func (f *fakeStore) Delete(ctx context.Context, keys ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, key := range keys {
+		delete(f.values, key)
+		delete(f.counters, key)
+		delete(f.sets, key)
+	}
+	return nil
+}
+
+func (f *fakeStore) Exists(ctx context.Context, key string) (bool, error) {
+	if f.lockChecked != nil && strings.HasPrefix(key, "code_lock:") {
+		<-f.lockChecked
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.values[key]
+	return ok, nil
+}
+
+func (f *fakeStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	time.Sleep(time.Millisecond) // a round trip to Redis, so concurrent requests overlap
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.counters[key]++
+	return f.counters[key], nil
+}
+
+func (f *fakeStore) AddToSet(ctx context.Context, key, member string, ttl time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.sets[key] == nil {
+		f.sets[key] = make(map[string]bool)
+	}
+	f.sets[key][member] = true
+	return nil
+}
+
+func (f *fakeStore) RemoveFromSet(ctx context.Context, key string, members ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, member := range members {
+		delete(f.sets[key], member)
+	}
+	return nil
+}
+
+func (f *fakeStore) SetMembers(ctx context.Context, key string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var members []string
+	for member := range f.sets[key] {
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+func (f *fakeStore) SetIndexed(ctx context.Context, key, value string, ttl time.Duration, setKey, member, guard string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.values[guard]; guard != "" && !ok {
+		return false, nil
+	}
+	f.values[key] = value
+	if f.sets[setKey] == nil {
+		f.sets[setKey] = make(map[string]bool)
+	}
+	f.sets[setKey][member] = true
+	return true, nil
+}
+
+func (f *fakeStore) DeleteIndexed(ctx context.Context, setKey, keyPrefix string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	members := f.sets[setKey]
+	for member := range members {
+		delete(f.values, keyPrefix+member)
+	}
+	delete(f.sets, setKey)
+	return len(members), nil
+}
+
+const testEmail = "user@example.com"
+
+func newTestService(store *fakeStore) *service {
+	store.values["code:"+testEmail] = "123456"
+	return &service{codeStore: store, maxCodeAttempts: 3, codeLockout: time.Minute}
+}
+
+func TestCheckCode_LocksOutAfterMaxAttempts(t *testing.T) {
+	store := newFakeStore()
+	s := newTestService(store)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := s.VerifyCodeOnly(ctx, testEmail, "000000"); err != ErrInvalidCode {
+			t.Fatalf("attempt %d: expected ErrInvalidCode, got %v", i+1, err)
+		}
+	}
+	if err := s.VerifyCodeOnly(ctx, testEmail, "000000"); err != ErrTooManyAttempts {
+		t.Fatalf("Expected the last allowed wrong code to lock out, got %v", err)
+	}
+
+	// The right code no longer works, and no new code is handed out
+	if err := s.VerifyCodeOnly(ctx, testEmail, "123456"); err != ErrTooManyAttempts {
+		t.Errorf("Expected the locked out email to be rejected, got %v", err)
+	}
+	if _, ok := store.values["code:"+testEmail]; ok {
+		t.Error("Expected the pending code to be invalidated")
+	}
+}
+
+// fakeSender records the last verification code sent
+type fakeSender struct {
+	emailpkg.Sender
+	code string
+}
+
+func (f *fakeSender) SendVerificationCode(email, code string) error {
+	f.code = code
+	return nil
+}
+
+func TestRequestCode_LiftsLockout(t *testing.T) {
+	store := newFakeStore()
+	s := newTestService(store)
+	sender := &fakeSender{}
+	s.emailSender = sender
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		s.VerifyCodeOnly(ctx, testEmail, "000000")
+	}
+	if err := s.VerifyCodeOnly(ctx, testEmail, "123456"); err != ErrTooManyAttempts {
+		t.Fatalf("Expected the email to be locked out, got %v", err)
+	}
+
+	// A new code starts over with a full budget of attempts
+	if err := s.RequestCode(ctx, testEmail); err != nil {
+		t.Fatalf("Expected a new code to be issued, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := s.VerifyCodeOnly(ctx, testEmail, "000000"); err != ErrInvalidCode {
+			t.Fatalf("attempt %d: expected ErrInvalidCode, got %v", i+1, err)
+		}
+	}
+	if err := s.VerifyCodeOnly(ctx, testEmail, sender.code); err != nil {
+		t.Errorf("Expected the new code to be accepted, got %v", err)
+	}
+}
+
+func TestCheckCode_SuccessResetsAttempts(t *testing.T) {
+	store := newFakeStore()
+	s := newTestService(store)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		s.VerifyCodeOnly(ctx, testEmail, "000000")
+	}
+	if err := s.VerifyCodeOnly(ctx, testEmail, "123456"); err != nil {
+		t.Fatalf("Expected the right code to be accepted, got %v", err)
+	}
+	if _, ok := store.counters[codeAttemptsKey(testEmail)]; ok {
+		t.Error("Expected the attempt counter to be reset")
+	}
+
+	// The code is single use
+	if err := s.VerifyCodeOnly(ctx, testEmail, "123456"); err != ErrInvalidCode {
+		t.Errorf("Expected a used code to be rejected, got %v", err)
+	}
+}
+
+func TestCheckCode_ConcurrentGuessesAreCapped(t *testing.T) {
+	store := newFakeStore()
+	s := newTestService(store)
+	ctx := context.Background()
+
+	// Guesses sent in parallel all pass the lockout check before any has
+	// been counted; only maxCodeAttempts of them may be compared
+	store.lockChecked = make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.VerifyCodeOnly(ctx, testEmail, "000000")
+		}()
+	}
+	time.Sleep(50 * time.Millisecond) // let every guess reach the lockout check
+	close(store.lockChecked)
+	wg.Wait()
+
+	if store.codeGets > s.maxCodeAttempts {
+		t.Errorf("Expected at most %d codes to be compared, got %d", s.maxCodeAttempts, store.codeGets)
+	}
+	if err := s.VerifyCodeOnly(ctx, testEmail, "123456"); err != ErrTooManyAttempts {
+		t.Errorf("Expected the email to be locked out, got %v", err)
+	}
+}
@@ -4,6 +4,8 @@ import (
     "net/http"
     "strconv"
 
+    "instant/internal/identity"
+
     "github.com/gin-gonic/gin"
 )
 
@@ -13,10 +15,6 @@ type Handler struct {
 
 func NewHandler(svc Service) *Handler { return &Handler{svc: svc} }
 
-func getUserID(c *gin.Context) string {
-    return c.GetHeader("X-User-ID")
-}
-
 // Create handles POST /
 // @Summary Create a comment
 // @Description Create a new comment on a post (requires authentication)
@@ -31,7 +29,7 @@ func getUserID(c *gin.Context) string {
 // @Security SessionAuth
 // @Router /api/comments [post]
 func (h *Handler) Create(c *gin.Context) {
-    userID := getUserID(c)
+    userID := identity.UserID(c)
     if userID == "" {
         c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
         return
@@ -66,7 +64,7 @@ func (h *Handler) Create(c *gin.Context) {
 // @Security SessionAuth
 // @Router /api/comments/{id} [patch]
 func (h *Handler) Update(c *gin.Context) {
-    userID := getUserID(c)
+    userID := identity.UserID(c)
     if userID == "" {
         c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
         return
@@ -100,7 +98,7 @@ func (h *Handler) Update(c *gin.Context) {
 // @Security SessionAuth
 // @Router /api/comments/{id} [delete]
 func (h *Handler) Delete(c *gin.Context) {
-    userID := getUserID(c)
+    userID := identity.UserID(c)
     if userID == "" {
         c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
         return
@@ -1,17 +1,29 @@
 package comments
 
-import "github.com/gin-gonic/gin"
+import (
+    "instant/internal/identity"
+    "instant/internal/logger"
+    "instant/internal/metrics"
+    "instant/internal/tracing"
 
-func SetupRouter(svc Service) *gin.Engine {
+    "github.com/gin-gonic/gin"
+)
+
+func SetupRouter(svc Service, signer *identity.Signer, writes identity.WriteTracker) *gin.Engine {
     r := gin.Default()
+    r.Use(logger.Middleware())
+    r.Use(tracing.Middleware())
+    metrics.Instrument(r) // request metrics and /metrics
     h := NewHandler(svc)
 
     r.GET("/health", h.Health)
 
-    r.POST("/", h.Create)
-    r.PATCH("/:id", h.Update)
-    r.DELETE("/:id", h.Delete)
-    r.GET("/post/:post_id", h.List)
+    api := r.Group("/")
+    api.Use(identity.RequireUser(signer), identity.ReadYourWrites(writes))
+    api.POST("/", h.Create)
+    api.PATCH("/:id", h.Update)
+    api.DELETE("/:id", h.Delete)
+    api.GET("/post/:post_id", h.List)
 
     return r
 }
@@ -0,0 +1,281 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSPolicy is the cross-origin policy applied to a set of routes
+type CORSPolicy struct {
+	// AllowedOrigins lists origins such as "https://app.example.com".
+	// Patterns use "*" for any part of the host, so "https://*.example.com"
+	// allows every subdomain; a lone "*" allows any origin and cannot be
+	// combined with credentials.
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	// ExposedHeaders are response headers the browser lets scripts read
+	ExposedHeaders []string
+	// AllowCredentials lets the browser send cookies cross-origin
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response
+	MaxAge time.Duration
+}
+
+// CORSRoute overrides parts of the default policy for paths under PathPrefix.
+// Fields left empty keep the default.
+type CORSRoute struct {
+	PathPrefix       string        `json:"path_prefix"`
+	AllowedOrigins   []string      `json:"allowed_origins,omitempty"`
+	AllowedMethods   []string      `json:"allowed_methods,omitempty"`
+	AllowedHeaders   []string      `json:"allowed_headers,omitempty"`
+	ExposedHeaders   []string      `json:"exposed_headers,omitempty"`
+	AllowCredentials *bool         `json:"allow_credentials,omitempty"`
+	MaxAge           time.Duration `json:"-"`
+}
+
+// CORSConfig is the CORS policy of the public API. Only the gateway applies
+// it; backend services are not reached by browsers directly.
+type CORSConfig struct {
+	Default CORSPolicy
+	// Routes are matched by longest path prefix
+	Routes []CORSRoute
+}
+
+// corsFile is the JSON layout of CORS_CONFIG_FILE. Durations are written
+// like "10m".
+type corsFile struct {
+	AllowedOrigins   []string        `json:"allowed_origins"`
+	AllowedMethods   []string        `json:"allowed_methods"`
+	AllowedHeaders   []string        `json:"allowed_headers"`
+	ExposedHeaders   []string        `json:"exposed_headers"`
+	AllowCredentials *bool           `json:"allow_credentials"`
+	MaxAge           string          `json:"max_age"`
+	Routes           []corsFileRoute `json:"routes"`
+}
+
+type corsFileRoute struct {
+	CORSRoute
+	MaxAge string `json:"max_age"`
+}
+
+// DefaultCORSConfig allows the local frontend with credentials
+func DefaultCORSConfig() CORSConfig {
+	return CORSConfig{
+		Default: CORSPolicy{
+			AllowedOrigins: []string{"http://localhost:5173"},
+			AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+			AllowedHeaders: []string{
+				"Accept", "Accept-Encoding", "Authorization", "Cache-Control",
+				"Content-Length", "Content-Type", "Origin", "X-CSRF-Token",
+				"X-Request-ID", "X-Requested-With",
+			},
+			ExposedHeaders:   []string{"X-Request-ID", "X-CSRF-Token"},
+			AllowCredentials: true,
+			MaxAge:           10 * time.Minute,
+		},
+	}
+}
+
+// LoadCORSConfig builds the CORS config from DefaultCORSConfig, then the JSON
+// file named by CORS_CONFIG_FILE, then CORS_ALLOWED_ORIGINS,
+// CORS_ALLOWED_METHODS, CORS_ALLOWED_HEADERS, CORS_EXPOSED_HEADERS
+// (comma-separated), CORS_ALLOW_CREDENTIALS and CORS_MAX_AGE. Per-route
+// overrides can only be set in the file.
+func LoadCORSConfig() (CORSConfig, error) {
+	cfg := DefaultCORSConfig()
+
+	if file := os.Getenv("CORS_CONFIG_FILE"); file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return cfg, fmt.Errorf("CORS_CONFIG_FILE: %w", err)
+		}
+		if err := cfg.applyFile(data); err != nil {
+			return cfg, fmt.Errorf("CORS_CONFIG_FILE %s: %w", file, err)
+		}
+	}
+
+	lists := []struct {
+		env   string
+		value *[]string
+	}{
+		{"CORS_ALLOWED_ORIGINS", &cfg.Default.AllowedOrigins},
+		{"CORS_ALLOWED_METHODS", &cfg.Default.AllowedMethods},
+		{"CORS_ALLOWED_HEADERS", &cfg.Default.AllowedHeaders},
+		{"CORS_EXPOSED_HEADERS", &cfg.Default.ExposedHeaders},
+	}
+	for _, l := range lists {
+		if v := os.Getenv(l.env); v != "" {
+			*l.value = splitList(v)
+		}
+	}
+
+	if v := os.Getenv("CORS_ALLOW_CREDENTIALS"); v != "" {
+		allow, err := strconv.ParseBool(v)
+		if err != nil {
+			return cfg, fmt.Errorf("CORS_ALLOW_CREDENTIALS: invalid value %q", v)
+		}
+		cfg.Default.AllowCredentials = allow
+	}
+
+	if v := os.Getenv("CORS_MAX_AGE"); v != "" {
+		maxAge, err := time.ParseDuration(v)
+		if err != nil || maxAge < 0 {
+			return cfg, fmt.Errorf("CORS_MAX_AGE: invalid duration %q", v)
+		}
+		cfg.Default.MaxAge = maxAge
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// applyFile merges a JSON config file into cfg
+func (c *CORSConfig) applyFile(data []byte) error {
+	var file corsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return err
+	}
+
+	if file.AllowedOrigins != nil {
+		c.Default.AllowedOrigins = file.AllowedOrigins
+	}
+	if file.AllowedMethods != nil {
+		c.Default.AllowedMethods = file.AllowedMethods
+	}
+	if file.AllowedHeaders != nil {
+		c.Default.AllowedHeaders = file.AllowedHeaders
+	}
+	if file.ExposedHeaders != nil {
+		c.Default.ExposedHeaders = file.ExposedHeaders
+	}
+	if file.AllowCredentials != nil {
+		c.Default.AllowCredentials = *file.AllowCredentials
+	}
+	if file.MaxAge != "" {
+		maxAge, err := time.ParseDuration(file.MaxAge)
+		if err != nil || maxAge < 0 {
+			return fmt.Errorf("max_age: invalid duration %q", file.MaxAge)
+		}
+		c.Default.MaxAge = maxAge
+	}
+
+	for _, r := range file.Routes {
+		route := r.CORSRoute
+		if r.MaxAge != "" {
+			maxAge, err := time.ParseDuration(r.MaxAge)
+			if err != nil || maxAge < 0 {
+				return fmt.Errorf("routes %s: invalid max_age %q", route.PathPrefix, r.MaxAge)
+			}
+			route.MaxAge = maxAge
+		}
+		c.Routes = append(c.Routes, route)
+	}
+
+	return nil
+}
+
+// Validate rejects policies browsers would refuse or that would let any site
+// make credentialed requests
+func (c CORSConfig) Validate() error {
+	policies := map[string]CORSPolicy{"default": c.Default}
+	for _, r := range c.Routes {
+		if !strings.HasPrefix(r.PathPrefix, "/") {
+			return fmt.Errorf("CORS route %q: path prefix must start with /", r.PathPrefix)
+		}
+		policies[r.PathPrefix] = c.PolicyFor(r.PathPrefix)
+	}
+
+	for name, p := range policies {
+		for _, origin := range p.AllowedOrigins {
+			if origin == "*" && p.AllowCredentials {
+				return fmt.Errorf("CORS %s: allowing any origin with credentials is not allowed", name)
+			}
+			if _, err := path.Match(origin, ""); err != nil {
+				return fmt.Errorf("CORS %s: invalid origin pattern %q", name, origin)
+			}
+		}
+	}
+	if len(c.Default.AllowedMethods) == 0 {
+		return errors.New("CORS: no allowed methods")
+	}
+
+	return nil
+}
+
+// PolicyFor returns the policy for a request path: the default merged with
+// the route override with the longest matching prefix
+func (c CORSConfig) PolicyFor(requestPath string) CORSPolicy {
+	routes := make([]CORSRoute, len(c.Routes))
+	copy(routes, c.Routes)
+	sort.SliceStable(routes, func(i, j int) bool {
+		return len(routes[i].PathPrefix) > len(routes[j].PathPrefix)
+	})
+
+	policy := c.Default
+	for _, r := range routes {
+		if !hasPathPrefix(requestPath, r.PathPrefix) {
+			continue
+		}
+		if r.AllowedOrigins != nil {
+			policy.AllowedOrigins = r.AllowedOrigins
+		}
+		if r.AllowedMethods != nil {
+			policy.AllowedMethods = r.AllowedMethods
+		}
+		if r.AllowedHeaders != nil {
+			policy.AllowedHeaders = r.AllowedHeaders
+		}
+		if r.ExposedHeaders != nil {
+			policy.ExposedHeaders = r.ExposedHeaders
+		}
+		if r.AllowCredentials != nil {
+			policy.AllowCredentials = *r.AllowCredentials
+		}
+		if r.MaxAge > 0 {
+			policy.MaxAge = r.MaxAge
+		}
+		break
+	}
+	return policy
+}
+
+// AllowsOrigin reports whether origin matches one of the allowed origins
+func (p CORSPolicy) AllowsOrigin(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	for _, allowed := range p.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+		if ok, _ := path.Match(strings.ToLower(allowed), strings.ToLower(origin)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPathPrefix matches whole path segments, so /api/files does not match
+// /api/filesystem
+func hasPathPrefix(requestPath, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return requestPath == prefix || strings.HasPrefix(requestPath, prefix+"/") || prefix == ""
+}
+
+// splitList splits a comma-separated env value, dropping empty entries
+func splitList(v string) []string {
+	var values []string
+	for _, entry := range strings.Split(v, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			values = append(values, entry)
+		}
+	}
+	return values
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCORSPolicyAllowsOrigin(t *testing.T) {
+	p := CORSPolicy{AllowedOrigins: []string{"https://app.example.com", "https://*.preview.example.com"}}
+
+	for origin, want := range map[string]bool{
+		"https://app.example.com":               true,
+		"HTTPS://APP.EXAMPLE.COM":               true,
+		"https://pr-12.preview.example.com":     true,
+		"http://app.example.com":                false,
+		"https://preview.example.com":           false,
+		"https://evil.com/.preview.example.com": false,
+		"":                                      false,
+	} {
+		if got := p.AllowsOrigin(origin); got != want {
+			t.Errorf("AllowsOrigin(%q) = %v, want %v", origin, got, want)
+		}
+	}
+}
+
+func TestLoadCORSConfigFileAndEnv(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cors.json")
+	os.WriteFile(file, []byte(`{
+		"allowed_origins": ["https://app.example.com"],
+		"max_age": "1h",
+		"routes": [
+			{"path_prefix": "/api/files", "allowed_origins": ["https://uploads.example.com"], "allow_credentials": false}
+		]
+	}`), 0o600)
+
+	t.Setenv("CORS_CONFIG_FILE", file)
+	t.Setenv("CORS_EXPOSED_HEADERS", "X-Request-ID, X-Total-Count")
+
+	cfg, err := LoadCORSConfig()
+	if err != nil {
+		t.Fatalf("LoadCORSConfig failed: %v", err)
+	}
+
+	def := cfg.PolicyFor("/api/posts")
+	if !def.AllowsOrigin("https://app.example.com") || def.MaxAge != time.Hour || !def.AllowCredentials {
+		t.Errorf("Unexpected default policy: %+v", def)
+	}
+	if len(def.ExposedHeaders) != 2 || def.ExposedHeaders[1] != "X-Total-Count" {
+		t.Errorf("Expected exposed headers from the env, got %v", def.ExposedHeaders)
+	}
+
+	files := cfg.PolicyFor("/api/files/upload-url")
+	if !files.AllowsOrigin("https://uploads.example.com") || files.AllowsOrigin("https://app.example.com") || files.AllowCredentials {
+		t.Errorf("Expected the route override to apply, got %+v", files)
+	}
+	if other := cfg.PolicyFor("/api/filesystem"); other.AllowsOrigin("https://uploads.example.com") {
+		t.Error("Expected prefixes to match whole path segments")
+	}
+}
+
+func TestCORSConfigRejectsWildcardWithCredentials(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", "*")
+
+	if _, err := LoadCORSConfig(); err == nil {
+		t.Error("Expected any origin with credentials to be rejected")
+	}
+
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "false")
+	if _, err := LoadCORSConfig(); err != nil {
+		t.Errorf("Expected any origin without credentials to be accepted, got %v", err)
+	}
+}
@@ -8,6 +8,10 @@ import (
 	"strings"
 )
 
+// minSessionSecretLength is the shortest SESSION_SECRET accepted for signing
+// session cookies
+const minSessionSecretLength = 32
+
 // ValidateEnv validates that all required environment variables are set
 func ValidateEnv(requiredVars []string) error {
 	var missing []string
@@ -32,6 +36,9 @@ func ValidateSessionSecret() error {
 	if secret == "" {
 		return errors.New("SESSION_SECRET is required")
 	}
+	if len(secret) < minSessionSecretLength {
+		return fmt.Errorf("SESSION_SECRET must be at least %d characters", minSessionSecretLength)
+	}
 
 	return nil
 }
@@ -0,0 +1,198 @@
+package consul
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+const (
+	// DefaultWatchWaitTime is how long a blocking query waits for changes
+	DefaultWatchWaitTime = 5 * time.Minute
+
+	// initialFetchTimeout bounds how long the first Discover call for a
+	// service waits for the watch to load its instances
+	initialFetchTimeout = 5 * time.Second
+
+	minWatchBackoff = time.Second
+	maxWatchBackoff = 30 * time.Second
+)
+
+// CachedDiscovery is a ServiceDiscovery that serves instances from memory.
+// Each service is watched with Consul blocking queries after its first lookup,
+// so requests never wait on Consul. If Consul becomes unreachable the last
+// known healthy set keeps being served until the watch recovers.
+type CachedDiscovery struct {
+	client   *Client
+	waitTime time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	watches map[string]*serviceWatch
+}
+
+// serviceWatch holds the cached instances of one service
+type serviceWatch struct {
+	mu        sync.RWMutex
+	instances []*ServiceInstance
+	loaded    bool
+	err       error
+
+	ready     chan struct{}
+	readyOnce sync.Once
+}
+
+// NewCachedDiscovery creates a caching discovery on top of a Consul client.
+// A zero waitTime uses DefaultWatchWaitTime. Close stops all watches.
+func NewCachedDiscovery(client *Client, waitTime time.Duration) *CachedDiscovery {
+	if waitTime <= 0 {
+		waitTime = DefaultWatchWaitTime
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &CachedDiscovery{
+		client:   client,
+		waitTime: waitTime,
+		ctx:      ctx,
+		cancel:   cancel,
+		watches:  make(map[string]*serviceWatch),
+	}
+}
+
+// Discover returns the cached healthy instances of a service.
+// The first call for a service starts its watch and waits for the initial load.
+func (d *CachedDiscovery) Discover(serviceName string) ([]*ServiceInstance, error) {
+	w := d.watch(serviceName)
+
+	select {
+	case <-w.ready:
+	case <-time.After(initialFetchTimeout):
+		return nil, fmt.Errorf("timed out discovering service %s", serviceName)
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if !w.loaded {
+		return nil, fmt.Errorf("failed to discover service %s: %w", serviceName, w.err)
+	}
+	if len(w.instances) == 0 {
+		return nil, fmt.Errorf("no healthy instances found for service: %s", serviceName)
+	}
+
+	return w.instances, nil
+}
+
+// DiscoverOne returns a single cached instance using random load balancing
+func (d *CachedDiscovery) DiscoverOne(serviceName string) (*ServiceInstance, error) {
+	instances, err := d.Discover(serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return instances[rand.Intn(len(instances))], nil
+}
+
+// Close stops all watches and waits for them to exit
+func (d *CachedDiscovery) Close() {
+	d.cancel()
+	d.wg.Wait()
+}
+
+// watch returns the watch for a service, starting it on first use
+func (d *CachedDiscovery) watch(serviceName string) *serviceWatch {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	w, ok := d.watches[serviceName]
+	if !ok {
+		w = &serviceWatch{ready: make(chan struct{})}
+		d.watches[serviceName] = w
+
+		d.wg.Add(1)
+		go d.run(serviceName, w)
+	}
+	return w
+}
+
+// run keeps a service's instances up to date with blocking queries
+func (d *CachedDiscovery) run(serviceName string, w *serviceWatch) {
+	defer d.wg.Done()
+
+	var index uint64
+	backoff := minWatchBackoff
+
+	for {
+		opts := (&consulapi.QueryOptions{
+			WaitIndex: index,
+			WaitTime:  d.waitTime,
+		}).WithContext(d.ctx)
+
+		entries, meta, err := d.client.api.Health().Service(serviceName, "", true, opts)
+		if d.ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			w.fail(err)
+			slog.Warn("Consul watch failed, serving cached instances",
+				"service", serviceName,
+				"error", err.Error(),
+				"retry_in", backoff.String(),
+			)
+
+			select {
+			case <-d.ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > maxWatchBackoff {
+				backoff = maxWatchBackoff
+			}
+			continue
+		}
+		backoff = minWatchBackoff
+
+		// Index went backwards (e.g. Consul restarted): start over
+		if meta.LastIndex < index {
+			index = 0
+			continue
+		}
+		if meta.LastIndex == index {
+			// Wait timed out without changes
+			continue
+		}
+		index = meta.LastIndex
+
+		w.update(toInstances(entries))
+	}
+}
+
+// update replaces the cached instances with a fresh set from Consul
+func (w *serviceWatch) update(instances []*ServiceInstance) {
+	w.mu.Lock()
+	w.instances = instances
+	w.loaded = true
+	w.err = nil
+	w.mu.Unlock()
+
+	w.readyOnce.Do(func() { close(w.ready) })
+}
+
+// fail records a Consul error. Cached instances are kept as they are.
+func (w *serviceWatch) fail(err error) {
+	w.mu.Lock()
+	w.err = err
+	w.mu.Unlock()
+
+	w.readyOnce.Do(func() { close(w.ready) })
+}
@@ -0,0 +1,148 @@
+package consul
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+// fakeConsul serves /v1/health/service/<name> from a mutable instance list
+type fakeConsul struct {
+	mu      sync.Mutex
+	index   uint64
+	ports   []int
+	down    bool
+	changed chan struct{}
+}
+
+func (f *fakeConsul) set(ports ...int) {
+	f.mu.Lock()
+	f.index++
+	f.ports = ports
+	close(f.changed)
+	f.changed = make(chan struct{})
+	f.mu.Unlock()
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	down := f.down
+	changed := f.changed
+	index := f.index
+	f.mu.Unlock()
+
+	if down {
+		http.Error(w, "consul unavailable", http.StatusInternalServerError)
+		return
+	}
+
+	// Emulate a blocking query: wait until the index moves past the client's
+	if wait, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64); wait >= index {
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+
+	f.mu.Lock()
+	entries := make([]*consulapi.ServiceEntry, 0, len(f.ports))
+	for _, port := range f.ports {
+		entries = append(entries, &consulapi.ServiceEntry{
+			Node:    &consulapi.Node{Address: "10.0.0.1"},
+			Service: &consulapi.AgentService{ID: "svc-" + strconv.Itoa(port), Service: "svc", Port: port},
+		})
+	}
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(f.index, 10))
+	f.mu.Unlock()
+
+	json.NewEncoder(w).Encode(entries)
+}
+
+func newTestCachedDiscovery(t *testing.T, fake *fakeConsul) *CachedDiscovery {
+	t.Helper()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client, err := NewClientWithToken(server.Listener.Addr().String(), "")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	d := NewCachedDiscovery(client, time.Second)
+	t.Cleanup(d.Close)
+	return d
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Condition not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCachedDiscovery_FollowsChanges(t *testing.T) {
+	fake := &fakeConsul{changed: make(chan struct{})}
+	fake.set(8001)
+	d := newTestCachedDiscovery(t, fake)
+
+	instances, err := d.Discover("svc")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(instances) != 1 || instances[0].Port != 8001 || instances[0].Address != "10.0.0.1" {
+		t.Fatalf("Unexpected instances: %+v", instances)
+	}
+
+	fake.set(8001, 8002)
+	waitFor(t, func() bool {
+		instances, _ := d.Discover("svc")
+		return len(instances) == 2
+	})
+}
+
+func TestCachedDiscovery_ServesLastKnownSetWhenConsulIsDown(t *testing.T) {
+	fake := &fakeConsul{changed: make(chan struct{})}
+	fake.set(8001)
+	d := newTestCachedDiscovery(t, fake)
+
+	if _, err := d.Discover("svc"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	fake.mu.Lock()
+	fake.down = true
+	fake.mu.Unlock()
+	fake.set(8001)
+
+	// Give the watch time to hit the error
+	time.Sleep(300 * time.Millisecond)
+
+	instance, err := d.DiscoverOne("svc")
+	if err != nil {
+		t.Fatalf("Expected cached instance while Consul is down, got %v", err)
+	}
+	if instance.Port != 8001 {
+		t.Errorf("Expected port 8001, got %d", instance.Port)
+	}
+}
+
+func TestCachedDiscovery_InitialFailure(t *testing.T) {
+	fake := &fakeConsul{changed: make(chan struct{}), down: true}
+	d := newTestCachedDiscovery(t, fake)
+
+	if _, err := d.Discover("svc"); err == nil {
+		t.Error("Expected error when Consul was never reachable")
+	}
+}
@@ -3,6 +3,8 @@ package consul
 import (
 	"fmt"
 	"math/rand"
+
+	consulapi "github.com/hashicorp/consul/api"
 )
 
 // ServiceInstance represents a discovered service instance
@@ -32,7 +34,11 @@ func (c *Client) Discover(serviceName string) ([]*ServiceInstance, error) {
 		return nil, fmt.Errorf("no healthy instances found for service: %s", serviceName)
 	}
 
-	// Convert to ServiceInstance slice
+	return toInstances(services), nil
+}
+
+// toInstances converts Consul health entries to service instances
+func toInstances(services []*consulapi.ServiceEntry) []*ServiceInstance {
 	instances := make([]*ServiceInstance, 0, len(services))
 	for _, entry := range services {
 		instance := &ServiceInstance{
@@ -51,7 +57,7 @@ func (c *Client) Discover(serviceName string) ([]*ServiceInstance, error) {
 		instances = append(instances, instance)
 	}
 
-	return instances, nil
+	return instances
 }
 
 // DiscoverOne retrieves a single healthy instance using random load balancing
@@ -0,0 +1,87 @@
+package consul
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+// GetKey returns the value of a KV key and its modify index.
+// A missing key returns a nil value and no error.
+func (c *Client) GetKey(key string) ([]byte, uint64, error) {
+	pair, _, err := c.api.KV().Get(key, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if pair == nil {
+		return nil, 0, nil
+	}
+	return pair.Value, pair.ModifyIndex, nil
+}
+
+// WatchKey blocks until ctx is done, calling fn with the new value of key
+// every time it changes after the first read. It uses blocking queries like
+// CachedDiscovery and retries with backoff while Consul is unreachable.
+// Deleting the key is not reported.
+func (c *Client) WatchKey(ctx context.Context, key string, fn func([]byte)) {
+	var index, modified uint64
+	first := true
+	backoff := minWatchBackoff
+
+	for {
+		opts := (&consulapi.QueryOptions{
+			WaitIndex: index,
+			WaitTime:  DefaultWatchWaitTime,
+		}).WithContext(ctx)
+
+		pair, meta, err := c.api.KV().Get(key, opts)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			slog.Warn("Consul key watch failed",
+				"key", key,
+				"error", err.Error(),
+				"retry_in", backoff.String(),
+			)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > maxWatchBackoff {
+				backoff = maxWatchBackoff
+			}
+			continue
+		}
+		backoff = minWatchBackoff
+
+		// Index went backwards (e.g. Consul restarted): start over
+		if meta.LastIndex < index {
+			index = 0
+			continue
+		}
+		if meta.LastIndex == index {
+			continue
+		}
+		index = meta.LastIndex
+
+		// The index can move for writes to other keys; only report the key's own
+		changed := pair != nil && pair.ModifyIndex != modified
+		if pair != nil {
+			modified = pair.ModifyIndex
+		}
+		if first {
+			first = false
+			continue
+		}
+		if changed {
+			fn(pair.Value)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package database
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config describes how to connect to Postgres and size the connection pool
+type Config struct {
+	Host     string
+	Port     string
+	Database string
+	Username string
+	Password string
+	// Schema is set as the search_path of every connection when not empty
+	Schema string
+
+	// SSLMode is the libpq sslmode: disable, prefer, require, verify-ca or verify-full
+	SSLMode string
+
+	// MaxConns is the maximum number of connections in the pool
+	MaxConns int32
+	// MinConns is the number of connections kept open even when idle
+	MinConns int32
+	// MaxConnLifetime closes connections older than this, so load spreads
+	// again after a failover or a new replica
+	MaxConnLifetime time.Duration
+	// MaxConnIdleTime closes connections idle for longer than this
+	MaxConnIdleTime time.Duration
+
+	// StatementTimeout aborts any single statement running longer than this; 0 disables it
+	StatementTimeout time.Duration
+	// ApplicationName shows up in pg_stat_activity
+	ApplicationName string
+
+	// ReplicaDSNs are connection URLs of read replicas. Pool and session
+	// settings are the same as for the primary.
+	ReplicaDSNs []string
+	// MaxReplicaLag takes a replica out of rotation while it is further behind
+	// the primary than this. It is also the read-your-writes window.
+	MaxReplicaLag time.Duration
+	// ReplicaCheckInterval is how often replica health and lag are checked
+	ReplicaCheckInterval time.Duration
+}
+
+// DefaultConfig returns pool settings suitable for a single service instance
+func DefaultConfig() Config {
+	return Config{
+		Port:             "5432",
+		SSLMode:          "require",
+		MaxConns:         20,
+		MinConns:         2,
+		MaxConnLifetime:  time.Hour,
+		MaxConnIdleTime:  30 * time.Minute,
+		StatementTimeout: 30 * time.Second,
+		ApplicationName:  filepath.Base(os.Args[0]),
+
+		MaxReplicaLag:        5 * time.Second,
+		ReplicaCheckInterval: 5 * time.Second,
+	}
+}
+
+// ConfigFromEnv builds a Config from DB_HOST, DB_PORT, DB_DATABASE, DB_USERNAME,
+// DB_PASSWORD, DB_SCHEMA, DB_SSLMODE, DB_MAX_CONNS, DB_MIN_CONNS,
+// DB_MAX_CONN_LIFETIME, DB_MAX_CONN_IDLE_TIME, DB_STATEMENT_TIMEOUT,
+// DB_APPLICATION_NAME, DB_REPLICA_DSNS (comma-separated), DB_MAX_REPLICA_LAG
+// and DB_REPLICA_CHECK_INTERVAL. Unset variables keep the DefaultConfig values.
+func ConfigFromEnv() (Config, error) {
+	cfg := DefaultConfig()
+
+	strs := []struct {
+		env   string
+		value *string
+	}{
+		{"DB_HOST", &cfg.Host},
+		{"DB_PORT", &cfg.Port},
+		{"DB_DATABASE", &cfg.Database},
+		{"DB_USERNAME", &cfg.Username},
+		{"DB_PASSWORD", &cfg.Password},
+		{"DB_SCHEMA", &cfg.Schema},
+		{"DB_SSLMODE", &cfg.SSLMode},
+		{"DB_APPLICATION_NAME", &cfg.ApplicationName},
+	}
+	for _, s := range strs {
+		if v := os.Getenv(s.env); v != "" {
+			*s.value = v
+		}
+	}
+
+	ints := []struct {
+		env   string
+		value *int32
+	}{
+		{"DB_MAX_CONNS", &cfg.MaxConns},
+		{"DB_MIN_CONNS", &cfg.MinConns},
+	}
+	for _, n := range ints {
+		v := os.Getenv(n.env)
+		if v == "" {
+			continue
+		}
+		parsed, err := strconv.ParseInt(v, 10, 32)
+		if err != nil || parsed < 0 {
+			return cfg, fmt.Errorf("%s: invalid value %q", n.env, v)
+		}
+		*n.value = int32(parsed)
+	}
+
+	durations := []struct {
+		env   string
+		value *time.Duration
+	}{
+		{"DB_MAX_CONN_LIFETIME", &cfg.MaxConnLifetime},
+		{"DB_MAX_CONN_IDLE_TIME", &cfg.MaxConnIdleTime},
+		{"DB_STATEMENT_TIMEOUT", &cfg.StatementTimeout},
+		{"DB_MAX_REPLICA_LAG", &cfg.MaxReplicaLag},
+		{"DB_REPLICA_CHECK_INTERVAL", &cfg.ReplicaCheckInterval},
+	}
+	for _, d := range durations {
+		v := os.Getenv(d.env)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed < 0 {
+			return cfg, fmt.Errorf("%s: invalid duration %q", d.env, v)
+		}
+		*d.value = parsed
+	}
+
+	for _, dsn := range strings.Split(os.Getenv("DB_REPLICA_DSNS"), ",") {
+		if dsn = strings.TrimSpace(dsn); dsn != "" {
+			cfg.ReplicaDSNs = append(cfg.ReplicaDSNs, dsn)
+		}
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Validate checks that the config can be used to open a pool
+func (c Config) Validate() error {
+	if c.Host == "" {
+		return fmt.Errorf("database host is required")
+	}
+	if c.Database == "" {
+		return fmt.Errorf("database name is required")
+	}
+
+	switch c.SSLMode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		return fmt.Errorf("invalid sslmode %q", c.SSLMode)
+	}
+
+	if c.MaxConns < 1 {
+		return fmt.Errorf("max conns must be at least 1")
+	}
+	if c.MinConns > c.MaxConns {
+		return fmt.Errorf("min conns (%d) exceeds max conns (%d)", c.MinConns, c.MaxConns)
+	}
+	if len(c.ReplicaDSNs) > 0 && c.ReplicaCheckInterval <= 0 {
+		return fmt.Errorf("replica check interval must be positive")
+	}
+	return nil
+}
+
+// DSN returns the connection URL. Pool settings are applied separately.
+func (c Config) DSN() string {
+	u := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(c.Username, c.Password),
+		Host:   net.JoinHostPort(c.Host, c.Port),
+		Path:   "/" + c.Database,
+	}
+
+	q := url.Values{}
+	q.Set("sslmode", c.SSLMode)
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("DB_HOST", "localhost")
+	t.Setenv("DB_DATABASE", "instant")
+	t.Setenv("DB_USERNAME", "app")
+	t.Setenv("DB_PASSWORD", "p@ss/word")
+	t.Setenv("DB_SSLMODE", "disable")
+	t.Setenv("DB_MAX_CONNS", "50")
+	t.Setenv("DB_STATEMENT_TIMEOUT", "5s")
+
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.MaxConns != 50 || cfg.StatementTimeout != 5*time.Second {
+		t.Errorf("Pool settings not read from env: %+v", cfg)
+	}
+	if cfg.MinConns != DefaultConfig().MinConns {
+		t.Errorf("Expected default min conns, got %d", cfg.MinConns)
+	}
+
+	u, err := url.Parse(cfg.DSN())
+	if err != nil {
+		t.Fatalf("DSN is not a valid URL: %v", err)
+	}
+	if password, _ := u.User.Password(); password != "p@ss/word" {
+		t.Errorf("Password not escaped correctly, got %q", password)
+	}
+	if u.Query().Get("sslmode") != "disable" {
+		t.Errorf("Expected sslmode=disable, got %q", u.Query().Get("sslmode"))
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Host, cfg.Database = "localhost", "instant"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected default config to be valid: %v", err)
+	}
+
+	bad := cfg
+	bad.SSLMode = "sometimes"
+	if err := bad.Validate(); err == nil {
+		t.Error("Expected error for invalid sslmode")
+	}
+
+	bad = cfg
+	bad.MinConns = bad.MaxConns + 1
+	if err := bad.Validate(); err == nil {
+		t.Error("Expected error when min conns exceed max conns")
+	}
+}
@@ -8,12 +8,18 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
-	"os"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"instant/internal/metrics"
+	"instant/internal/tracing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
 	_ "github.com/joho/godotenv/autoload"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Service represents a service that interacts with a database.
@@ -26,46 +32,148 @@ type Service interface {
 	// It returns an error if the connection cannot be closed.
 	Close() error
 
-	// QueryRow executes a query that returns a single row
-	QueryRow(ctx context.Context, query string, args ...interface{}) *sql.Row
+	// QueryRow, Query and Exec run directly on the connection pool
+	Querier
+
+	// WithTx runs fn in a transaction, see TxOptions.
+	// A nil opts uses the server's default isolation level.
+	WithTx(ctx context.Context, opts *TxOptions, fn func(tx Tx) error) error
 
-	// Query executes a query that returns multiple rows
-	Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	// DB returns a database/sql handle on the pool for tools that need a
+	// dedicated connection, such as the migration runner
+	DB() *sql.DB
 
-	// Exec executes a query without returning rows (INSERT, UPDATE, DELETE)
-	Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	// Pool returns the pgx connection pool for code using pgx directly
+	Pool() *pgxpool.Pool
+
+	// WriteTracker pins a user's reads to the primary right after they write,
+	// so they see their own changes before replicas catch up
+	WriteTracker() *WriteTracker
 }
 
 type service struct {
-	db *sql.DB
+	pool   *pgxpool.Pool
+	db     *sql.DB
+	config Config
+
+	replicas []*replica
+	next     atomic.Uint64
+	writes   *WriteTracker
+
+	stopReplicas context.CancelFunc
+	wg           sync.WaitGroup
 }
 
 var (
-	database   = os.Getenv("DB_DATABASE")
-	password   = os.Getenv("DB_PASSWORD")
-	username   = os.Getenv("DB_USERNAME")
-	port       = os.Getenv("DB_PORT")
-	host       = os.Getenv("DB_HOST")
-	schema     = os.Getenv("DB_SCHEMA")
 	dbInstance *service
+	dbMu       sync.Mutex
 )
 
+// New returns the process-wide database service configured from the DB_*
+// environment variables (see ConfigFromEnv), creating it on first use.
+// It exits the process if the configuration is invalid.
 func New() Service {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+
 	// Reuse Connection
 	if dbInstance != nil {
 		return dbInstance
 	}
-	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=require&search_path=%s", username, password, host, port, database, schema)
-	db, err := sql.Open("pgx", connStr)
+
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid database configuration: %v", err)
+	}
+
+	svc, err := NewWithConfig(cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
-	dbInstance = &service{
-		db: db,
-	}
+	dbInstance = svc.(*service)
 	return dbInstance
 }
 
+// NewWithConfig creates a database service backed by a pgx connection pool.
+// Connections are opened lazily, except for MinConns which are opened in the background.
+func NewWithConfig(cfg Config) (Service, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid database config: %w", err)
+	}
+
+	pool, err := newPool(cfg.DSN(), cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &service{
+		pool: pool,
+		// database/sql view of the same pool; it keeps no connections of its own
+		db:     stdlib.OpenDBFromPool(pool),
+		config: cfg,
+		writes: NewWriteTracker(cfg.MaxReplicaLag),
+	}
+
+	for i, dsn := range cfg.ReplicaDSNs {
+		r, err := newReplica("replica-"+strconv.Itoa(i), dsn, cfg)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		s.replicas = append(s.replicas, r)
+	}
+
+	metrics.RegisterDBPool("primary", s.pool)
+	for _, r := range s.replicas {
+		metrics.RegisterDBPool(r.name, r.pool)
+	}
+
+	// Replicas start out of rotation until their first health check passes
+	if len(s.replicas) > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		s.stopReplicas = cancel
+		s.wg.Add(1)
+		go s.watchReplicas(ctx)
+	}
+
+	return s, nil
+}
+
+// newPool creates a pgx pool for dsn with the pool and session settings from cfg
+func newPool(dsn string, cfg Config) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database config: %w", err)
+	}
+
+	poolConfig.MaxConns = cfg.MaxConns
+	poolConfig.MinConns = cfg.MinConns
+	if cfg.MaxConnLifetime > 0 {
+		poolConfig.MaxConnLifetime = cfg.MaxConnLifetime
+	}
+	if cfg.MaxConnIdleTime > 0 {
+		poolConfig.MaxConnIdleTime = cfg.MaxConnIdleTime
+	}
+
+	// Session settings sent on every new connection
+	params := poolConfig.ConnConfig.RuntimeParams
+	if cfg.Schema != "" {
+		params["search_path"] = cfg.Schema
+	}
+	if cfg.StatementTimeout > 0 {
+		params["statement_timeout"] = strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
+	}
+	if cfg.ApplicationName != "" {
+		params["application_name"] = cfg.ApplicationName
+	}
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create connection pool: %w", err)
+	}
+	return pool, nil
+}
+
 // Health checks the health of the database connection by pinging the database.
 // It returns a map with keys indicating various health statistics.
 func (s *service) Health() map[string]string {
@@ -75,7 +183,7 @@ func (s *service) Health() map[string]string {
 	stats := make(map[string]string)
 
 	// Ping the database
-	err := s.db.PingContext(ctx)
+	err := s.pool.Ping(ctx)
 	if err != nil {
 		stats["status"] = "down"
 		stats["error"] = fmt.Sprintf("db down: %v", err)
@@ -87,31 +195,37 @@ func (s *service) Health() map[string]string {
 	stats["status"] = "up"
 	stats["message"] = "It's healthy"
 
-	// Get database stats (like open connections, in use, idle, etc.)
-	dbStats := s.db.Stats()
-	stats["open_connections"] = strconv.Itoa(dbStats.OpenConnections)
-	stats["in_use"] = strconv.Itoa(dbStats.InUse)
-	stats["idle"] = strconv.Itoa(dbStats.Idle)
-	stats["wait_count"] = strconv.FormatInt(dbStats.WaitCount, 10)
-	stats["wait_duration"] = dbStats.WaitDuration.String()
-	stats["max_idle_closed"] = strconv.FormatInt(dbStats.MaxIdleClosed, 10)
-	stats["max_lifetime_closed"] = strconv.FormatInt(dbStats.MaxLifetimeClosed, 10)
+	// Get pool stats (like open connections, in use, idle, etc.)
+	poolStats := s.pool.Stat()
+	stats["total_conns"] = strconv.Itoa(int(poolStats.TotalConns()))
+	stats["max_conns"] = strconv.Itoa(int(poolStats.MaxConns()))
+	stats["acquired_conns"] = strconv.Itoa(int(poolStats.AcquiredConns()))
+	stats["idle_conns"] = strconv.Itoa(int(poolStats.IdleConns()))
+	stats["constructing_conns"] = strconv.Itoa(int(poolStats.ConstructingConns()))
+	stats["acquire_count"] = strconv.FormatInt(poolStats.AcquireCount(), 10)
+	stats["acquire_duration"] = poolStats.AcquireDuration().String()
+	stats["empty_acquire_count"] = strconv.FormatInt(poolStats.EmptyAcquireCount(), 10)
+	stats["canceled_acquire_count"] = strconv.FormatInt(poolStats.CanceledAcquireCount(), 10)
+	stats["new_conns_count"] = strconv.FormatInt(poolStats.NewConnsCount(), 10)
+	stats["max_lifetime_destroy_count"] = strconv.FormatInt(poolStats.MaxLifetimeDestroyCount(), 10)
+	stats["max_idle_destroy_count"] = strconv.FormatInt(poolStats.MaxIdleDestroyCount(), 10)
 
 	// Evaluate stats to provide a health message
-	if dbStats.OpenConnections > 40 { // Assuming 50 is the max for this example
+	if poolStats.AcquiredConns() >= poolStats.MaxConns()*4/5 {
 		stats["message"] = "The database is experiencing heavy load."
 	}
 
-	if dbStats.WaitCount > 1000 {
-		stats["message"] = "The database has a high number of wait events, indicating potential bottlenecks."
+	// Requests had to wait for a connection for a significant share of acquires
+	if poolStats.AcquireCount() > 0 && poolStats.EmptyAcquireCount()*10 > poolStats.AcquireCount() {
+		stats["message"] = "Many requests wait for a free connection, consider increasing DB_MAX_CONNS."
 	}
 
-	if dbStats.MaxIdleClosed > int64(dbStats.OpenConnections)/2 {
-		stats["message"] = "Many idle connections are being closed, consider revising the connection pool settings."
+	if poolStats.MaxLifetimeDestroyCount() > int64(poolStats.TotalConns())*10 {
+		stats["message"] = "Many connections are being closed due to max lifetime, consider increasing DB_MAX_CONN_LIFETIME."
 	}
 
-	if dbStats.MaxLifetimeClosed > int64(dbStats.OpenConnections)/2 {
-		stats["message"] = "Many connections are being closed due to max lifetime, consider increasing max lifetime or revising the connection usage pattern."
+	if len(s.replicas) > 0 {
+		s.replicaHealth(stats)
 	}
 
 	return stats
@@ -122,21 +236,76 @@ func (s *service) Health() map[string]string {
 // If the connection is successfully closed, it returns nil.
 // If an error occurs while closing the connection, it returns the error.
 func (s *service) Close() error {
-	log.Printf("Disconnected from database: %s", database)
-	return s.db.Close()
+	log.Printf("Disconnected from database: %s", s.config.Database)
+	if s.stopReplicas != nil {
+		s.stopReplicas()
+		s.wg.Wait()
+	}
+	for _, r := range s.replicas {
+		metrics.UnregisterDBPool(r.name)
+		r.close()
+	}
+	metrics.UnregisterDBPool("primary")
+
+	err := s.db.Close()
+	s.pool.Close()
+	return err
 }
 
-// QueryRow executes a query that returns a single row
+// QueryRow executes a query that returns a single row.
+// Plain SELECTs run on a healthy replica, falling back to the primary if the
+// replica cannot be reached, as Query does.
 func (s *service) QueryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
-	return s.db.QueryRowContext(ctx, query, args...)
+	db, r := s.reader(ctx, query)
+	ctx, span := startSpan(ctx, query, targetName(r))
+
+	row := db.QueryRowContext(ctx, query, args...)
+	if err := row.Err(); err != nil && r != nil && ctx.Err() == nil && isConnectionError(err) {
+		r.markDown(err)
+		span.SetAttributes(attribute.String("db.target", targetName(nil)))
+		row = s.db.QueryRowContext(ctx, query, args...)
+	}
+	tracing.End(span, row.Err())
+	return row
 }
 
-// Query executes a query that returns multiple rows
-func (s *service) Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
-	return s.db.QueryContext(ctx, query, args...)
+// Query executes a query that returns multiple rows.
+// Plain SELECTs run on a healthy replica, falling back to the primary if the
+// replica cannot be reached. SQL errors are returned as they are.
+func (s *service) Query(ctx context.Context, query string, args ...interface{}) (rows *sql.Rows, err error) {
+	db, r := s.reader(ctx, query)
+	ctx, span := startSpan(ctx, query, targetName(r))
+	defer func() { tracing.End(span, err) }()
+
+	rows, err = db.QueryContext(ctx, query, args...)
+	if err != nil && r != nil && ctx.Err() == nil && isConnectionError(err) {
+		r.markDown(err)
+		span.SetAttributes(attribute.String("db.target", targetName(nil)))
+		return s.db.QueryContext(ctx, query, args...)
+	}
+	return rows, err
 }
 
-// Exec executes a query without returning rows (INSERT, UPDATE, DELETE)
+// Exec executes a query without returning rows (INSERT, UPDATE, DELETE).
+// It always runs on the primary.
 func (s *service) Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
-	return s.db.ExecContext(ctx, query, args...)
+	ctx, span := startSpan(ctx, query, targetName(nil))
+	result, err := s.db.ExecContext(ctx, query, args...)
+	tracing.End(span, err)
+	return result, err
+}
+
+// WriteTracker returns the read-your-writes tracker, whose window is MaxReplicaLag
+func (s *service) WriteTracker() *WriteTracker {
+	return s.writes
+}
+
+// DB returns a database/sql handle on the connection pool
+func (s *service) DB() *sql.DB {
+	return s.db
+}
+
+// Pool returns the pgx connection pool
+func (s *service) Pool() *pgxpool.Pool {
+	return s.pool
 }
@@ -0,0 +1,279 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// replicaLagQuery returns how far behind the primary a replica is, in seconds.
+// A replica that has replayed everything it received reports 0, so an idle
+// primary does not make its replicas look lagging.
+const replicaLagQuery = `
+	SELECT CASE
+		WHEN NOT pg_is_in_recovery() THEN 0
+		WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+	END`
+
+// replica is a read-only connection pool with its last known health
+type replica struct {
+	name string
+	pool *pgxpool.Pool
+	db   *sql.DB
+
+	healthy atomic.Bool
+	// lag is the last measured replication lag in milliseconds
+	lag atomic.Int64
+	err atomic.Value // string
+}
+
+func newReplica(name, dsn string, cfg Config) (*replica, error) {
+	pool, err := newPool(dsn, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("replica %s: %w", name, err)
+	}
+	return &replica{name: name, pool: pool, db: stdlib.OpenDBFromPool(pool)}, nil
+}
+
+// check measures the replica's lag and marks it healthy if it answers and
+// is no further behind than maxLag
+func (r *replica) check(ctx context.Context, maxLag time.Duration) {
+	var seconds float64
+	if err := r.db.QueryRowContext(ctx, replicaLagQuery).Scan(&seconds); err != nil {
+		r.markDown(err)
+		return
+	}
+
+	lag := time.Duration(seconds * float64(time.Second))
+	r.lag.Store(lag.Milliseconds())
+	if lag > maxLag {
+		r.markDown(fmt.Errorf("replication lag %s exceeds %s", lag.Round(time.Millisecond), maxLag))
+		return
+	}
+
+	if !r.healthy.Swap(true) {
+		log.Printf("Read replica %s is healthy (lag %s)", r.name, lag.Round(time.Millisecond))
+	}
+	r.err.Store("")
+}
+
+func (r *replica) markDown(err error) {
+	if r.healthy.Swap(false) {
+		log.Printf("Read replica %s taken out of rotation: %v", r.name, err)
+	}
+	r.err.Store(err.Error())
+}
+
+// isConnectionError reports whether err means the server could not be
+// reached or dropped the connection, as opposed to an error in the query
+func isConnectionError(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, net.ErrClosed) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) {
+		return true
+	}
+
+	// Class 08 is connection exceptions, 57P01-57P03 a shutting down server
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return strings.HasPrefix(pgErr.Code, "08") || strings.HasPrefix(pgErr.Code, "57P")
+	}
+	return pgconn.SafeToRetry(err)
+}
+
+func (r *replica) close() {
+	r.db.Close()
+	r.pool.Close()
+}
+
+// watchReplicas checks every replica until ctx is cancelled
+func (s *service) watchReplicas(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.config.ReplicaCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, r := range s.replicas {
+			checkCtx, cancel := context.WithTimeout(ctx, s.config.ReplicaCheckInterval)
+			r.check(checkCtx, s.config.MaxReplicaLag)
+			cancel()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reader returns where a query should run: a healthy replica for plain reads,
+// the primary for writes, locking reads and contexts forced to the primary
+func (s *service) reader(ctx context.Context, query string) (*sql.DB, *replica) {
+	if len(s.replicas) == 0 || IsPrimary(ctx) || !isReadQuery(query) {
+		return s.db, nil
+	}
+
+	// Round-robin over healthy replicas, starting at a different one each time
+	start := s.next.Add(1)
+	for i := range s.replicas {
+		r := s.replicas[(start+uint64(i))%uint64(len(s.replicas))]
+		if r.healthy.Load() {
+			return r.db, r
+		}
+	}
+	return s.db, nil
+}
+
+// targetName names where a query ran for tracing: a replica or the primary
+func targetName(r *replica) string {
+	if r == nil {
+		return "primary"
+	}
+	return r.name
+}
+
+// isReadQuery reports whether a statement only reads data and can run on a replica
+func isReadQuery(query string) bool {
+	q := strings.ToLower(stripLeadingComments(query))
+
+	if !strings.HasPrefix(q, "select") && !strings.HasPrefix(q, "with") {
+		return false
+	}
+
+	// Locking reads, data-modifying CTEs and sequence calls need the primary
+	for _, marker := range []string{" for update", " for no key update", " for share", " for key share",
+		"insert ", "update ", "delete ", "nextval(", "setval("} {
+		if strings.Contains(q, marker) {
+			return false
+		}
+	}
+	return true
+}
+
+func stripLeadingComments(query string) string {
+	q := strings.TrimSpace(query)
+	for strings.HasPrefix(q, "--") {
+		end := strings.IndexByte(q, '\n')
+		if end < 0 {
+			return ""
+		}
+		q = strings.TrimSpace(q[end+1:])
+	}
+	return q
+}
+
+// replicaHealth adds per-replica entries to a Health map
+func (s *service) replicaHealth(stats map[string]string) {
+	healthy := 0
+	for i, r := range s.replicas {
+		prefix := "replica_" + strconv.Itoa(i) + "_"
+		status := "down"
+		if r.healthy.Load() {
+			status = "up"
+			healthy++
+		}
+		stats[prefix+"status"] = status
+		stats[prefix+"lag"] = (time.Duration(r.lag.Load()) * time.Millisecond).String()
+		if msg, _ := r.err.Load().(string); msg != "" {
+			stats[prefix+"error"] = msg
+		}
+		stats[prefix+"total_conns"] = strconv.Itoa(int(r.pool.Stat().TotalConns()))
+	}
+	stats["replicas_healthy"] = fmt.Sprintf("%d/%d", healthy, len(s.replicas))
+}
+
+type primaryKey struct{}
+
+// ForcePrimary returns a context whose queries all run on the primary,
+// e.g. to read data the same request has just written
+func ForcePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// IsPrimary reports whether ctx is pinned to the primary, by ForcePrimary or
+// a WriteTracker. Callers with their own caches should bypass them too, since
+// a cached copy may predate the write.
+func IsPrimary(ctx context.Context) bool {
+	forced, _ := ctx.Value(primaryKey{}).(bool)
+	return forced
+}
+
+// WriteTracker remembers who wrote recently, so their reads can go to the
+// primary until replicas have caught up (read-your-writes). Keys are usually
+// user IDs. State is per process: a write seen by one instance does not pin
+// reads served by another, so services using it must be consistent-hash
+// balanced by the gateway to keep a user's requests on the same instance.
+type WriteTracker struct {
+	window time.Duration
+
+	mu     sync.Mutex
+	writes map[string]time.Time
+}
+
+// NewWriteTracker creates a tracker that pins reads to the primary for window after a write
+func NewWriteTracker(window time.Duration) *WriteTracker {
+	return &WriteTracker{window: window, writes: make(map[string]time.Time)}
+}
+
+// MarkWrite records that key has just written
+func (t *WriteTracker) MarkWrite(key string) {
+	if key == "" {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.writes[key] = now
+
+	// Drop expired entries now and then so the map stays small
+	if len(t.writes) > 1024 {
+		for k, at := range t.writes {
+			if now.Sub(at) > t.window {
+				delete(t.writes, k)
+			}
+		}
+	}
+}
+
+// Context returns ctx forced to the primary if key wrote within the window
+func (t *WriteTracker) Context(ctx context.Context, key string) context.Context {
+	if key == "" {
+		return ctx
+	}
+
+	t.mu.Lock()
+	at, ok := t.writes[key]
+	t.mu.Unlock()
+
+	if ok && time.Since(at) <= t.window {
+		return ForcePrimary(ctx)
+	}
+	return ctx
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestIsReadQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		read  bool
+	}{
+		{"SELECT id FROM posts WHERE id = $1", true},
+		{"\n\t\tselect count(*) from likes", true},
+		{"-- newest first\nSELECT * FROM posts ORDER BY created_at DESC", true},
+		{"WITH recent AS (SELECT * FROM posts) SELECT * FROM recent", true},
+		{"SELECT email FROM users WHERE id = $1 FOR UPDATE", false},
+		{"SELECT * FROM posts WHERE id = $1 FOR SHARE", false},
+		{"WITH moved AS (DELETE FROM posts RETURNING *) SELECT * FROM moved", false},
+		{"SELECT nextval('posts_id_seq')", false},
+		{"INSERT INTO likes (user_id, post_id) VALUES ($1, $2)", false},
+		{"UPDATE posts SET caption = $1", false},
+	}
+
+	for _, tt := range tests {
+		if got := isReadQuery(tt.query); got != tt.read {
+			t.Errorf("isReadQuery(%q) = %v, want %v", tt.query, got, tt.read)
+		}
+	}
+}
+
+func TestIsConnectionError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"bad connection", driver.ErrBadConn, true},
+		{"connection done", sql.ErrConnDone, true},
+		{"closed connection", fmt.Errorf("read: %w", net.ErrClosed), true},
+		{"unexpected eof", io.ErrUnexpectedEOF, true},
+		{"dial failure", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, true},
+		{"admin shutdown", &pgconn.PgError{Code: "57P01"}, true},
+		{"connection failure", &pgconn.PgError{Code: "08006"}, true},
+		{"undefined column", &pgconn.PgError{Code: "42703"}, false},
+		{"invalid input syntax", &pgconn.PgError{Code: "22P02"}, false},
+		{"no rows", sql.ErrNoRows, false},
+	}
+
+	for _, tt := range tests {
+		if got := isConnectionError(tt.err); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestWriteTracker(t *testing.T) {
+	tracker := NewWriteTracker(50 * time.Millisecond)
+	ctx := context.Background()
+
+	if IsPrimary(tracker.Context(ctx, "alice")) {
+		t.Fatal("Expected replica reads before any write")
+	}
+
+	tracker.MarkWrite("alice")
+	if !IsPrimary(tracker.Context(ctx, "alice")) {
+		t.Error("Expected primary reads right after a write")
+	}
+	if IsPrimary(tracker.Context(ctx, "bob")) {
+		t.Error("Expected other users to keep reading from replicas")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if IsPrimary(tracker.Context(ctx, "alice")) {
+		t.Error("Expected replica reads after the window")
+	}
+}
@@ -0,0 +1,34 @@
+package database
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("instant/internal/database")
+
+// startSpan starts a client span for one statement run on target (primary
+// or a replica name). Only the query text is recorded, never the arguments.
+func startSpan(ctx context.Context, query, target string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, operation(query),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNamePostgreSQL,
+			semconv.DBQueryText(strings.TrimSpace(query)),
+			attribute.String("db.target", target),
+		))
+}
+
+// operation returns the statement's leading keyword, e.g. SELECT, as the span name
+func operation(query string) string {
+	fields := strings.Fields(stripLeadingComments(query))
+	if len(fields) == 0 {
+		return "query"
+	}
+	return strings.ToUpper(fields[0])
+}
@@ -0,0 +1,170 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"instant/internal/tracing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultTxRetries is how many times a transaction is retried after a
+// serialization failure or deadlock when TxOptions.MaxRetries is zero
+const DefaultTxRetries = 3
+
+// Querier runs queries. It is implemented by both Service (the connection
+// pool) and Tx, so repositories can work inside or outside a transaction.
+type Querier interface {
+	// QueryRow executes a query that returns a single row
+	QueryRow(ctx context.Context, query string, args ...interface{}) *sql.Row
+
+	// Query executes a query that returns multiple rows
+	Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+
+	// Exec executes a query without returning rows (INSERT, UPDATE, DELETE)
+	Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Tx is a database transaction started by Service.WithTx
+type Tx interface {
+	Querier
+}
+
+// TxOptions configures a transaction started by WithTx
+type TxOptions struct {
+	// Isolation is the isolation level; sql.LevelDefault uses the server
+	// default (READ COMMITTED in Postgres)
+	Isolation sql.IsolationLevel
+	// ReadOnly starts a read-only transaction
+	ReadOnly bool
+	// MaxRetries is how many times the whole transaction is re-run after a
+	// serialization failure or deadlock. 0 uses DefaultTxRetries, a negative
+	// value disables retries.
+	MaxRetries int
+}
+
+// Serializable returns options for a SERIALIZABLE transaction
+func Serializable() *TxOptions {
+	return &TxOptions{Isolation: sql.LevelSerializable}
+}
+
+// RepeatableRead returns options for a REPEATABLE READ transaction
+func RepeatableRead() *TxOptions {
+	return &TxOptions{Isolation: sql.LevelRepeatableRead}
+}
+
+// tx wraps *sql.Tx to implement Tx
+type tx struct {
+	tx *sql.Tx
+}
+
+func (t *tx) QueryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := startSpan(ctx, query, targetName(nil))
+	row := t.tx.QueryRowContext(ctx, query, args...)
+	tracing.End(span, row.Err())
+	return row
+}
+
+func (t *tx) Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := startSpan(ctx, query, targetName(nil))
+	rows, err := t.tx.QueryContext(ctx, query, args...)
+	tracing.End(span, err)
+	return rows, err
+}
+
+func (t *tx) Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startSpan(ctx, query, targetName(nil))
+	result, err := t.tx.ExecContext(ctx, query, args...)
+	tracing.End(span, err)
+	return result, err
+}
+
+// WithTx runs fn in a transaction. The transaction is committed if fn returns
+// nil and rolled back if it returns an error or panics; a panic is re-raised
+// after the rollback. When the transaction fails with a serialization failure
+// or deadlock, fn is run again in a new transaction, so fn must not have side
+// effects outside the database. A nil opts uses the defaults.
+func (s *service) WithTx(ctx context.Context, opts *TxOptions, fn func(tx Tx) error) (err error) {
+	if opts == nil {
+		opts = &TxOptions{}
+	}
+
+	ctx, span := tracer.Start(ctx, "transaction", trace.WithAttributes(
+		semconv.DBSystemNamePostgreSQL,
+		attribute.String("db.isolation", opts.Isolation.String()),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	retries := opts.MaxRetries
+	if retries == 0 {
+		retries = DefaultTxRetries
+	}
+	if retries < 0 {
+		retries = 0
+	}
+
+	for attempt := 0; ; attempt++ {
+		err = s.runTx(ctx, opts, fn)
+		if err == nil || !IsRetryable(err) || attempt >= retries {
+			return err
+		}
+		span.AddEvent("retry", trace.WithAttributes(attribute.Int("attempt", attempt+1)))
+
+		// Back off with jitter so the conflicting transactions don't collide again
+		backoff := time.Duration(10*(1<<attempt)+rand.Intn(10)) * time.Millisecond
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// runTx runs a single transaction attempt
+func (s *service) runTx(ctx context.Context, opts *TxOptions, fn func(tx Tx) error) (err error) {
+	sqlTx, err := s.db.BeginTx(ctx, &sql.TxOptions{
+		Isolation: opts.Isolation,
+		ReadOnly:  opts.ReadOnly,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			sqlTx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(&tx{tx: sqlTx}); err != nil {
+		if rbErr := sqlTx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	if err := sqlTx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// IsRetryable reports whether err is a serialization failure or deadlock,
+// meaning the transaction can succeed if run again
+func IsRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	// 40001 serialization_failure, 40P01 deadlock_detected
+	return pgErr.Code == "40001" || pgErr.Code == "40P01"
+}
@@ -7,7 +7,11 @@ import (
 	"log/slog"
 	"time"
 
+	kafkapkg "instant/internal/kafka"
+	"instant/internal/metrics"
+
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"go.opentelemetry.io/otel/codes"
 )
 
 // Consumer wraps Kafka consumer with email processing logic
@@ -120,8 +124,12 @@ func (c *Consumer) Start(ctx context.Context) error {
 	}
 }
 
-// processMessage processes a single Kafka message
+// processMessage processes a single Kafka message, continuing the trace of
+// the request that produced it
 func (c *Consumer) processMessage(ctx context.Context, msg *kafka.Message) {
+	ctx, span := kafkapkg.StartConsumerSpan(ctx, msg, c.config.ConsumerGroup)
+	defer span.End()
+
 	c.logger.Info("Received email event",
 		"topic", *msg.TopicPartition.Topic,
 		"partition", msg.TopicPartition.Partition,
@@ -134,6 +142,7 @@ func (c *Consumer) processMessage(ctx context.Context, msg *kafka.Message) {
 		c.logger.Error("Failed to parse email event",
 			"error", err,
 			"raw_value", string(msg.Value))
+		metrics.KafkaConsumed(c.config.Topic, metrics.ResultInvalid)
 		c.commitMessage(msg) // Commit to skip bad message
 		return
 	}
@@ -143,6 +152,7 @@ func (c *Consumer) processMessage(ctx context.Context, msg *kafka.Message) {
 		c.logger.Error("Email event missing message_id",
 			"recipient", event.Recipient,
 			"type", event.EventType)
+		metrics.KafkaConsumed(c.config.Topic, metrics.ResultInvalid)
 		c.commitMessage(msg) // Commit to skip invalid message
 		return
 	}
@@ -153,6 +163,7 @@ func (c *Consumer) processMessage(ctx context.Context, msg *kafka.Message) {
 		c.logger.Error("Failed to check idempotency",
 			"messageID", event.MessageID,
 			"error", err)
+		metrics.KafkaConsumed(c.config.Topic, metrics.ResultRetry)
 		// Don't commit - will retry
 		return
 	}
@@ -162,6 +173,7 @@ func (c *Consumer) processMessage(ctx context.Context, msg *kafka.Message) {
 			"messageID", event.MessageID,
 			"recipient", event.Recipient,
 			"type", event.EventType)
+		metrics.KafkaConsumed(c.config.Topic, metrics.ResultDuplicate)
 		c.commitMessage(msg) // Commit - already processed
 		return
 	}
@@ -172,8 +184,11 @@ func (c *Consumer) processMessage(ctx context.Context, msg *kafka.Message) {
 		c.logger.Error("Failed to process email event after retries",
 			"messageID", event.MessageID,
 			"error", err)
+		metrics.KafkaConsumed(c.config.Topic, metrics.ResultFailed)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "sent to DLQ")
 		// Send to DLQ
-		c.sendToDLQ(event, err)
+		c.sendToDLQ(ctx, event, err)
 		c.commitMessage(msg) // Commit to move past failed message
 		return
 	}
@@ -184,6 +199,7 @@ func (c *Consumer) processMessage(ctx context.Context, msg *kafka.Message) {
 		c.logger.Error("Failed to mark as processed",
 			"messageID", event.MessageID,
 			"error", err)
+		metrics.KafkaConsumed(c.config.Topic, metrics.ResultRetry)
 		// Don't commit - will retry
 		return
 	}
@@ -193,6 +209,8 @@ func (c *Consumer) processMessage(ctx context.Context, msg *kafka.Message) {
 			"messageID", event.MessageID)
 	}
 
+	metrics.KafkaConsumed(c.config.Topic, metrics.ResultProcessed)
+
 	// Commit offset
 	c.commitMessage(msg)
 
@@ -241,7 +259,7 @@ func (c *Consumer) processWithRetry(ctx context.Context, event EmailEvent) error
 }
 
 // sendToDLQ sends a failed message to the Dead Letter Queue
-func (c *Consumer) sendToDLQ(event EmailEvent, processingError error) {
+func (c *Consumer) sendToDLQ(ctx context.Context, event EmailEvent, processingError error) {
 	// Add error information to event
 	dlqEvent := map[string]interface{}{
 		"original_event": event,
@@ -265,8 +283,10 @@ func (c *Consumer) sendToDLQ(event EmailEvent, processingError error) {
 		},
 		Value: jsonData,
 	}
+	kafkapkg.InjectTrace(ctx, msg)
 
 	err = c.dlqProducer.Produce(msg, nil)
+	metrics.KafkaDLQ(c.config.DLQTopic, err)
 	if err != nil {
 		c.logger.Error("Failed to send to DLQ",
 			"messageID", event.MessageID,
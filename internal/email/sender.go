@@ -4,7 +4,7 @@ package email
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"net/smtp"
 	"os"
 	"strconv"
@@ -54,7 +54,7 @@ func NewSender(cfg *Config) Sender {
 type logSender struct{}
 
 func (s *logSender) SendVerificationCode(email, code string) error {
-	log.Printf("[DEV] Verification code for %s: %s (expires in 10 minutes)", email, code)
+	slog.Info("[DEV] Verification code (expires in 10 minutes)", "email", email, "code", code)
 	return nil
 }
 
@@ -67,7 +67,10 @@ func (s *logSender) SendEmailEvent(event EmailEvent) error {
 		}
 		return s.SendVerificationCode(event.Recipient, code)
 	default:
-		log.Printf("[DEV] Email event for %s: type=%s, data=%v", event.Recipient, event.EventType, event.Data)
+		slog.Info("[DEV] Email event",
+			"recipient", event.Recipient,
+			"type", event.EventType,
+			"data", event.Data)
 		return nil
 	}
 }
@@ -101,7 +104,7 @@ func (s *smtpSender) SendVerificationCode(email, code string) error {
 		return fmt.Errorf("failed to send email: %w", err)
 	}
 
-	log.Printf("Verification code sent to %s via SMTP", email)
+	slog.Info("Verification code sent via SMTP", "email", email)
 	return nil
 }
 
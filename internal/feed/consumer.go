@@ -0,0 +1,191 @@
+package feed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	kafkapkg "instant/internal/kafka"
+	"instant/internal/metrics"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// Consumer reads post-created events from Kafka and fans them out to
+// timelines, and follow-changed events to rebuild the follower's timeline
+type Consumer struct {
+	consumer *kafka.Consumer
+	svc      Service
+	config   *ConsumerConfig
+	logger   *slog.Logger
+}
+
+// ConsumerConfig holds consumer configuration
+type ConsumerConfig struct {
+	Brokers string
+	Topic   string
+	// FollowTopic carries follow-changed events; empty means not consumed
+	FollowTopic   string
+	ConsumerGroup string
+	MaxRetries    int
+}
+
+// NewConsumer creates a new Kafka consumer for post-created and follow-changed events
+func NewConsumer(config *ConsumerConfig, svc Service, logger *slog.Logger) (*Consumer, error) {
+	consumerConfig := &kafka.ConfigMap{
+		"bootstrap.servers":  config.Brokers,
+		"group.id":           config.ConsumerGroup,
+		"auto.offset.reset":  "latest", // Timelines are rebuilt on read, no need to replay history
+		"enable.auto.commit": false,    // Commit only after fan-out
+	}
+
+	c, err := kafka.NewConsumer(consumerConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer: %w", err)
+	}
+
+	logger.Info("Kafka consumer initialized",
+		"brokers", config.Brokers,
+		"topic", config.Topic,
+		"follow_topic", config.FollowTopic,
+		"group", config.ConsumerGroup)
+
+	return &Consumer{
+		consumer: c,
+		svc:      svc,
+		config:   config,
+		logger:   logger,
+	}, nil
+}
+
+// Start consumes messages until the context is cancelled
+func (c *Consumer) Start(ctx context.Context) error {
+	topics := []string{c.config.Topic}
+	if c.config.FollowTopic != "" {
+		topics = append(topics, c.config.FollowTopic)
+	}
+	if err := c.consumer.SubscribeTopics(topics, nil); err != nil {
+		return fmt.Errorf("failed to subscribe to topics: %w", err)
+	}
+
+	c.logger.Info("Starting to consume messages", "topics", topics)
+
+	for {
+		select {
+		case <-ctx.Done():
+			c.logger.Info("Consumer shutting down...")
+			return nil
+
+		default:
+			msg, err := c.consumer.ReadMessage(1 * time.Second)
+			if err != nil {
+				// Timeout is not an error
+				if kerr, ok := err.(kafka.Error); ok && kerr.Code() == kafka.ErrTimedOut {
+					continue
+				}
+				c.logger.Error("Error reading message", "error", err)
+				continue
+			}
+
+			c.processMessage(ctx, msg)
+		}
+	}
+}
+
+// processMessage handles a single event, by topic. Pushing to a timeline
+// and dropping one are idempotent, so redelivered events are harmless.
+func (c *Consumer) processMessage(ctx context.Context, msg *kafka.Message) {
+	ctx, span := kafkapkg.StartConsumerSpan(ctx, msg, c.config.ConsumerGroup)
+	defer span.End()
+
+	topic := *msg.TopicPartition.Topic
+	var (
+		process func() error
+		attrs   []any
+		err     error
+	)
+	if topic == c.config.FollowTopic {
+		var event kafkapkg.FollowChangedEvent
+		err = json.Unmarshal(msg.Value, &event)
+		process = func() error { return c.svc.FollowChanged(ctx, event) }
+		attrs = []any{"follower_id", event.FollowerID, "followee_id", event.FolloweeID}
+	} else {
+		var event kafkapkg.PostCreatedEvent
+		err = json.Unmarshal(msg.Value, &event)
+		process = func() error { return c.svc.FanOut(ctx, event) }
+		attrs = []any{"postID", event.PostID, "authorID", event.AuthorID}
+	}
+	if err != nil {
+		c.logger.Error("Failed to parse event",
+			"topic", topic,
+			"error", err,
+			"raw_value", string(msg.Value))
+		metrics.KafkaConsumed(topic, metrics.ResultInvalid)
+		c.commitMessage(msg) // Commit to skip bad message
+		return
+	}
+
+	if err := c.processWithRetry(topic, process, attrs); err != nil {
+		// Followers still see the change: timelines are rebuilt from the
+		// database when they expire, DefaultTimelineTTL after being built
+		c.logger.Error("Failed to process event after retries",
+			append(attrs, "topic", topic, "error", err)...)
+		metrics.KafkaConsumed(topic, metrics.ResultFailed)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "processing failed")
+	} else {
+		metrics.KafkaConsumed(topic, metrics.ResultProcessed)
+	}
+
+	c.commitMessage(msg)
+}
+
+// processWithRetry attempts to process an event with linear backoff
+func (c *Consumer) processWithRetry(topic string, process func() error, attrs []any) error {
+	maxRetries := c.config.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = 3 // Default
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		err := process()
+		if err == nil {
+			return nil
+		}
+		if err == ErrInvalidInput {
+			return err
+		}
+
+		lastErr = err
+		c.logger.Warn("Failed to process event, will retry",
+			append(attrs, "topic", topic, "attempt", attempt, "maxRetries", maxRetries, "error", err)...)
+
+		if attempt < maxRetries {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+	}
+
+	return fmt.Errorf("max retries exceeded: %w", lastErr)
+}
+
+// commitMessage commits the Kafka offset
+func (c *Consumer) commitMessage(msg *kafka.Message) {
+	if _, err := c.consumer.CommitMessage(msg); err != nil {
+		c.logger.Error("Failed to commit offset",
+			"topic", *msg.TopicPartition.Topic,
+			"partition", msg.TopicPartition.Partition,
+			"offset", msg.TopicPartition.Offset,
+			"error", err)
+	}
+}
+
+// Close closes the consumer
+func (c *Consumer) Close() {
+	c.logger.Info("Closing Kafka consumer...")
+	c.consumer.Close()
+	c.logger.Info("Kafka consumer closed")
+}
@@ -0,0 +1,60 @@
+package feed
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"instant/internal/identity"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	svc Service
+}
+
+func NewHandler(svc Service) *Handler { return &Handler{svc: svc} }
+
+// Home handles GET /
+// @Summary Get home timeline
+// @Description Posts from the accounts the current user follows, newest first, with cursor pagination (requires authentication)
+// @Tags feed
+// @Produce json
+// @Param cursor query string false "Opaque cursor from the previous page's next_cursor"
+// @Param limit query int false "Page size" default(20)
+// @Success 200 {object} FeedResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security SessionAuth
+// @Router /api/feed [get]
+func (h *Handler) Home(c *gin.Context) {
+	userID := identity.UserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(DefaultLimit)))
+
+	resp, err := h.svc.HomeFeed(c.Request.Context(), userID, c.Query("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load feed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// GET /health
+func (h *Handler) Health(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "healthy",
+		"service": "feed-service",
+	})
+}
@@ -0,0 +1,20 @@
+package feed
+
+import "time"
+
+// Post is a post as it appears in a user's home timeline
+type Post struct {
+	PostID    int64     `json:"post_id"`
+	UserID    string    `json:"user_id"`
+	Caption   string    `json:"caption"`
+	ImageURL  string    `json:"image_url"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// FeedResponse is a single page of the home timeline
+type FeedResponse struct {
+	Posts      []Post `json:"posts"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
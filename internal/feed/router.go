@@ -0,0 +1,26 @@
+package feed
+
+import (
+	"instant/internal/identity"
+	"instant/internal/logger"
+	"instant/internal/metrics"
+	"instant/internal/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SetupRouter(svc Service, signer *identity.Signer) *gin.Engine {
+	r := gin.Default()
+	r.Use(logger.Middleware())
+	r.Use(tracing.Middleware())
+	metrics.Instrument(r) // request metrics and /metrics
+	h := NewHandler(svc)
+
+	// Health
+	r.GET("/health", h.Health)
+
+	// Home timeline (identity signed by the gateway)
+	r.GET("/", identity.RequireUser(signer), h.Home)
+
+	return r
+}
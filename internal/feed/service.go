@@ -0,0 +1,391 @@
+// Package feed implements the feed service logic.
+// It builds a user's home timeline from the posts of the accounts they follow.
+//
+// Timelines use a hybrid strategy: posts of regular authors are pushed into
+// per-follower Redis sorted sets when they are created (fan-out on write),
+// while posts of authors with very large audiences are pulled from the
+// database when the timeline is read (fan-out on read).
+package feed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"instant/internal/database"
+	kafkapkg "instant/internal/kafka"
+	"instant/internal/logger"
+	"instant/internal/pagination"
+)
+
+const (
+	// DefaultLimit is the page size used when the client does not provide one
+	DefaultLimit = 20
+	// MaxLimit caps the page size to keep timeline queries cheap
+	MaxLimit = 100
+	// DefaultCelebrityThreshold is the follower count above which an author's
+	// posts are no longer fanned out on write
+	DefaultCelebrityThreshold = 10000
+
+	// timelinePadding is read from Redis on top of what a page still needs, to
+	// make up for deleted posts, unfollowed authors and posts sharing the
+	// cursor's millisecond without another round trip
+	timelinePadding = 10
+)
+
+var (
+	ErrInvalidInput  = errors.New("invalid input")
+	ErrInvalidCursor = pagination.ErrInvalidCursor
+)
+
+type Service interface {
+	HomeFeed(ctx context.Context, userID, cursor string, limit int) (*FeedResponse, error)
+	FanOut(ctx context.Context, event kafkapkg.PostCreatedEvent) error
+	FollowChanged(ctx context.Context, event kafkapkg.FollowChangedEvent) error
+}
+
+type service struct {
+	db                 database.Service
+	timelines          *TimelineStore
+	celebrityThreshold int64
+}
+
+// NewService creates a feed service that builds every timeline from the database
+func NewService(db database.Service) Service {
+	return &service{db: db}
+}
+
+// NewServiceWithTimelines creates a feed service backed by Redis timelines
+func NewServiceWithTimelines(db database.Service, timelines *TimelineStore, celebrityThreshold int64) Service {
+	if celebrityThreshold <= 0 {
+		celebrityThreshold = DefaultCelebrityThreshold
+	}
+	return &service{
+		db:                 db,
+		timelines:          timelines,
+		celebrityThreshold: celebrityThreshold,
+	}
+}
+
+// HomeFeed returns one page of posts written by the accounts userID follows,
+// newest first. An empty cursor starts from the most recent post.
+func (s *service) HomeFeed(ctx context.Context, userID, token string, limit int) (*FeedResponse, error) {
+	if userID == "" {
+		return nil, ErrInvalidInput
+	}
+	if limit < 1 || limit > MaxLimit {
+		limit = DefaultLimit
+	}
+
+	var c *pagination.Cursor
+	if token != "" {
+		var err error
+		if c, err = pagination.DecodeCursor(token); err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		posts []Post
+		err   error
+	)
+	if s.timelines != nil {
+		posts, err = s.timelinePage(ctx, userID, c, limit)
+		if err != nil {
+			logger.FromContext(ctx).Warn("Timeline read failed, falling back to database",
+				"user_id", userID, "error", err)
+		}
+	}
+	if s.timelines == nil || err != nil {
+		posts, err = s.followeePosts(ctx, userID, c, limit+1)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// One extra row tells whether another page exists
+	resp := &FeedResponse{Posts: posts}
+	if len(posts) > limit {
+		resp.Posts = posts[:limit]
+		resp.HasMore = true
+		last := resp.Posts[limit-1]
+		resp.NextCursor = pagination.EncodeCursor(last.CreatedAt, last.PostID)
+	}
+
+	return resp, nil
+}
+
+// timelinePage returns up to limit+1 posts, merging the user's Redis timeline
+// with the posts of followed celebrities
+func (s *service) timelinePage(ctx context.Context, userID string, c *pagination.Cursor, limit int) ([]Post, error) {
+	warm, err := s.timelines.Exists(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !warm {
+		if c != nil {
+			// Deep page on an expired timeline: serve straight from the database
+			return s.followeePosts(ctx, userID, c, limit+1)
+		}
+		recent, err := s.followeePosts(ctx, userID, nil, s.timelines.maxLen)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.timelines.Rebuild(ctx, userID, recent); err != nil {
+			logger.FromContext(ctx).Error("Failed to rebuild timeline",
+				"user_id", userID, "error", err)
+		}
+		if len(recent) > limit+1 {
+			recent = recent[:limit+1]
+		}
+		return recent, nil
+	}
+
+	var truncated bool
+	next := func(offset, count int) ([]int64, error) {
+		ids, full, err := s.timelines.Range(ctx, userID, c, offset, count)
+		truncated = full
+		return ids, err
+	}
+	hydrate := func(ids []int64) ([]Post, error) {
+		return s.postsByID(ctx, userID, ids, c)
+	}
+	pushed, exhausted, err := collectTimeline(next, hydrate, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	if exhausted && truncated && len(pushed) < limit+1 {
+		// Reached the end of what Redis keeps; older posts live only in the database
+		return s.followeePosts(ctx, userID, c, limit+1)
+	}
+
+	celebrities, err := s.timelines.Celebrities(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pulled, err := s.celebrityPosts(ctx, userID, celebrities, c, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	return mergePosts(pushed, pulled, limit+1), nil
+}
+
+// FanOut pushes a newly created post into the timelines of its author's followers.
+// Authors above the celebrity threshold are skipped and served on read instead,
+// until their follower count drops below it again.
+func (s *service) FanOut(ctx context.Context, event kafkapkg.PostCreatedEvent) error {
+	if s.timelines == nil {
+		return nil
+	}
+	if event.PostID <= 0 || event.AuthorID == "" {
+		return ErrInvalidInput
+	}
+
+	var followers int64
+	const countQ = `SELECT COUNT(*) FROM follow WHERE followee_id=$1`
+	if err := s.db.QueryRow(ctx, countQ, event.AuthorID).Scan(&followers); err != nil {
+		return fmt.Errorf("count followers: %w", err)
+	}
+
+	if followers >= s.celebrityThreshold {
+		if err := s.timelines.MarkCelebrity(ctx, event.AuthorID); err != nil {
+			return fmt.Errorf("mark celebrity: %w", err)
+		}
+		return nil
+	}
+
+	// An author who dropped below the threshold is fanned out on write again.
+	// Their earlier posts were only ever pulled on read, so the followers'
+	// timelines are dropped and rebuilt from the database, which also picks
+	// up this post. They stay marked until then so no post goes missing.
+	demoted, err := s.timelines.IsCelebrity(ctx, event.AuthorID)
+	if err != nil {
+		return fmt.Errorf("check celebrity: %w", err)
+	}
+	deliver := func(batch []string) error {
+		if demoted {
+			return s.timelines.Invalidate(ctx, batch)
+		}
+		return s.timelines.Push(ctx, batch, event.PostID, event.CreatedAt)
+	}
+
+	const q = `SELECT follower_id FROM follow WHERE followee_id=$1`
+	rows, err := s.db.Query(ctx, q, event.AuthorID)
+	if err != nil {
+		return fmt.Errorf("query followers: %w", err)
+	}
+	defer rows.Close()
+
+	const batchSize = 500
+	batch := make([]string, 0, batchSize)
+	for rows.Next() {
+		var followerID string
+		if err := rows.Scan(&followerID); err != nil {
+			return fmt.Errorf("scan follower: %w", err)
+		}
+		batch = append(batch, followerID)
+		if len(batch) == batchSize {
+			if err := deliver(batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate followers: %w", err)
+	}
+
+	if len(batch) > 0 {
+		if err := deliver(batch); err != nil {
+			return err
+		}
+	}
+	if demoted {
+		if err := s.timelines.UnmarkCelebrity(ctx, event.AuthorID); err != nil {
+			return fmt.Errorf("unmark celebrity: %w", err)
+		}
+	}
+	return nil
+}
+
+// FollowChanged drops the follower's timeline after a follow or unfollow, so
+// it is rebuilt from the current follow set on the next read. A new followee's
+// earlier posts were never pushed to it, and an unfollowed author's posts
+// should not stay in it.
+func (s *service) FollowChanged(ctx context.Context, event kafkapkg.FollowChangedEvent) error {
+	if s.timelines == nil {
+		return nil
+	}
+	if event.FollowerID == "" {
+		return ErrInvalidInput
+	}
+	return s.timelines.Invalidate(ctx, []string{event.FollowerID})
+}
+
+// followeePosts builds a timeline page directly from the follow and posts tables
+func (s *service) followeePosts(ctx context.Context, userID string, c *pagination.Cursor, n int) ([]Post, error) {
+	const q = `
+		SELECT p.post_id, p.user_id, p.caption, p.image_url, p.created_at, p.updated_at
+		FROM posts p
+		JOIN follow f ON f.followee_id = p.user_id
+		WHERE f.follower_id = $1
+	`
+	return s.queryPage(ctx, q, c, n, userID)
+}
+
+// collectTimeline reads timeline IDs in batches from next and hydrates them
+// until n posts are collected or the timeline is exhausted. Hydration drops
+// posts that no longer belong in the timeline, so a batch may yield fewer
+// posts than it has IDs.
+func collectTimeline(next func(offset, count int) ([]int64, error), hydrate func(ids []int64) ([]Post, error), n int) (posts []Post, exhausted bool, err error) {
+	posts = []Post{}
+	offset := 0
+	for len(posts) < n {
+		count := n - len(posts) + timelinePadding
+		ids, err := next(offset, count)
+		if err != nil {
+			return nil, false, err
+		}
+		offset += len(ids)
+
+		batch, err := hydrate(ids)
+		if err != nil {
+			return nil, false, err
+		}
+		posts = mergePosts(posts, batch, n)
+
+		if len(ids) < count {
+			return posts, true, nil
+		}
+	}
+	return posts, false, nil
+}
+
+// postsByID loads the given posts, dropping deleted ones, those by authors
+// userID no longer follows and those not after the cursor
+func (s *service) postsByID(ctx context.Context, userID string, ids []int64, c *pagination.Cursor) ([]Post, error) {
+	if len(ids) == 0 {
+		return []Post{}, nil
+	}
+	const q = `
+		SELECT p.post_id, p.user_id, p.caption, p.image_url, p.created_at, p.updated_at
+		FROM posts p
+		JOIN follow f ON f.followee_id = p.user_id
+		WHERE f.follower_id = $1 AND p.post_id = ANY($2::bigint[])
+	`
+	return s.queryPage(ctx, q, c, len(ids), userID, ids)
+}
+
+// celebrityPosts loads posts of followed authors that are fanned out on read
+func (s *service) celebrityPosts(ctx context.Context, userID string, celebrities []string, c *pagination.Cursor, n int) ([]Post, error) {
+	if len(celebrities) == 0 {
+		return []Post{}, nil
+	}
+	const q = `
+		SELECT p.post_id, p.user_id, p.caption, p.image_url, p.created_at, p.updated_at
+		FROM posts p
+		JOIN follow f ON f.followee_id = p.user_id
+		WHERE f.follower_id = $1 AND p.user_id = ANY($2::uuid[])
+	`
+	return s.queryPage(ctx, q, c, n, userID, celebrities)
+}
+
+// queryPage appends the keyset condition, ordering and limit to a base query
+// and scans the resulting posts
+func (s *service) queryPage(ctx context.Context, base string, c *pagination.Cursor, n int, args ...interface{}) ([]Post, error) {
+	q := base
+	if c != nil {
+		q += " AND " + c.Condition("p.created_at", "p.post_id", len(args)+1)
+		args = append(args, c.CreatedAt, c.PostID)
+	}
+	q += fmt.Sprintf(" ORDER BY p.created_at DESC, p.post_id DESC LIMIT $%d", len(args)+1)
+	args = append(args, n)
+
+	rows, err := s.db.Query(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query feed: %w", err)
+	}
+	defer rows.Close()
+
+	posts := []Post{}
+	for rows.Next() {
+		var p Post
+		if err := rows.Scan(&p.PostID, &p.UserID, &p.Caption, &p.ImageURL, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan feed post: %w", err)
+		}
+		posts = append(posts, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate feed: %w", err)
+	}
+
+	return posts, nil
+}
+
+// mergePosts merges two newest-first lists, dropping duplicates, and keeps at most n posts
+func mergePosts(a, b []Post, n int) []Post {
+	seen := make(map[int64]bool, len(a)+len(b))
+	out := make([]Post, 0, len(a)+len(b))
+	for _, list := range [][]Post{a, b} {
+		for _, p := range list {
+			if !seen[p.PostID] {
+				seen[p.PostID] = true
+				out = append(out, p)
+			}
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].PostID > out[j].PostID
+		}
+		return out[i].CreatedAt.After(out[j].CreatedAt)
+	})
+
+	if len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
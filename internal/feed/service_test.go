@@ -0,0 +1,207 @@
+package feed
+
+import (
+	"testing"
+	"time"
+
+	"instant/internal/pagination"
+)
+
+var base = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// post returns a post created ms milliseconds after base
+func post(id int64, author string, ms int) Post {
+	return Post{PostID: id, UserID: author, CreatedAt: base.Add(time.Duration(ms) * time.Millisecond)}
+}
+
+func postIDs(posts []Post) []int64 {
+	ids := make([]int64, 0, len(posts))
+	for _, p := range posts {
+		ids = append(ids, p.PostID)
+	}
+	return ids
+}
+
+func equalIDs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMergePosts(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []Post
+		n    int
+		want []int64
+	}{
+		{"empty", nil, nil, 5, []int64{}},
+		{"one side", []Post{post(2, "a", 20), post(1, "a", 10)}, nil, 5, []int64{2, 1}},
+		{"interleaved", []Post{post(4, "a", 40), post(2, "a", 20)}, []Post{post(3, "c", 30), post(1, "c", 10)}, 5, []int64{4, 3, 2, 1}},
+		{"duplicates dropped", []Post{post(2, "a", 20), post(1, "a", 10)}, []Post{post(2, "a", 20)}, 5, []int64{2, 1}},
+		{"same millisecond by post id", []Post{post(5, "a", 10)}, []Post{post(7, "c", 10), post(6, "c", 10)}, 5, []int64{7, 6, 5}},
+		{"truncated to n", []Post{post(3, "a", 30), post(1, "a", 10)}, []Post{post(2, "c", 20)}, 2, []int64{3, 2}},
+	}
+	for _, tt := range tests {
+		if got := postIDs(mergePosts(tt.a, tt.b, tt.n)); !equalIDs(got, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+// fakeFeed mirrors timelinePage over in-memory data: a Redis timeline of post
+// IDs, the posts still in the database, the authors the reader follows and
+// the posts of followed celebrities
+type fakeFeed struct {
+	timeline    []Post // newest first, as scored in Redis
+	deleted     map[int64]bool
+	unfollowed  map[string]bool
+	celebrities []Post // newest first
+	reads       int
+}
+
+func after(p Post, c *pagination.Cursor) bool {
+	return c == nil || c.Includes(p.CreatedAt, p.PostID)
+}
+
+func (f *fakeFeed) page(t *testing.T, c *pagination.Cursor, limit int) []Post {
+	t.Helper()
+
+	byID := make(map[int64]Post, len(f.timeline))
+	for _, p := range f.timeline {
+		byID[p.PostID] = p
+	}
+
+	// Range is inclusive of the cursor's millisecond
+	var inRange []int64
+	for _, p := range f.timeline {
+		if c == nil || !p.CreatedAt.After(c.CreatedAt) {
+			inRange = append(inRange, p.PostID)
+		}
+	}
+	next := func(offset, count int) ([]int64, error) {
+		f.reads++
+		if offset >= len(inRange) {
+			return nil, nil
+		}
+		return inRange[offset:min(offset+count, len(inRange))], nil
+	}
+	hydrate := func(ids []int64) ([]Post, error) {
+		posts := []Post{}
+		for _, id := range ids {
+			p := byID[id]
+			if !f.deleted[id] && !f.unfollowed[p.UserID] && after(p, c) {
+				posts = append(posts, p)
+			}
+		}
+		return posts, nil
+	}
+
+	pushed, _, err := collectTimeline(next, hydrate, limit+1)
+	if err != nil {
+		t.Fatalf("collectTimeline failed: %v", err)
+	}
+
+	pulled := []Post{}
+	for _, p := range f.celebrities {
+		if after(p, c) && len(pulled) < limit+1 {
+			pulled = append(pulled, p)
+		}
+	}
+	return mergePosts(pushed, pulled, limit+1)
+}
+
+// readAll pages through the feed like HomeFeed does
+func (f *fakeFeed) readAll(t *testing.T, limit int) (ids []int64, pageSizes []int) {
+	t.Helper()
+
+	var c *pagination.Cursor
+	for i := 0; i < 100; i++ {
+		posts := f.page(t, c, limit)
+		hasMore := len(posts) > limit
+		if hasMore {
+			posts = posts[:limit]
+		}
+		ids = append(ids, postIDs(posts)...)
+		pageSizes = append(pageSizes, len(posts))
+		if !hasMore {
+			return ids, pageSizes
+		}
+		last := posts[len(posts)-1]
+		c = &pagination.Cursor{CreatedAt: last.CreatedAt, PostID: last.PostID}
+	}
+	t.Fatal("Expected paging to end")
+	return nil, nil
+}
+
+func TestTimelinePaging_SkipsDeletedAndUnfollowed(t *testing.T) {
+	f := &fakeFeed{deleted: map[int64]bool{}, unfollowed: map[string]bool{"gone": true}}
+	var want []int64
+	for id := int64(100); id >= 1; id-- {
+		author := "a"
+		switch {
+		case id > 60 && id <= 90:
+			// A long run of deleted posts, far more than timelinePadding
+			f.deleted[id] = true
+		case id%7 == 0:
+			author = "gone"
+		default:
+			want = append(want, id)
+		}
+		f.timeline = append(f.timeline, post(id, author, int(id)))
+	}
+
+	got, sizes := f.readAll(t, 5)
+	if !equalIDs(got, want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for i, size := range sizes[:len(sizes)-1] {
+		if size != 5 {
+			t.Errorf("Expected page %d to be full, got %d posts", i, size)
+		}
+	}
+}
+
+func TestTimelinePaging_MergesCelebrities(t *testing.T) {
+	f := &fakeFeed{}
+	var want []int64
+	// Pushed and pulled posts interleave, some within the same millisecond
+	for id := int64(40); id >= 1; id-- {
+		p := post(id, "a", int(id/2))
+		if id%3 == 0 {
+			p.UserID = "celebrity"
+			f.celebrities = append(f.celebrities, p)
+		} else {
+			f.timeline = append(f.timeline, p)
+		}
+		want = append(want, id)
+	}
+
+	got, sizes := f.readAll(t, 4)
+	if !equalIDs(got, want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	if len(sizes) != 10 {
+		t.Errorf("Expected 10 pages, got %d", len(sizes))
+	}
+}
+
+func TestCollectTimeline_StopsWhenFull(t *testing.T) {
+	f := &fakeFeed{}
+	for id := int64(500); id >= 1; id-- {
+		f.timeline = append(f.timeline, post(id, "a", int(id)))
+	}
+
+	if got := postIDs(f.page(t, nil, 3)); !equalIDs(got, []int64{500, 499, 498, 497}) {
+		t.Errorf("Expected the newest posts, got %v", got)
+	}
+	if f.reads != 1 {
+		t.Errorf("Expected a single timeline read, got %d", f.reads)
+	}
+}
@@ -0,0 +1,177 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"instant/internal/pagination"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// DefaultTimelineMaxLen is how many post IDs are kept per timeline
+	DefaultTimelineMaxLen = 800
+	// DefaultTimelineTTL is how long a timeline lives after it is built. Reads
+	// and pushes do not extend it, so every timeline is rebuilt from the
+	// database at least this often, picking up anything fan-out missed.
+	DefaultTimelineTTL = 24 * time.Hour
+
+	celebritiesKey = "feed:celebrities"
+)
+
+// pushScript adds a post to a timeline only if the timeline already exists.
+// Cold timelines are rebuilt from the database on the next read, so creating
+// them here would leave them with only the newest posts.
+var pushScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+redis.call('ZADD', KEYS[1], ARGV[1], ARGV[2])
+redis.call('ZREMRANGEBYRANK', KEYS[1], 0, -(tonumber(ARGV[3]) + 1))
+return 1
+`)
+
+// TimelineStore keeps per-user home timelines as Redis sorted sets of post IDs
+// scored by creation time (unix milliseconds)
+type TimelineStore struct {
+	client *redis.Client
+	maxLen int
+	ttl    time.Duration
+}
+
+// NewTimelineStore creates a new Redis-backed timeline store
+func NewTimelineStore(client *redis.Client, maxLen int, ttl time.Duration) *TimelineStore {
+	if maxLen <= 0 {
+		maxLen = DefaultTimelineMaxLen
+	}
+	if ttl <= 0 {
+		ttl = DefaultTimelineTTL
+	}
+	return &TimelineStore{
+		client: client,
+		maxLen: maxLen,
+		ttl:    ttl,
+	}
+}
+
+func timelineKey(userID string) string {
+	return fmt.Sprintf("timeline:%s", userID)
+}
+
+func score(t time.Time) float64 {
+	return float64(t.UnixMilli())
+}
+
+// Push adds a post to the timelines of the given followers, trimming each to maxLen
+func (s *TimelineStore) Push(ctx context.Context, followerIDs []string, postID int64, createdAt time.Time) error {
+	pipe := s.client.Pipeline()
+	for _, followerID := range followerIDs {
+		pushScript.Run(ctx, pipe, []string{timelineKey(followerID)},
+			score(createdAt), postID, s.maxLen)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return fmt.Errorf("push to timelines: %w", err)
+	}
+	return nil
+}
+
+// Exists reports whether the user's timeline is warm
+func (s *TimelineStore) Exists(ctx context.Context, userID string) (bool, error) {
+	n, err := s.client.Exists(ctx, timelineKey(userID)).Result()
+	return n > 0, err
+}
+
+// Rebuild replaces the user's timeline with the given posts
+func (s *TimelineStore) Rebuild(ctx context.Context, userID string, posts []Post) error {
+	key := timelineKey(userID)
+
+	pipe := s.client.TxPipeline()
+	pipe.Del(ctx, key)
+	if len(posts) > 0 {
+		members := make([]redis.Z, 0, len(posts))
+		for _, p := range posts {
+			members = append(members, redis.Z{Score: score(p.CreatedAt), Member: p.PostID})
+		}
+		pipe.ZAdd(ctx, key, members...)
+	} else {
+		// Placeholder so an empty timeline still counts as warm
+		pipe.ZAdd(ctx, key, redis.Z{Score: 0, Member: 0})
+	}
+	pipe.PExpire(ctx, key, s.ttl)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("rebuild timeline: %w", err)
+	}
+	return nil
+}
+
+// Invalidate drops the given users' timelines so they are rebuilt from the
+// database on their next read
+func (s *TimelineStore) Invalidate(ctx context.Context, userIDs []string) error {
+	keys := make([]string, 0, len(userIDs))
+	for _, userID := range userIDs {
+		keys = append(keys, timelineKey(userID))
+	}
+	if err := s.client.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("invalidate timelines: %w", err)
+	}
+	return nil
+}
+
+// Range returns up to count post IDs at or before the cursor, newest first,
+// skipping the first offset of them. truncated is true when the timeline is
+// full, meaning older posts may exist that are no longer kept in Redis.
+func (s *TimelineStore) Range(ctx context.Context, userID string, c *pagination.Cursor, offset, count int) (ids []int64, truncated bool, err error) {
+	key := timelineKey(userID)
+
+	max := "+inf"
+	if c != nil {
+		// Inclusive: posts within the same millisecond are filtered by the caller
+		max = strconv.FormatFloat(score(c.CreatedAt), 'f', 0, 64)
+	}
+
+	pipe := s.client.Pipeline()
+	rangeCmd := pipe.ZRevRangeByScore(ctx, key, &redis.ZRangeBy{
+		Min:    "(0",
+		Max:    max,
+		Offset: int64(offset),
+		Count:  int64(count),
+	})
+	cardCmd := pipe.ZCard(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, false, fmt.Errorf("range timeline: %w", err)
+	}
+
+	for _, member := range rangeCmd.Val() {
+		id, err := strconv.ParseInt(member, 10, 64)
+		if err != nil || id <= 0 {
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, cardCmd.Val() >= int64(s.maxLen), nil
+}
+
+// MarkCelebrity records that an author's posts are fanned out on read
+func (s *TimelineStore) MarkCelebrity(ctx context.Context, authorID string) error {
+	return s.client.SAdd(ctx, celebritiesKey, authorID).Err()
+}
+
+// UnmarkCelebrity records that an author's posts are fanned out on write again
+func (s *TimelineStore) UnmarkCelebrity(ctx context.Context, authorID string) error {
+	return s.client.SRem(ctx, celebritiesKey, authorID).Err()
+}
+
+// IsCelebrity reports whether an author's posts are fanned out on read
+func (s *TimelineStore) IsCelebrity(ctx context.Context, authorID string) (bool, error) {
+	return s.client.SIsMember(ctx, celebritiesKey, authorID).Result()
+}
+
+// Celebrities returns all authors whose posts are fanned out on read
+func (s *TimelineStore) Celebrities(ctx context.Context) ([]string, error) {
+	return s.client.SMembers(ctx, celebritiesKey).Result()
+}
@@ -4,40 +4,40 @@ import (
 	"net/http"
 	"os"
 
-	"github.com/gin-contrib/cors"
+	"instant/internal/identity"
+	"instant/internal/logger"
+	"instant/internal/metrics"
+	"instant/internal/tracing"
+
 	"github.com/gin-gonic/gin"
 )
 
 // Server holds dependencies for files service
 type Server struct {
 	service *Service
+	signer  *identity.Signer
 }
 
 // NewServer creates a new files server
-func NewServer(service *Service) *Server {
-	return &Server{service: service}
+func NewServer(service *Service, signer *identity.Signer) *Server {
+	return &Server{service: service, signer: signer}
 }
 
 // RegisterRoutes sets up HTTP routes for files service
 func (s *Server) RegisterRoutes() http.Handler {
 	r := gin.Default()
-
-	// CORS configuration
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:5173", "http://localhost:3000", "http://localhost:8080"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowHeaders:     []string{"Accept", "Authorization", "Content-Type", "X-User-ID", "X-User-Email"},
-		AllowCredentials: true,
-	}))
+	r.Use(logger.Middleware())
+	r.Use(tracing.Middleware())
+	metrics.Instrument(r) // request metrics and /metrics
 
 	handler := NewHandler(s.service)
 
 	// Health check endpoint (public)
 	r.GET("/health", handler.Health)
 
-	// File operations endpoints
-	// Note: These should be protected via Gateway in production
+	// File operations endpoints - require the identity signed by the gateway
 	filesGroup := r.Group("/files")
+	filesGroup.Use(identity.RequireUser(s.signer))
 	{
 		filesGroup.POST("/upload-url", handler.GenerateUploadURL)     // Generate presigned upload URL
 		filesGroup.POST("/download-url", handler.GenerateDownloadURL) // Generate presigned download URL
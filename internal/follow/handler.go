@@ -3,6 +3,8 @@ package follow
 import (
 	"net/http"
 
+	"instant/internal/identity"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -15,7 +17,7 @@ func NewHandler(svc Service) *Handler {
 }
 
 func (h *Handler) Follow(c *gin.Context) {
-	followerID := c.GetHeader("X-User-ID")
+	followerID := identity.UserID(c)
 	if followerID == "" {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
@@ -27,7 +29,7 @@ func (h *Handler) Follow(c *gin.Context) {
 		return
 	}
 
-	f, err := h.svc.Follow(c, followerID, req.FolloweeID)
+	f, err := h.svc.Follow(c.Request.Context(), followerID, req.FolloweeID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed"})
 		return
@@ -37,7 +39,7 @@ func (h *Handler) Follow(c *gin.Context) {
 }
 
 func (h *Handler) Unfollow(c *gin.Context) {
-	followerID := c.GetHeader("X-User-ID")
+	followerID := identity.UserID(c)
 	if followerID == "" {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
@@ -45,7 +47,7 @@ func (h *Handler) Unfollow(c *gin.Context) {
 
 	followeeID := c.Param("user_id")
 
-	_, err := h.svc.Unfollow(c, followerID, followeeID)
+	_, err := h.svc.Unfollow(c.Request.Context(), followerID, followeeID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed"})
 		return
@@ -57,7 +59,7 @@ func (h *Handler) Unfollow(c *gin.Context) {
 func (h *Handler) FollowersCount(c *gin.Context) {
 	userID := c.Param("user_id")
 
-	cnt, err := h.svc.FollowersCount(c, userID)
+	cnt, err := h.svc.FollowersCount(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed"})
 		return
@@ -69,7 +71,7 @@ func (h *Handler) FollowersCount(c *gin.Context) {
 func (h *Handler) FollowingCount(c *gin.Context) {
 	userID := c.Param("user_id")
 
-	cnt, err := h.svc.FollowingCount(c, userID)
+	cnt, err := h.svc.FollowingCount(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed"})
 		return
@@ -79,7 +81,7 @@ func (h *Handler) FollowingCount(c *gin.Context) {
 }
 
 func (h *Handler) IsFollowing(c *gin.Context) {
-	followerID := c.GetHeader("X-User-ID")
+	followerID := identity.UserID(c)
 	if followerID == "" {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
@@ -87,7 +89,7 @@ func (h *Handler) IsFollowing(c *gin.Context) {
 
 	followeeID := c.Param("user_id")
 
-	ok, err := h.svc.IsFollowing(c, followerID, followeeID)
+	ok, err := h.svc.IsFollowing(c.Request.Context(), followerID, followeeID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed"})
 		return
@@ -1,24 +1,38 @@
 package follow
 
-import "github.com/gin-gonic/gin"
+import (
+    "instant/internal/identity"
+    "instant/internal/logger"
+    "instant/internal/metrics"
+    "instant/internal/tracing"
 
-func SetupRouter(svc Service) *gin.Engine {
+    "github.com/gin-gonic/gin"
+)
+
+func SetupRouter(svc Service, signer *identity.Signer, writes identity.WriteTracker) *gin.Engine {
     r := gin.Default()
+    r.Use(logger.Middleware())
+    r.Use(tracing.Middleware())
+    metrics.Instrument(r) // request metrics and /metrics
     h := NewHandler(svc)
 
     // Health
     r.GET("/health", h.Health)
 
+    // Everything else requires the identity signed by the gateway
+    api := r.Group("/")
+    api.Use(identity.RequireUser(signer), identity.ReadYourWrites(writes))
+
     // Follow / unfollow
-    r.POST("/", h.Follow)
-    r.DELETE("/:user_id", h.Unfollow)
+    api.POST("/", h.Follow)
+    api.DELETE("/:user_id", h.Unfollow)
 
     // Counts
-    r.GET("/:user_id/followers/count", h.FollowersCount)
-    r.GET("/:user_id/following/count", h.FollowingCount)
+    api.GET("/:user_id/followers/count", h.FollowersCount)
+    api.GET("/:user_id/following/count", h.FollowingCount)
 
     // Check if I follow user
-    r.GET("/:user_id/following/me", h.IsFollowing)
+    api.GET("/:user_id/following/me", h.IsFollowing)
 
     return r
 }
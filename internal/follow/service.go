@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"instant/internal/database"
+	kafkapkg "instant/internal/kafka"
+	"instant/internal/logger"
 
 	"github.com/google/uuid"
 )
@@ -25,12 +27,21 @@ type Service interface {
 
 type service struct {
 	db database.Service
+
+	kafkaProducer *kafkapkg.Producer
+	followTopic   string
 }
 
 func NewService(db database.Service) Service {
 	return &service{db: db}
 }
 
+// NewServiceWithKafka creates a follow service that also publishes
+// follow-changed events for the feed service
+func NewServiceWithKafka(db database.Service, kafkaProducer *kafkapkg.Producer, followTopic string) Service {
+	return &service{db: db, kafkaProducer: kafkaProducer, followTopic: followTopic}
+}
+
 func (s *service) Follow(ctx context.Context, followerID, followeeID string) (*Follow, error) {
 	if followerID == "" || followeeID == "" {
 		return nil, ErrInvalidInput
@@ -49,9 +60,13 @@ func (s *service) Follow(ctx context.Context, followerID, followeeID string) (*F
 		ON CONFLICT (follower_id, followee_id) DO NOTHING
 	`
 
-	if _, err := s.db.Exec(ctx, q, f.ID, f.FollowerID, f.FolloweeID, f.CreatedAt); err != nil {
+	res, err := s.db.Exec(ctx, q, f.ID, f.FollowerID, f.FolloweeID, f.CreatedAt)
+	if err != nil {
 		return nil, fmt.Errorf("insert follow: %w", err)
 	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		s.publishFollowChanged(ctx, followerID, followeeID, true)
+	}
 
 	return f, nil
 }
@@ -64,7 +79,33 @@ func (s *service) Unfollow(ctx context.Context, followerID, followeeID string) (
 		return 0, fmt.Errorf("delete follow: %w", err)
 	}
 
-	return res.RowsAffected()
+	n, err := res.RowsAffected()
+	if err == nil && n > 0 {
+		s.publishFollowChanged(ctx, followerID, followeeID, false)
+	}
+	return n, err
+}
+
+// publishFollowChanged queues a follow-changed event so the follower's
+// timeline is rebuilt. Failures are only logged: the timeline still catches
+// up when it expires.
+func (s *service) publishFollowChanged(ctx context.Context, followerID, followeeID string, following bool) {
+	if s.kafkaProducer == nil {
+		return
+	}
+
+	event := kafkapkg.FollowChangedEvent{
+		EventID:    uuid.New().String(),
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+		Following:  following,
+		ChangedAt:  time.Now(),
+	}
+
+	// Keyed by follower so a user's follow changes are applied in order
+	if err := s.kafkaProducer.PublishEvent(ctx, s.followTopic, followerID, event); err != nil {
+		logger.FromContext(ctx).Error("Failed to publish follow-changed event", "followee_id", followeeID, "error", err)
+	}
 }
 
 func (s *service) FollowersCount(ctx context.Context, userID string) (int64, error) {
@@ -0,0 +1,208 @@
+package gateway
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"instant/internal/consul"
+)
+
+// Load-balancing strategies selectable per upstream service
+const (
+	BalancerRandom           = "random"
+	BalancerRoundRobin       = "round-robin"
+	BalancerLeastOutstanding = "least-outstanding"
+	BalancerConsistentHash   = "consistent-hash"
+)
+
+// Balancer decides which instance of a service a request goes to
+type Balancer interface {
+	// Order returns the instances in the order they should be tried: the
+	// first one gets the request, the rest are used for retries.
+	// key identifies the caller for hashing strategies.
+	Order(instances []*consul.ServiceInstance, key string) []*consul.ServiceInstance
+	// Acquire marks a request to instance as started; the returned func marks it done
+	Acquire(instance *consul.ServiceInstance) func()
+}
+
+// NewBalancer creates a balancer for one of the Balancer* strategies
+func NewBalancer(strategy string) (Balancer, error) {
+	switch strategy {
+	case BalancerRandom, "":
+		return &randomBalancer{}, nil
+	case BalancerRoundRobin:
+		return &roundRobinBalancer{}, nil
+	case BalancerLeastOutstanding:
+		return &leastOutstandingBalancer{outstanding: make(map[string]*int64)}, nil
+	case BalancerConsistentHash:
+		return &consistentHashBalancer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown balancer %q", strategy)
+	}
+}
+
+func noop() {}
+
+// sortedByID returns a copy of instances in a stable order, since Consul
+// does not guarantee one between queries
+func sortedByID(instances []*consul.ServiceInstance) []*consul.ServiceInstance {
+	sorted := make([]*consul.ServiceInstance, len(instances))
+	copy(sorted, instances)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	return sorted
+}
+
+// randomBalancer picks instances in random order
+type randomBalancer struct{}
+
+func (b *randomBalancer) Order(instances []*consul.ServiceInstance, key string) []*consul.ServiceInstance {
+	order := make([]*consul.ServiceInstance, len(instances))
+	copy(order, instances)
+	rand.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+	return order
+}
+
+func (b *randomBalancer) Acquire(instance *consul.ServiceInstance) func() { return noop }
+
+// roundRobinBalancer cycles through instances in ID order
+type roundRobinBalancer struct {
+	next atomic.Uint64
+}
+
+func (b *roundRobinBalancer) Order(instances []*consul.ServiceInstance, key string) []*consul.ServiceInstance {
+	sorted := sortedByID(instances)
+	start := int(b.next.Add(1)-1) % len(sorted)
+	return append(sorted[start:], sorted[:start]...)
+}
+
+func (b *roundRobinBalancer) Acquire(instance *consul.ServiceInstance) func() { return noop }
+
+// leastOutstandingBalancer prefers the instance with the fewest requests in flight
+type leastOutstandingBalancer struct {
+	mu          sync.Mutex
+	outstanding map[string]*int64
+}
+
+func (b *leastOutstandingBalancer) counter(id string) *int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n, ok := b.outstanding[id]
+	if !ok {
+		n = new(int64)
+		b.outstanding[id] = n
+	}
+	return n
+}
+
+func (b *leastOutstandingBalancer) Order(instances []*consul.ServiceInstance, key string) []*consul.ServiceInstance {
+	// Shuffle first so ties are broken randomly
+	order := (&randomBalancer{}).Order(instances, key)
+
+	counts := make(map[string]int64, len(order))
+	for _, instance := range order {
+		counts[instance.ID] = atomic.LoadInt64(b.counter(instance.ID))
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return counts[order[i].ID] < counts[order[j].ID]
+	})
+	return order
+}
+
+func (b *leastOutstandingBalancer) Acquire(instance *consul.ServiceInstance) func() {
+	n := b.counter(instance.ID)
+	atomic.AddInt64(n, 1)
+	return func() { atomic.AddInt64(n, -1) }
+}
+
+// hashReplicas is the number of points each instance gets on the hash ring.
+// More points spread keys more evenly between instances.
+const hashReplicas = 100
+
+// consistentHashBalancer sends the same key to the same instance for as long
+// as the instance set is unchanged. When an instance joins or leaves only
+// the keys on its part of the ring move.
+type consistentHashBalancer struct {
+	mu   sync.Mutex
+	ring *hashRing
+}
+
+type hashRing struct {
+	// members identifies the instance set the ring was built for
+	members string
+	points  []uint32
+	owners  map[uint32]*consul.ServiceInstance
+	size    int
+}
+
+func hashKey(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return h.Sum32()
+}
+
+func newHashRing(members string, instances []*consul.ServiceInstance) *hashRing {
+	ring := &hashRing{
+		members: members,
+		points:  make([]uint32, 0, len(instances)*hashReplicas),
+		owners:  make(map[uint32]*consul.ServiceInstance, len(instances)*hashReplicas),
+		size:    len(instances),
+	}
+	for _, instance := range instances {
+		for i := 0; i < hashReplicas; i++ {
+			point := hashKey(instance.ID + "#" + strconv.Itoa(i))
+			if _, taken := ring.owners[point]; taken {
+				continue
+			}
+			ring.owners[point] = instance
+			ring.points = append(ring.points, point)
+		}
+	}
+	sort.Slice(ring.points, func(i, j int) bool { return ring.points[i] < ring.points[j] })
+	return ring
+}
+
+func (b *consistentHashBalancer) ringFor(instances []*consul.ServiceInstance) *hashRing {
+	sorted := sortedByID(instances)
+	ids := make([]string, len(sorted))
+	for i, instance := range sorted {
+		ids[i] = instance.ID
+	}
+	members := strings.Join(ids, ",")
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.ring == nil || b.ring.members != members {
+		b.ring = newHashRing(members, sorted)
+	}
+	return b.ring
+}
+
+func (b *consistentHashBalancer) Order(instances []*consul.ServiceInstance, key string) []*consul.ServiceInstance {
+	ring := b.ringFor(instances)
+
+	// Walk the ring clockwise from the key; later distinct instances are retry targets
+	start := sort.Search(len(ring.points), func(i int) bool {
+		return ring.points[i] >= hashKey(key)
+	})
+
+	order := make([]*consul.ServiceInstance, 0, ring.size)
+	seen := make(map[string]bool, ring.size)
+	for i := 0; i < len(ring.points) && len(order) < ring.size; i++ {
+		instance := ring.owners[ring.points[(start+i)%len(ring.points)]]
+		if !seen[instance.ID] {
+			seen[instance.ID] = true
+			order = append(order, instance)
+		}
+	}
+	return order
+}
+
+func (b *consistentHashBalancer) Acquire(instance *consul.ServiceInstance) func() { return noop }
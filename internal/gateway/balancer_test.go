@@ -0,0 +1,103 @@
+package gateway
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"instant/internal/consul"
+)
+
+func testInstances(n int) []*consul.ServiceInstance {
+	instances := make([]*consul.ServiceInstance, n)
+	for i := range instances {
+		instances[i] = &consul.ServiceInstance{ID: "svc-" + strconv.Itoa(i), Port: 8000 + i}
+	}
+	return instances
+}
+
+func TestRoundRobinBalancer_Cycles(t *testing.T) {
+	b, _ := NewBalancer(BalancerRoundRobin)
+	instances := testInstances(3)
+
+	seen := make(map[string]int)
+	for i := 0; i < 6; i++ {
+		order := b.Order(instances, "")
+		if len(order) != 3 {
+			t.Fatalf("Expected all 3 instances in order, got %d", len(order))
+		}
+		seen[order[0].ID]++
+	}
+
+	for _, instance := range instances {
+		if seen[instance.ID] != 2 {
+			t.Errorf("Expected %s to be picked twice, got %d", instance.ID, seen[instance.ID])
+		}
+	}
+}
+
+func TestLeastOutstandingBalancer_PrefersIdleInstance(t *testing.T) {
+	b, _ := NewBalancer(BalancerLeastOutstanding)
+	instances := testInstances(2)
+
+	done := b.Acquire(instances[0])
+	for i := 0; i < 5; i++ {
+		if order := b.Order(instances, ""); order[0].ID != instances[1].ID {
+			t.Fatalf("Expected idle instance %s first, got %s", instances[1].ID, order[0].ID)
+		}
+	}
+	done()
+
+	done = b.Acquire(instances[1])
+	defer done()
+	if order := b.Order(instances, ""); order[0].ID != instances[0].ID {
+		t.Errorf("Expected %s first after release, got %s", instances[0].ID, order[0].ID)
+	}
+}
+
+func TestConsistentHashBalancer_StableAndMinimalMovement(t *testing.T) {
+	b, _ := NewBalancer(BalancerConsistentHash)
+	instances := testInstances(4)
+
+	before := make(map[string]string)
+	for i := 0; i < 200; i++ {
+		key := "user:" + strconv.Itoa(i)
+		first := b.Order(instances, key)[0].ID
+		if again := b.Order(instances, key)[0].ID; again != first {
+			t.Fatalf("Key %s moved from %s to %s without instance changes", key, first, again)
+		}
+		before[key] = first
+	}
+
+	// Removing one instance only moves the keys it owned
+	removed := instances[3].ID
+	for key, owner := range before {
+		now := b.Order(instances[:3], key)[0].ID
+		if owner != removed && now != owner {
+			t.Errorf("Key %s moved from %s to %s although its instance is still up", key, owner, now)
+		}
+	}
+}
+
+func TestNewBalancer_UnknownStrategy(t *testing.T) {
+	if _, err := NewBalancer("fastest"); err == nil {
+		t.Error("Expected error for unknown strategy")
+	}
+}
+
+func TestProxyHandler_RouteBalancerOverridesService(t *testing.T) {
+	cfg := DefaultProxyConfig()
+	cfg.Balancers = map[string]string{"posts-service": BalancerRoundRobin}
+	h := NewProxyHandler(&mockDiscovery{}, nil, cfg)
+	ctx := context.Background()
+
+	if _, ok := h.balancerFor(ctx, "posts-service", "").(*roundRobinBalancer); !ok {
+		t.Error("Expected the service's balancer without a route override")
+	}
+	if _, ok := h.balancerFor(ctx, "posts-service", BalancerConsistentHash).(*consistentHashBalancer); !ok {
+		t.Error("Expected the route's balancer to override the service's")
+	}
+	if h.balancerFor(ctx, "posts-service", "") != h.balancerFor(ctx, "posts-service", BalancerRoundRobin) {
+		t.Error("Expected routes with the same strategy to share a balancer")
+	}
+}
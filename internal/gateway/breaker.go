@@ -0,0 +1,226 @@
+package gateway
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of a circuit breaker
+type BreakerState int
+
+const (
+	// BreakerClosed lets all requests through and counts failures
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects requests until the open timeout has passed
+	BreakerOpen
+	// BreakerHalfOpen lets a limited number of probe requests through
+	BreakerHalfOpen
+)
+
+// String returns the state name used on the admin endpoint
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// ErrCircuitOpen is returned when the breaker rejects a request
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerConfig holds circuit breaker thresholds
+type BreakerConfig struct {
+	// FailureThreshold is how many consecutive failures open the circuit
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before probing
+	OpenTimeout time.Duration
+	// HalfOpenRequests is how many probes may be in flight while half-open
+	HalfOpenRequests int
+}
+
+// CircuitBreaker tracks the health of a single upstream service
+type CircuitBreaker struct {
+	mu sync.Mutex
+
+	name   string
+	config BreakerConfig
+
+	state       BreakerState
+	failures    int
+	inFlight    int
+	openedAt    time.Time
+	lastFailure time.Time
+	lastError   string
+}
+
+// NewCircuitBreaker creates a closed circuit breaker
+func NewCircuitBreaker(name string, config BreakerConfig) *CircuitBreaker {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = 5
+	}
+	if config.OpenTimeout <= 0 {
+		config.OpenTimeout = 30 * time.Second
+	}
+	if config.HalfOpenRequests <= 0 {
+		config.HalfOpenRequests = 1
+	}
+	return &CircuitBreaker{name: name, config: config}
+}
+
+// Allow reports whether a request may be sent to the upstream.
+// Every allowed request must be followed by RecordSuccess or RecordFailure.
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen {
+		if time.Since(b.openedAt) < b.config.OpenTimeout {
+			return ErrCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+		b.inFlight = 0
+	}
+
+	if b.state == BreakerHalfOpen {
+		if b.inFlight >= b.config.HalfOpenRequests {
+			return ErrCircuitOpen
+		}
+	}
+
+	b.inFlight++
+	return nil
+}
+
+// RecordSuccess closes the circuit after a successful request
+func (b *CircuitBreaker) RecordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.release()
+	b.failures = 0
+	b.state = BreakerClosed
+}
+
+// RecordFailure counts a failed request and opens the circuit once the
+// threshold is reached. A failed probe reopens it immediately.
+func (b *CircuitBreaker) RecordFailure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.release()
+	b.failures++
+	b.lastFailure = time.Now()
+	if err != nil {
+		b.lastError = err.Error()
+	}
+
+	if b.state == BreakerHalfOpen || b.failures >= b.config.FailureThreshold {
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// Release frees an allowed request without counting it either way,
+// e.g. when the client went away before the upstream answered
+func (b *CircuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.release()
+}
+
+func (b *CircuitBreaker) release() {
+	if b.inFlight > 0 {
+		b.inFlight--
+	}
+}
+
+// BreakerStatus is a point-in-time view of a breaker for the admin endpoint
+type BreakerStatus struct {
+	Upstream            string     `json:"upstream"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	LastFailure         *time.Time `json:"last_failure,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+// Status returns the current breaker state
+func (b *CircuitBreaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := b.state
+	if state == BreakerOpen && time.Since(b.openedAt) >= b.config.OpenTimeout {
+		state = BreakerHalfOpen
+	}
+
+	status := BreakerStatus{
+		Upstream:            b.name,
+		State:               state.String(),
+		ConsecutiveFailures: b.failures,
+		LastError:           b.lastError,
+	}
+	if state != BreakerClosed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	if !b.lastFailure.IsZero() {
+		lastFailure := b.lastFailure
+		status.LastFailure = &lastFailure
+	}
+	return status
+}
+
+// BreakerRegistry holds one circuit breaker per upstream service
+type BreakerRegistry struct {
+	mu       sync.Mutex
+	config   BreakerConfig
+	breakers map[string]*CircuitBreaker
+}
+
+// NewBreakerRegistry creates an empty registry; breakers are created on first use
+func NewBreakerRegistry(config BreakerConfig) *BreakerRegistry {
+	return &BreakerRegistry{
+		config:   config,
+		breakers: make(map[string]*CircuitBreaker),
+	}
+}
+
+// Get returns the breaker for an upstream, creating it if needed
+func (r *BreakerRegistry) Get(upstream string) *CircuitBreaker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.breakers[upstream]
+	if !ok {
+		b = NewCircuitBreaker(upstream, r.config)
+		r.breakers[upstream] = b
+	}
+	return b
+}
+
+// Statuses returns the state of every known upstream, sorted by name
+func (r *BreakerRegistry) Statuses() []BreakerStatus {
+	r.mu.Lock()
+	breakers := make([]*CircuitBreaker, 0, len(r.breakers))
+	for _, b := range r.breakers {
+		breakers = append(breakers, b)
+	}
+	r.mu.Unlock()
+
+	statuses := make([]BreakerStatus, 0, len(breakers))
+	for _, b := range breakers {
+		statuses = append(statuses, b.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Upstream < statuses[j].Upstream
+	})
+	return statuses
+}
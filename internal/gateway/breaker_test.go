@@ -0,0 +1,192 @@
+package gateway
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"instant/internal/consul"
+	"instant/internal/identity"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Mock discovery returning a fixed set of instances
+type mockDiscovery struct {
+	instances []*consul.ServiceInstance
+}
+
+func (m *mockDiscovery) Discover(serviceName string) ([]*consul.ServiceInstance, error) {
+	if len(m.instances) == 0 {
+		return nil, errors.New("no healthy instances")
+	}
+	return m.instances, nil
+}
+
+func (m *mockDiscovery) DiscoverOne(serviceName string) (*consul.ServiceInstance, error) {
+	instances, err := m.Discover(serviceName)
+	if err != nil {
+		return nil, err
+	}
+	return instances[0], nil
+}
+
+func instanceFor(t *testing.T, id string, server *httptest.Server) *consul.ServiceInstance {
+	t.Helper()
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to parse server address: %v", err)
+	}
+	p, _ := strconv.Atoi(port)
+	return &consul.ServiceInstance{ID: id, Name: "test-service", Address: host, Port: p}
+}
+
+func TestCircuitBreaker_OpensAndRecovers(t *testing.T) {
+	b := NewCircuitBreaker("test-service", BreakerConfig{
+		FailureThreshold: 2,
+		OpenTimeout:      20 * time.Millisecond,
+		HalfOpenRequests: 1,
+	})
+
+	for i := 0; i < 2; i++ {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("Expected closed breaker to allow request: %v", err)
+		}
+		b.RecordFailure(errors.New("boom"))
+	}
+
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if state := b.Status().State; state != "open" {
+		t.Errorf("Expected state open, got %s", state)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	// One probe is allowed while half-open, the next is rejected
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Expected half-open breaker to allow a probe: %v", err)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected second probe to be rejected, got %v", err)
+	}
+
+	b.RecordSuccess()
+	if state := b.Status().State; state != "closed" {
+		t.Errorf("Expected state closed after successful probe, got %s", state)
+	}
+}
+
+func TestCircuitBreaker_FailedProbeReopens(t *testing.T) {
+	b := NewCircuitBreaker("test-service", BreakerConfig{
+		FailureThreshold: 1,
+		OpenTimeout:      10 * time.Millisecond,
+	})
+
+	b.Allow()
+	b.RecordFailure(errors.New("boom"))
+	time.Sleep(20 * time.Millisecond)
+
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Expected probe to be allowed: %v", err)
+	}
+	b.RecordFailure(errors.New("still down"))
+
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected breaker to reopen after failed probe, got %v", err)
+	}
+}
+
+func newTestProxy(t *testing.T, instances ...*consul.ServiceInstance) *ProxyHandler {
+	t.Helper()
+	signer := identity.NewSigner("test-secret-that-is-at-least-32-bytes-long")
+	cfg := DefaultProxyConfig()
+	cfg.Timeout = time.Second
+	return NewProxyHandler(&mockDiscovery{instances: instances}, signer, cfg)
+}
+
+func TestProxy_RetriesIdempotentRequestOnAnotherInstance(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer up.Close()
+
+	h := newTestProxy(t, instanceFor(t, "down", down), instanceFor(t, "up", up))
+	r := gin.New()
+	r.Any("/*path", h.ProxyRequest("test-service"))
+
+	// Whichever order the instances are tried in, GET must end up on the healthy one
+	for i := 0; i < 5; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 after retry, got %d", w.Code)
+		}
+	}
+}
+
+func TestProxy_DoesNotRetryPost(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	calls := 0
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	h := newTestProxy(t, instanceFor(t, "a", down), instanceFor(t, "b", down))
+	r := gin.New()
+	r.Any("/*path", h.ProxyRequest("test-service"))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/items", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected upstream 503 to be passed through, got %d", w.Code)
+	}
+	if calls != 1 {
+		t.Errorf("Expected POST to be sent once, got %d calls", calls)
+	}
+}
+
+func TestProxy_OpenBreakerRejects(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	calls := 0
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+
+	h := newTestProxy(t, instanceFor(t, "a", down))
+	r := gin.New()
+	r.Any("/*path", h.ProxyRequest("test-service"))
+
+	threshold := DefaultProxyConfig().Breaker.FailureThreshold
+	for i := 0; i < threshold; i++ {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/items", nil))
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 from open breaker, got %d", w.Code)
+	}
+	if calls != threshold {
+		t.Errorf("Expected %d upstream calls, got %d", threshold, calls)
+	}
+}
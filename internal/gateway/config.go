@@ -0,0 +1,226 @@
+package gateway
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"instant/internal/config"
+	"instant/internal/identity"
+	"instant/internal/session"
+)
+
+// RouterConfig holds everything NewRouter needs besides discovery and sessions
+type RouterConfig struct {
+	// Routes are the proxied routes; Router.Reload replaces them
+	Routes RouteTable
+	// Signer signs the identity forwarded to backend services
+	Signer *identity.Signer
+	// Cookies verifies session cookies before any session lookup
+	Cookies *session.CookieSigner
+	// RateLimiter enforces RateLimits; nil disables rate limiting
+	RateLimiter RateLimiter
+	RateLimits  RateLimitConfig
+	// Proxy configures upstream timeouts, retries and circuit breakers
+	Proxy ProxyConfig
+	// AdminToken protects /admin/*; the admin endpoints are not registered when empty
+	AdminToken string
+	// CORS is the cross-origin policy; the zero value allows no origins
+	CORS config.CORSConfig
+	// TrustedProxies are the IPs and CIDRs whose X-Forwarded-For is believed
+	// when resolving the client IP; empty means the connection's address is used
+	TrustedProxies []string
+}
+
+// LoadTrustedProxies reads GATEWAY_TRUSTED_PROXIES, a comma-separated list of
+// IPs and CIDRs of load balancers in front of the gateway
+func LoadTrustedProxies() ([]string, error) {
+	var proxies []string
+	for _, entry := range strings.Split(os.Getenv("GATEWAY_TRUSTED_PROXIES"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(entry); err != nil && net.ParseIP(entry) == nil {
+			return nil, fmt.Errorf("GATEWAY_TRUSTED_PROXIES: invalid IP or CIDR %q", entry)
+		}
+		proxies = append(proxies, entry)
+	}
+	return proxies, nil
+}
+
+// ProxyConfig controls how the gateway talks to upstream services
+type ProxyConfig struct {
+	// Timeout bounds a single attempt against one upstream instance
+	Timeout time.Duration
+	// Timeouts overrides Timeout per service name
+	Timeouts map[string]time.Duration
+	// DialTimeout bounds establishing the TCP connection
+	DialTimeout time.Duration
+	// StreamIdleTimeout closes WebSocket and SSE streams nothing has been
+	// sent over in either direction for this long
+	StreamIdleTimeout time.Duration
+	// MaxRetries is how many other instances an idempotent request is retried on
+	MaxRetries int
+	// Breaker configures the per-upstream circuit breakers
+	Breaker BreakerConfig
+	// Balancer is the default load-balancing strategy (see NewBalancer)
+	Balancer string
+	// Balancers overrides Balancer per service name
+	Balancers map[string]string
+}
+
+// DefaultProxyConfig returns the proxy settings used when nothing is configured
+func DefaultProxyConfig() ProxyConfig {
+	return ProxyConfig{
+		Timeout:           10 * time.Second,
+		Timeouts:          map[string]time.Duration{},
+		DialTimeout:       3 * time.Second,
+		StreamIdleTimeout: 5 * time.Minute,
+		MaxRetries:        2,
+		Breaker: BreakerConfig{
+			FailureThreshold: 5,
+			OpenTimeout:      30 * time.Second,
+			HalfOpenRequests: 1,
+		},
+		Balancer: BalancerRandom,
+		// Same user, same instance: better cache locality in posts-service,
+		// and the services that pin reads to the primary after a user writes
+		// keep that state in process memory (database.WriteTracker)
+		Balancers: map[string]string{
+			"posts-service":    BalancerConsistentHash,
+			"likes-service":    BalancerConsistentHash,
+			"follow-service":   BalancerConsistentHash,
+			"comments-service": BalancerConsistentHash,
+		},
+	}
+}
+
+// TimeoutFor returns the per-attempt timeout for a service
+func (c ProxyConfig) TimeoutFor(serviceName string) time.Duration {
+	if t, ok := c.Timeouts[serviceName]; ok {
+		return t
+	}
+	return c.Timeout
+}
+
+// BalancerFor returns the load-balancing strategy for a service
+func (c ProxyConfig) BalancerFor(serviceName string) string {
+	if b, ok := c.Balancers[serviceName]; ok {
+		return b
+	}
+	return c.Balancer
+}
+
+// LoadProxyConfig reads proxy settings from environment variables:
+// UPSTREAM_TIMEOUT, UPSTREAM_TIMEOUTS ("files-service=60s,posts-service=5s"),
+// UPSTREAM_DIAL_TIMEOUT, UPSTREAM_STREAM_IDLE_TIMEOUT, UPSTREAM_MAX_RETRIES,
+// UPSTREAM_BALANCER, UPSTREAM_BALANCERS ("posts-service=consistent-hash"),
+// BREAKER_FAILURE_THRESHOLD, BREAKER_OPEN_TIMEOUT and BREAKER_HALF_OPEN_REQUESTS
+func LoadProxyConfig() (ProxyConfig, error) {
+	cfg := DefaultProxyConfig()
+
+	durations := []struct {
+		env   string
+		value *time.Duration
+	}{
+		{"UPSTREAM_TIMEOUT", &cfg.Timeout},
+		{"UPSTREAM_DIAL_TIMEOUT", &cfg.DialTimeout},
+		{"UPSTREAM_STREAM_IDLE_TIMEOUT", &cfg.StreamIdleTimeout},
+		{"BREAKER_OPEN_TIMEOUT", &cfg.Breaker.OpenTimeout},
+	}
+	for _, d := range durations {
+		v := os.Getenv(d.env)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed <= 0 {
+			return cfg, fmt.Errorf("%s: invalid duration %q", d.env, v)
+		}
+		*d.value = parsed
+	}
+
+	ints := []struct {
+		env   string
+		value *int
+		min   int
+	}{
+		{"UPSTREAM_MAX_RETRIES", &cfg.MaxRetries, 0},
+		{"BREAKER_FAILURE_THRESHOLD", &cfg.Breaker.FailureThreshold, 1},
+		{"BREAKER_HALF_OPEN_REQUESTS", &cfg.Breaker.HalfOpenRequests, 1},
+	}
+	for _, n := range ints {
+		v := os.Getenv(n.env)
+		if v == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < n.min {
+			return cfg, fmt.Errorf("%s: invalid value %q", n.env, v)
+		}
+		*n.value = parsed
+	}
+
+	timeouts, err := parseServiceMap("UPSTREAM_TIMEOUTS")
+	if err != nil {
+		return cfg, err
+	}
+	for name, value := range timeouts {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return cfg, fmt.Errorf("UPSTREAM_TIMEOUTS: invalid timeout %q for %s", value, name)
+		}
+		cfg.Timeouts[name] = timeout
+	}
+
+	if v := os.Getenv("UPSTREAM_BALANCER"); v != "" {
+		cfg.Balancer = v
+	}
+	balancers, err := parseServiceMap("UPSTREAM_BALANCERS")
+	if err != nil {
+		return cfg, err
+	}
+	for name, strategy := range balancers {
+		cfg.Balancers[name] = strategy
+	}
+
+	// Reject unknown strategies at startup rather than on first request
+	strategies := []string{cfg.Balancer}
+	for _, strategy := range cfg.Balancers {
+		strategies = append(strategies, strategy)
+	}
+	for _, strategy := range strategies {
+		if _, err := NewBalancer(strategy); err != nil {
+			return cfg, err
+		}
+	}
+
+	return cfg, nil
+}
+
+// parseServiceMap reads an env var written as "service=value,service=value"
+func parseServiceMap(env string) (map[string]string, error) {
+	values := make(map[string]string)
+
+	v := os.Getenv(env)
+	if v == "" {
+		return values, nil
+	}
+	for _, entry := range strings.Split(v, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(entry, "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || name == "" || value == "" {
+			return nil, fmt.Errorf("%s: invalid entry %q", env, entry)
+		}
+		values[name] = value
+	}
+	return values, nil
+}
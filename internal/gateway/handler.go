@@ -1,123 +1,320 @@
 package gateway
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httputil"
-	"net/url"
+	"strconv"
+	"sync"
+	"time"
 
 	"instant/internal/consul"
+	"instant/internal/identity"
+	"instant/internal/logger"
+	"instant/internal/metrics"
+	"instant/internal/session"
+	"instant/internal/tracing"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// maxRetryBodySize is the largest request body buffered so it can be replayed on retry
+const maxRetryBodySize = 1 << 20
+
 // ProxyHandler handles reverse proxy requests to backend services
 type ProxyHandler struct {
 	discovery consul.ServiceDiscovery
+	signer    *identity.Signer
+	config    ProxyConfig
+	breakers  *BreakerRegistry
+	transport http.RoundTripper
+
+	mu        sync.Mutex
+	balancers map[string]Balancer // by service and strategy
+
+	// streams is canceled by CloseStreams to end every WebSocket and SSE stream
+	streams      context.Context
+	closeStreams context.CancelFunc
 }
 
-// NewProxyHandler creates a new proxy handler
-func NewProxyHandler(discovery consul.ServiceDiscovery) *ProxyHandler {
+// NewProxyHandler creates a new proxy handler.
+// The signer signs the identity forwarded to backend services.
+func NewProxyHandler(discovery consul.ServiceDiscovery, signer *identity.Signer, config ProxyConfig) *ProxyHandler {
+	// One transport for all upstreams so connections are pooled across requests
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   config.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:        200,
+		MaxIdleConnsPerHost: 50,
+		IdleConnTimeout:     90 * time.Second,
+	}
+
+	streams, closeStreams := context.WithCancel(context.Background())
+
 	return &ProxyHandler{
-		discovery: discovery,
+		discovery:    discovery,
+		signer:       signer,
+		config:       config,
+		breakers:     NewBreakerRegistry(config.Breaker),
+		transport:    transport,
+		balancers:    make(map[string]Balancer),
+		streams:      streams,
+		closeStreams: closeStreams,
 	}
 }
 
+// CloseStreams ends every proxied WebSocket and SSE stream, e.g. on shutdown,
+// which would otherwise wait for them
+func (h *ProxyHandler) CloseStreams() {
+	h.closeStreams()
+}
+
 // ProxyRequest creates a handler that proxies requests to the specified service
 func (h *ProxyHandler) ProxyRequest(serviceName string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Discover service instance
-		instance, err := h.discovery.DiscoverOne(serviceName)
-		if err != nil {
-			log.Printf("Failed to discover service %s: %v", serviceName, err)
-			c.JSON(http.StatusServiceUnavailable, gin.H{
-				"error": fmt.Sprintf("service %s unavailable", serviceName),
-			})
+		h.proxy(c, Route{Service: serviceName})
+	}
+}
+
+// ProxyWithPathRewrite proxies requests with path rewriting
+// Example: /api/posts/* -> /* on the posts service
+func (h *ProxyHandler) ProxyWithPathRewrite(serviceName, stripPrefix string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h.proxy(c, Route{Service: serviceName, StripPrefix: stripPrefix})
+	}
+}
+
+// ProxyRoute proxies requests matched by a route table entry, with the
+// route's timeouts when it sets them
+func (h *ProxyHandler) ProxyRoute(route Route) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h.proxy(c, route)
+	}
+}
+
+// proxy forwards the request to route.Service through its circuit breaker.
+// Idempotent requests are retried against other instances when the upstream
+// cannot be reached, times out or answers 502/503/504.
+func (h *ProxyHandler) proxy(c *gin.Context, route Route) {
+	serviceName := route.Service
+	c.Set("upstream_service", serviceName)
+
+	// Discover service instances
+	instances, err := h.discovery.Discover(serviceName)
+	if err != nil || len(instances) == 0 {
+		logger.FromContext(c.Request.Context()).Error("Failed to discover service",
+			"upstream_service", serviceName, "error", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": fmt.Sprintf("service %s unavailable", serviceName),
+		})
+		return
+	}
+
+	// The balancer picks the instance; the others in order are retry targets.
+	// Hashing strategies key on the user, or the client IP for anonymous requests.
+	balancer := h.balancerFor(c.Request.Context(), serviceName, route.Balancer)
+	order := balancer.Order(instances, KeyByUserID(c))
+
+	body, retryable := h.replayableBody(c.Request)
+	attempts := 1
+	if retryable {
+		attempts += h.config.MaxRetries
+	}
+	if attempts > len(order) {
+		attempts = len(order)
+	}
+
+	breaker := h.breakers.Get(serviceName)
+	var lastErr error
+
+	for i := 0; i < attempts; i++ {
+		if err := breaker.Allow(); err != nil {
+			lastErr = err
+			break
+		}
+
+		if body != nil {
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		final := i == attempts-1
+		done := balancer.Acquire(order[i])
+		start := time.Now()
+
+		// The attempt is settled as soon as its response is accepted, as
+		// streams stay open long after that
+		settled := false
+		settle := func(status int) {
+			settled = true
+			metrics.ObserveUpstream(serviceName, status, false, time.Since(start))
+			if isUpstreamFailure(status) {
+				// Passed through to the client as is, but still counts against the upstream
+				breaker.RecordFailure(fmt.Errorf("upstream returned %d", status))
+			} else {
+				breaker.RecordSuccess()
+			}
+		}
+
+		err := h.forward(c, route, order[i], final, settle)
+		done()
+		if settled {
+			// Only a failed protocol switch gets here with an error
+			if err != nil && !c.Writer.Written() {
+				logger.FromContext(c.Request.Context()).Error("Proxy error", "upstream_service", serviceName, "error", err)
+				c.JSON(http.StatusBadGateway, gin.H{
+					"error": "bad gateway",
+				})
+			}
 			return
 		}
+		metrics.ObserveUpstream(serviceName, 0, errors.Is(err, context.DeadlineExceeded), time.Since(start))
 
-		// Build target URL
-		target := fmt.Sprintf("http://%s:%d", instance.Address, instance.Port)
-		targetURL, err := url.Parse(target)
-		if err != nil {
-			log.Printf("Failed to parse target URL %s: %v", target, err)
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "internal server error",
-			})
+		// The client went away; nothing to retry and not the upstream's fault
+		if c.Request.Context().Err() != nil {
+			breaker.Release()
 			return
 		}
 
-		// Create reverse proxy
-		proxy := httputil.NewSingleHostReverseProxy(targetURL)
+		breaker.RecordFailure(err)
+		lastErr = err
 
-		// Customize proxy behavior
-		proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-			log.Printf("Proxy error for %s: %v", serviceName, err)
-			w.WriteHeader(http.StatusBadGateway)
-			w.Write([]byte(`{"error":"bad gateway"}`))
+		if !final {
+			logger.FromContext(c.Request.Context()).Warn("Proxy attempt failed, retrying",
+				"attempt", i+1,
+				"attempts", attempts,
+				"upstream_service", serviceName,
+				"instance", order[i].ID,
+				"error", err)
 		}
+	}
 
-		// Modify request before proxying
-		originalDirector := proxy.Director
-		proxy.Director = func(req *http.Request) {
-			originalDirector(req)
-			// Preserve original path and query
-			req.URL.Scheme = targetURL.Scheme
-			req.URL.Host = targetURL.Host
-			req.Host = targetURL.Host
-
-			// Log the proxy request
-			log.Printf("Proxying %s %s -> %s", req.Method, c.Request.URL.Path, req.URL.String())
-		}
+	logger.FromContext(c.Request.Context()).Error("Proxy error", "upstream_service", serviceName, "error", lastErr)
 
-		// Proxy the request
-		proxy.ServeHTTP(c.Writer, c.Request)
+	switch {
+	case errors.Is(lastErr, ErrCircuitOpen):
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": fmt.Sprintf("service %s unavailable", serviceName),
+		})
+	case errors.Is(lastErr, context.DeadlineExceeded):
+		c.JSON(http.StatusGatewayTimeout, gin.H{
+			"error": "gateway timeout",
+		})
+	default:
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error": "bad gateway",
+		})
 	}
 }
 
-// ProxyWithPathRewrite proxies requests with path rewriting
-// Example: /api/posts/* -> /* on the posts service
-func (h *ProxyHandler) ProxyWithPathRewrite(serviceName, stripPrefix string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Discover service instance
-		instance, err := h.discovery.DiscoverOne(serviceName)
+// balancerFor returns the balancer for a service using strategy, or the
+// service's configured strategy if it is empty, creating it on first use
+func (h *ProxyHandler) balancerFor(ctx context.Context, serviceName, strategy string) Balancer {
+	if strategy == "" {
+		strategy = h.config.BalancerFor(serviceName)
+	}
+	key := serviceName + "/" + strategy
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	b, ok := h.balancers[key]
+	if !ok {
+		var err error
+		b, err = NewBalancer(strategy)
 		if err != nil {
-			log.Printf("Failed to discover service %s: %v", serviceName, err)
-			c.JSON(http.StatusServiceUnavailable, gin.H{
-				"error": fmt.Sprintf("service %s unavailable", serviceName),
-			})
-			return
+			logger.FromContext(ctx).Error("Invalid balancer, using random",
+				"upstream_service", serviceName, "error", err)
+			b, _ = NewBalancer(BalancerRandom)
 		}
+		h.balancers[key] = b
+	}
+	return b
+}
 
-		// Build target URL
-		target := fmt.Sprintf("http://%s:%d", instance.Address, instance.Port)
-		targetURL, err := url.Parse(target)
-		if err != nil {
-			log.Printf("Failed to parse target URL %s: %v", target, err)
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "internal server error",
-			})
-			return
+// forward sends a single attempt to one instance. settle is called with
+// the status once the upstream's response is accepted; an error before that
+// means nothing was written to the client and the attempt may be retried.
+// Upstream 502/503/504 responses are turned into errors unless this is the
+// final attempt, in which case they are passed through.
+//
+// WebSocket and SSE requests are bounded by the upstream timeout only until
+// the stream is established, then by the stream idle timeout.
+func (h *ProxyHandler) forward(c *gin.Context, route Route, instance *consul.ServiceInstance, final bool, settle func(status int)) error {
+	serviceName, stripPrefix := route.Service, route.StripPrefix
+	targetHost := net.JoinHostPort(instance.Address, strconv.Itoa(instance.Port))
+
+	timeout := route.Timeout
+	if timeout <= 0 {
+		timeout = h.config.TimeoutFor(serviceName)
+	}
+
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+		w      http.ResponseWriter = c.Writer
+		st     *stream
+	)
+	if kind := streamKind(c.Request); kind != "" {
+		idleTimeout := route.IdleTimeout
+		if idleTimeout <= 0 {
+			idleTimeout = h.config.StreamIdleTimeout
 		}
+		st = h.openStream(c.Request.Context(), kind, serviceName, timeout, idleTimeout)
+		defer st.close()
+		ctx, cancel, w = st.ctx, st.cancel, st.writer(c.Writer)
 
-		// Create reverse proxy
-		proxy := httputil.NewSingleHostReverseProxy(targetURL)
+		// Streams end by aborting the copy when the client goes away, the
+		// idle timeout passes or the gateway shuts down; that is not a failure
+		defer func() {
+			if p := recover(); p != nil && p != http.ErrAbortHandler {
+				panic(p)
+			}
+		}()
+	} else {
+		ctx, cancel = context.WithTimeout(c.Request.Context(), timeout)
+	}
+	defer cancel()
+
+	// One client span per attempt; its context is what the upstream continues
+	ctx, span := otel.Tracer("instant/internal/gateway").Start(ctx, "proxy "+serviceName,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.ServicePeerName(serviceName),
+			semconv.ServerAddress(instance.Address),
+			semconv.ServerPort(instance.Port),
+		))
 
-		// Error handler
-		proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-			log.Printf("Proxy error for %s: %v", serviceName, err)
-			w.WriteHeader(http.StatusBadGateway)
-			w.Write([]byte(`{"error":"bad gateway"}`))
+	var (
+		status   int
+		proxyErr error
+	)
+	defer func() {
+		if status != 0 {
+			span.SetAttributes(semconv.HTTPResponseStatusCode(status))
 		}
+		tracing.End(span, proxyErr)
+	}()
 
-		// Modify request with path rewriting
-		originalDirector := proxy.Director
-		proxy.Director = func(req *http.Request) {
-			originalDirector(req)
-			req.URL.Scheme = targetURL.Scheme
-			req.URL.Host = targetURL.Host
-			req.Host = targetURL.Host
+	proxy := &httputil.ReverseProxy{
+		Transport: h.transport,
+		Director: func(req *http.Request) {
+			req.URL.Scheme = "http"
+			req.URL.Host = targetHost
+			req.Host = targetHost
+			h.forwardIdentity(c, req)
+			tracing.Inject(ctx, req.Header)
+			req.Header.Set(logger.RequestIDHeader, c.GetString("request_id"))
 
 			// Strip prefix if provided
 			if stripPrefix != "" {
@@ -125,17 +322,87 @@ func (h *ProxyHandler) ProxyWithPathRewrite(serviceName, stripPrefix string) gin
 				if req.URL.Path == "" {
 					req.URL.Path = "/"
 				}
+				req.URL.RawPath = ""
 			}
 
-			log.Printf("Proxying %s %s -> %s%s",
-				req.Method, c.Request.URL.Path, req.URL.Host, req.URL.Path)
-		}
+			logger.FromContext(c.Request.Context()).Debug("Proxying request",
+				"method", req.Method,
+				"path", c.Request.URL.Path,
+				"target", req.URL.Host+req.URL.Path)
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			status = resp.StatusCode
+			if !final && isUpstreamFailure(resp.StatusCode) {
+				return fmt.Errorf("upstream returned %d", resp.StatusCode)
+			}
+			if st != nil {
+				st.established(w, resp)
+			}
+			settle(resp.StatusCode)
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			proxyErr = err
+		},
+	}
+
+	proxy.ServeHTTP(w, c.Request.WithContext(ctx))
+
+	if proxyErr != nil && st != nil && st.timedOut.Load() {
+		proxyErr = context.DeadlineExceeded
+	}
+	return proxyErr
+}
+
+// replayableBody buffers the request body of idempotent requests so the
+// request can be retried. It reports false for requests that must not be retried.
+func (h *ProxyHandler) replayableBody(req *http.Request) ([]byte, bool) {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+	default:
+		return nil, false
+	}
+
+	if req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0 {
+		return nil, true
+	}
+	if req.ContentLength < 0 || req.ContentLength > maxRetryBodySize {
+		return nil, false
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		return nil, false
+	}
+	return body, true
+}
+
+// isUpstreamFailure reports whether a status means the upstream itself is unhealthy
+func isUpstreamFailure(status int) bool {
+	return status == http.StatusBadGateway ||
+		status == http.StatusServiceUnavailable ||
+		status == http.StatusGatewayTimeout
+}
 
-		// Proxy the request
-		proxy.ServeHTTP(c.Writer, c.Request)
+// forwardIdentity replaces any identity headers on the outgoing request with
+// the signed identity of the session validated by SessionAuthMiddleware
+func (h *ProxyHandler) forwardIdentity(c *gin.Context, req *http.Request) {
+	identity.Strip(req.Header)
+	if sess, ok := c.Get("session"); ok {
+		s := sess.(*session.Session)
+		h.signer.Inject(req.Header, s.UserID, s.Email)
 	}
 }
 
+// Breakers handles GET /admin/breakers and reports every upstream's circuit breaker
+func (h *ProxyHandler) Breakers(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"breakers": h.breakers.Statuses(),
+	})
+}
+
 // Health is the gateway health check handler
 func (h *ProxyHandler) Health(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
@@ -1,72 +1,178 @@
 package gateway
 
 import (
+	"crypto/subtle"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"instant/internal/config"
+	"instant/internal/identity"
+	"instant/internal/logger"
 	"instant/internal/session"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 )
 
-// SessionAuthMiddleware validates session and injects user context
-func SessionAuthMiddleware(sessionMgr session.Manager) gin.HandlerFunc {
+// SessionAuthMiddleware validates session and injects user context.
+// The cookie signature is checked first, so forged or garbage cookies are
+// rejected without a Redis lookup.
+func SessionAuthMiddleware(sessionMgr session.Manager, cookies *session.CookieSigner) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get session ID from cookie
-		sessionID, err := c.Cookie("session_id")
-		if err != nil {
+		sess, reason := authenticate(c, sessionMgr, cookies)
+		if sess == nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "unauthorized: no session cookie",
+				"error": reason,
 			})
 			return
 		}
 
-		// Validate and get session
-		sess, err := sessionMgr.Get(c.Request.Context(), sessionID)
-		if err != nil {
-			slog.Warn("Invalid session",
-				"session_id", sessionID,
-				"error", err.Error(),
-				"request_id", c.GetString("request_id"),
-			)
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "unauthorized: invalid session",
-			})
+		setIdentity(c, sess)
+		c.Next()
+	}
+}
+
+// OptionalSessionAuthMiddleware injects user context when the request has a
+// valid session and lets it through anonymously otherwise
+func OptionalSessionAuthMiddleware(sessionMgr session.Manager, cookies *session.CookieSigner) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// The response may depend on who is signed in; keep shared caches
+		// from serving one visitor's response to another
+		c.Writer.Header().Add("Vary", "Cookie")
+
+		if sess, _ := authenticate(c, sessionMgr, cookies); sess != nil {
+			setIdentity(c, sess)
+		}
+		c.Next()
+	}
+}
+
+// authenticate returns the session of the request, refreshing it when due.
+// Without a valid session it returns nil and the reason for a 401 response.
+func authenticate(c *gin.Context, sessionMgr session.Manager, cookies *session.CookieSigner) (*session.Session, string) {
+	// Get session ID from cookie
+	cookie, err := c.Cookie(session.CookieName)
+	if err != nil {
+		return nil, "unauthorized: no session cookie"
+	}
+
+	sessionID, err := cookies.Verify(cookie)
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Warn("Rejected session cookie", "error", err)
+		return nil, "unauthorized: invalid session"
+	}
+
+	// Validate and get session
+	sess, err := sessionMgr.Get(c.Request.Context(), sessionID)
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Warn("Invalid session",
+			"session_id", sessionID,
+			"error", err.Error(),
+		)
+		return nil, "unauthorized: invalid session"
+	}
+
+	// Double-check expiration (should be caught by Get, but be defensive)
+	if time.Now().After(sess.ExpiresAt) {
+		return nil, "unauthorized: session expired"
+	}
+
+	// Slide the expiry of an active session and rotate its ID when due.
+	// The cookie is issued again so the browser follows the new expiry
+	// and ID; a failed refresh leaves the session as it was.
+	refreshed, changed, err := sessionMgr.Refresh(c.Request.Context(), sess)
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Warn("Failed to refresh session", "error", err)
+	} else if changed {
+		sess = refreshed
+		reissued := cookies.NewCookie(sess)
+		http.SetCookie(c.Writer, reissued)
+		replaceCookie(c.Request, session.CookieName, reissued.Value)
+	}
+
+	return sess, ""
+}
+
+// setIdentity injects the user of a validated session for handlers, logs
+// and the proxied request
+func setIdentity(c *gin.Context, sess *session.Session) {
+	c.Set("session", sess)
+	c.Set("user_id", sess.UserID)
+	c.Request = c.Request.WithContext(logger.WithUserID(c.Request.Context(), sess.UserID))
+
+	// Add headers for proxied requests
+	c.Request.Header.Set("X-User-ID", sess.UserID)
+	c.Request.Header.Set("X-User-Email", sess.Email)
+}
+
+// CSRFMiddleware rejects state-changing requests that do not carry the CSRF
+// token of the session in the X-CSRF-Token header. Cross-site pages can make
+// the browser send the session cookie but cannot read the token, which the
+// client gets from POST /auth/verify-code or GET /auth/csrf. Anonymous
+// requests let through by OptionalSessionAuthMiddleware act as nobody and
+// need no token. Must run after the session middleware.
+func CSRFMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
 			return
 		}
 
-		// Double-check expiration (should be caught by Get, but be defensive)
-		if time.Now().After(sess.ExpiresAt) {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "unauthorized: session expired",
+		value, authenticated := c.Get("session")
+		if !authenticated {
+			c.Next()
+			return
+		}
+
+		sess, ok := value.(*session.Session)
+		if !ok || !sess.CheckCSRFToken(c.GetHeader(session.CSRFHeader)) {
+			logger.FromContext(c.Request.Context()).Warn("Rejected request without valid CSRF token",
+				"method", c.Request.Method,
+				"path", c.Request.URL.Path,
+			)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "forbidden: invalid CSRF token",
 			})
 			return
 		}
 
-		// Inject user context for downstream services
-		c.Set("user_id", sess.UserID)
-		c.Set("email", sess.Email)
+		c.Next()
+	}
+}
 
-		// Add headers for proxied requests
-		c.Request.Header.Set("X-User-ID", sess.UserID)
-		c.Request.Header.Set("X-User-Email", sess.Email)
+// replaceCookie sets the value of a cookie on a request, so upstream services
+// see the rotated session ID rather than the one the client sent
+func replaceCookie(req *http.Request, name, value string) {
+	cookies := req.Cookies()
+	req.Header.Del("Cookie")
+	for _, cookie := range cookies {
+		if cookie.Name == name {
+			cookie.Value = value
+		}
+		req.AddCookie(cookie)
+	}
+}
 
+// StripIdentityMiddleware removes identity headers sent by the client.
+// Only the gateway may set them, after validating the session.
+func StripIdentityMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity.Strip(c.Request.Header)
 		c.Next()
 	}
 }
 
-// CORSMiddleware handles CORS for the gateway
-func CORSMiddleware() gin.HandlerFunc {
+// AdminAuthMiddleware protects admin endpoints with a static bearer token
+func AdminAuthMiddleware(token string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "http://localhost:5173")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusNoContent)
+		provided := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "unauthorized",
+			})
 			return
 		}
 
@@ -74,22 +180,66 @@ func CORSMiddleware() gin.HandlerFunc {
 	}
 }
 
-// RequestIDMiddleware generates a unique request ID for distributed tracing
-func RequestIDMiddleware() gin.HandlerFunc {
+// CORSMiddleware applies the CORS policy for the request path. Allowed
+// origins are echoed back rather than answered with "*", so credentialed
+// requests work. The gateway answers every preflight itself; OPTIONS requests
+// are never proxied, so backend services need no CORS handling.
+func CORSMiddleware(cfg config.CORSConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Generate unique request ID
-		requestID := uuid.New().String()
+		origin := c.GetHeader("Origin")
+		policy := cfg.PolicyFor(c.Request.URL.Path)
+		allowed := policy.AllowsOrigin(origin)
 
-		// Store in context for downstream use
-		c.Set("request_id", requestID)
+		header := c.Writer.Header()
+		if origin != "" {
+			header.Add("Vary", "Origin")
+		}
+		if allowed {
+			header.Set("Access-Control-Allow-Origin", origin)
+			if policy.AllowCredentials {
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if len(policy.ExposedHeaders) > 0 {
+				header.Set("Access-Control-Expose-Headers", strings.Join(policy.ExposedHeaders, ", "))
+			}
+		}
 
-		// Add to response headers for client correlation
-		c.Writer.Header().Set("X-Request-ID", requestID)
+		// Browsers send cookies with cross-site WebSocket handshakes and CORS
+		// does not cover them, so the session must not be usable from other origins
+		if origin != "" && !allowed && isWebSocketUpgrade(c.Request) {
+			logger.FromContext(c.Request.Context()).Warn("Rejected WebSocket upgrade from disallowed origin",
+				"origin", origin,
+				"path", c.Request.URL.Path,
+			)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "forbidden: origin not allowed",
+			})
+			return
+		}
+
+		if c.Request.Method == http.MethodOptions {
+			if allowed {
+				header.Set("Access-Control-Allow-Methods", strings.Join(policy.AllowedMethods, ", "))
+				header.Set("Access-Control-Allow-Headers", strings.Join(policy.AllowedHeaders, ", "))
+				if policy.MaxAge > 0 {
+					header.Set("Access-Control-Max-Age", strconv.Itoa(int(policy.MaxAge.Seconds())))
+				}
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
 
 		c.Next()
 	}
 }
 
+// RequestIDMiddleware assigns the request ID used to correlate logs and traces.
+// A well-formed X-Request-ID from the client is reused, otherwise a new one is
+// generated. The ID is forwarded to upstream services by the proxy.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return logger.Middleware()
+}
+
 // LoggingMiddleware logs all requests passing through the gateway with structured JSON
 func LoggingMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -138,9 +288,6 @@ func LoggingMiddleware() gin.HandlerFunc {
 		if userID, exists := c.Get("user_id"); exists {
 			attrs = append(attrs, "user_id", userID)
 		}
-		if email, exists := c.Get("email"); exists {
-			attrs = append(attrs, "email", email)
-		}
 
 		// Add upstream service if this was a proxied request
 		if upstreamService, exists := c.Get("upstream_service"); exists {
@@ -6,18 +6,24 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"instant/internal/config"
 	"instant/internal/session"
 
 	"github.com/gin-gonic/gin"
 )
 
+// testCookies signs the session cookies sent in tests
+var testCookies = session.NewCookieSigner("test-session-secret-at-least-32-characters")
+
 // Mock session manager for testing
 type mockSessionManager struct {
 	getFunc      func(ctx context.Context, sessionID string) (*session.Session, error)
 	validateFunc func(ctx context.Context, sessionID string) (bool, error)
+	refreshFunc  func(ctx context.Context, sess *session.Session) (*session.Session, bool, error)
 }
 
 func (m *mockSessionManager) Get(ctx context.Context, sessionID string) (*session.Session, error) {
@@ -27,14 +33,33 @@ func (m *mockSessionManager) Get(ctx context.Context, sessionID string) (*sessio
 	return nil, errors.New("session not found")
 }
 
-func (m *mockSessionManager) Create(ctx context.Context, userID, email string, maxAge int) (string, error) {
-	return "", nil
+func (m *mockSessionManager) Create(ctx context.Context, userID, email string, client session.ClientInfo) (*session.Session, error) {
+	return nil, nil
 }
 
 func (m *mockSessionManager) Delete(ctx context.Context, sessionID string) error {
 	return nil
 }
 
+func (m *mockSessionManager) Refresh(ctx context.Context, sess *session.Session) (*session.Session, bool, error) {
+	if m.refreshFunc != nil {
+		return m.refreshFunc(ctx, sess)
+	}
+	return sess, false, nil
+}
+
+func (m *mockSessionManager) List(ctx context.Context, userID string) ([]*session.Session, error) {
+	return nil, nil
+}
+
+func (m *mockSessionManager) RevokeAll(ctx context.Context, userID string) (int, error) {
+	return 0, nil
+}
+
+func (m *mockSessionManager) CSRFToken(ctx context.Context, sess *session.Session) (string, error) {
+	return sess.CSRFToken, nil
+}
+
 func (m *mockSessionManager) Validate(ctx context.Context, sessionID string) (bool, error) {
 	if m.validateFunc != nil {
 		return m.validateFunc(ctx, sessionID)
@@ -58,19 +83,21 @@ func TestSessionAuthMiddleware_ValidSession(t *testing.T) {
 	}
 
 	r := gin.New()
-	r.Use(SessionAuthMiddleware(mockMgr))
+	r.Use(SessionAuthMiddleware(mockMgr, testCookies))
 	r.GET("/test", func(c *gin.Context) {
 		// Check that headers were injected into the request
 		userID := c.Request.Header.Get("X-User-ID")
 		email := c.Request.Header.Get("X-User-Email")
 
-		// Also check Gin context
+		// Also check Gin context; the email is kept out of it so it is not logged
 		userIDCtx, _ := c.Get("user_id")
-		emailCtx, _ := c.Get("email")
+		emailCtx, hasEmail := c.Get("email")
+		if hasEmail {
+			t.Errorf("Expected no email in the context, got %v", emailCtx)
+		}
 
 		c.JSON(http.StatusOK, gin.H{
 			"user_id":      userIDCtx,
-			"email":        emailCtx,
 			"header_user":  userID,
 			"header_email": email,
 		})
@@ -79,7 +106,7 @@ func TestSessionAuthMiddleware_ValidSession(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/test", nil)
 	req.AddCookie(&http.Cookie{
 		Name:  "session_id",
-		Value: "valid-session-id",
+		Value: testCookies.Sign("valid-session-id"),
 	})
 	w := httptest.NewRecorder()
 
@@ -98,9 +125,6 @@ func TestSessionAuthMiddleware_ValidSession(t *testing.T) {
 	if response["user_id"] != "test-user-id" {
 		t.Errorf("Expected user_id to be test-user-id, got %v", response["user_id"])
 	}
-	if response["email"] != "test@example.com" {
-		t.Errorf("Expected email to be test@example.com, got %v", response["email"])
-	}
 	if response["header_user"] != "test-user-id" {
 		t.Errorf("Expected header_user to be test-user-id, got %v", response["header_user"])
 	}
@@ -111,7 +135,7 @@ func TestSessionAuthMiddleware_NoSessionCookie(t *testing.T) {
 
 	mockMgr := &mockSessionManager{}
 	r := gin.New()
-	r.Use(SessionAuthMiddleware(mockMgr))
+	r.Use(SessionAuthMiddleware(mockMgr, testCookies))
 	r.GET("/test", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "success"})
 	})
@@ -137,7 +161,7 @@ func TestSessionAuthMiddleware_InvalidSession(t *testing.T) {
 	}
 
 	r := gin.New()
-	r.Use(SessionAuthMiddleware(mockMgr))
+	r.Use(SessionAuthMiddleware(mockMgr, testCookies))
 	r.GET("/test", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "success"})
 	})
@@ -145,7 +169,7 @@ func TestSessionAuthMiddleware_InvalidSession(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/test", nil)
 	req.AddCookie(&http.Cookie{
 		Name:  "session_id",
-		Value: "invalid-session-id",
+		Value: testCookies.Sign("invalid-session-id"),
 	})
 	w := httptest.NewRecorder()
 
@@ -172,7 +196,7 @@ func TestSessionAuthMiddleware_ExpiredSession(t *testing.T) {
 	}
 
 	r := gin.New()
-	r.Use(SessionAuthMiddleware(mockMgr))
+	r.Use(SessionAuthMiddleware(mockMgr, testCookies))
 	r.GET("/test", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "success"})
 	})
@@ -180,7 +204,7 @@ func TestSessionAuthMiddleware_ExpiredSession(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/test", nil)
 	req.AddCookie(&http.Cookie{
 		Name:  "session_id",
-		Value: "expired-session-id",
+		Value: testCookies.Sign("expired-session-id"),
 	})
 	w := httptest.NewRecorder()
 
@@ -207,7 +231,7 @@ func TestSessionAuthMiddleware_HeaderInjection(t *testing.T) {
 	}
 
 	r := gin.New()
-	r.Use(SessionAuthMiddleware(mockMgr))
+	r.Use(SessionAuthMiddleware(mockMgr, testCookies))
 	r.GET("/test", func(c *gin.Context) {
 		// Check headers that should be injected
 		userID := c.Request.Header.Get("X-User-ID")
@@ -226,7 +250,7 @@ func TestSessionAuthMiddleware_HeaderInjection(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/test", nil)
 	req.AddCookie(&http.Cookie{
 		Name:  "session_id",
-		Value: "valid-session",
+		Value: testCookies.Sign("valid-session"),
 	})
 	w := httptest.NewRecorder()
 
@@ -241,12 +265,13 @@ func TestCORSMiddleware(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	r := gin.New()
-	r.Use(CORSMiddleware())
+	r.Use(CORSMiddleware(config.DefaultCORSConfig()))
 	r.GET("/test", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "success"})
 	})
 
 	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("Origin", "http://localhost:5173")
 	w := httptest.NewRecorder()
 
 	r.ServeHTTP(w, req)
@@ -258,25 +283,89 @@ func TestCORSMiddleware(t *testing.T) {
 	if w.Header().Get("Access-Control-Allow-Credentials") != "true" {
 		t.Error("Expected CORS Allow-Credentials header")
 	}
+	if !strings.Contains(w.Header().Get("Access-Control-Expose-Headers"), "X-Request-ID") {
+		t.Error("Expected X-Request-ID to be exposed")
+	}
+}
+
+func TestCORSMiddleware_UnknownOrigin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(CORSMiddleware(config.DefaultCORSConfig()))
+	r.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Expected no Allow-Origin for an unknown origin, got %q", got)
+	}
+}
+
+func TestCORSMiddleware_WebSocketOrigin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(CORSMiddleware(config.DefaultCORSConfig()))
+	r.GET("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		origin string
+		want   int
+	}{
+		{"http://localhost:5173", http.StatusOK},
+		{"https://evil.example", http.StatusForbidden},
+		{"", http.StatusOK}, // not a browser
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tt.want {
+			t.Errorf("origin %q: expected status %d, got %d", tt.origin, tt.want, w.Code)
+		}
+	}
 }
 
 func TestCORSMiddleware_OPTIONS(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	r := gin.New()
-	r.Use(CORSMiddleware())
+	r.Use(CORSMiddleware(config.DefaultCORSConfig()))
 	r.OPTIONS("/test", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "should not reach here"})
 	})
 
 	req := httptest.NewRequest(http.MethodOptions, "/test", nil)
+	req.Header.Set("Origin", "http://localhost:5173")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPatch)
 	w := httptest.NewRecorder()
 
 	r.ServeHTTP(w, req)
 
 	// OPTIONS should return 204 No Content
 	if w.Code != http.StatusNoContent {
-		t.Errorf("Expected status 204 for OPTIONS, got %d", w.Code)
+		t.Errorf("Expected status 204, got %d", w.Code)
+	}
+	if !strings.Contains(w.Header().Get("Access-Control-Allow-Methods"), http.MethodPatch) {
+		t.Error("Expected PATCH in Allow-Methods")
+	}
+	if w.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Errorf("Expected Max-Age 600, got %q", w.Header().Get("Access-Control-Max-Age"))
 	}
 }
 
@@ -300,3 +389,241 @@ func TestLoggingMiddleware(t *testing.T) {
 	// Note: Logging output would go to stdout, which we're not capturing here
 	// This test just ensures the middleware doesn't break the request flow
 }
+
+func TestSessionAuthMiddleware_RotatedSessionReissuesCookie(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockMgr := &mockSessionManager{
+		getFunc: func(ctx context.Context, sessionID string) (*session.Session, error) {
+			return &session.Session{
+				ID:        sessionID,
+				UserID:    "test-user-id",
+				ExpiresAt: time.Now().Add(time.Hour),
+			}, nil
+		},
+		refreshFunc: func(ctx context.Context, sess *session.Session) (*session.Session, bool, error) {
+			rotated := *sess
+			rotated.ID = "rotated-session-id"
+			rotated.ExpiresAt = time.Now().Add(2 * time.Hour)
+			return &rotated, true, nil
+		},
+	}
+
+	r := gin.New()
+	r.Use(SessionAuthMiddleware(mockMgr, testCookies))
+	var upstreamCookie string
+	r.GET("/test", func(c *gin.Context) {
+		upstreamCookie, _ = c.Cookie(session.CookieName)
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.AddCookie(&http.Cookie{Name: "theme", Value: "dark"})
+	req.AddCookie(&http.Cookie{Name: session.CookieName, Value: testCookies.Sign("old-session-id")})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if id, _ := testCookies.Verify(upstreamCookie); id != "rotated-session-id" {
+		t.Errorf("Expected upstream to see the rotated session, got %q", upstreamCookie)
+	}
+
+	var issued *http.Cookie
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == session.CookieName {
+			issued = cookie
+		}
+	}
+	if issued == nil || issued.Value != testCookies.Sign("rotated-session-id") {
+		t.Fatalf("Expected the rotated session cookie to be issued, got %+v", issued)
+	}
+	if issued.MaxAge < int(time.Hour.Seconds()) {
+		t.Errorf("Expected the cookie to follow the extended expiry, got max age %d", issued.MaxAge)
+	}
+}
+
+func TestSessionAuthMiddleware_RejectsUnsignedCookieWithoutLookup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	lookups := 0
+	mockMgr := &mockSessionManager{
+		getFunc: func(ctx context.Context, sessionID string) (*session.Session, error) {
+			lookups++
+			return &session.Session{ID: sessionID, UserID: "test-user-id", ExpiresAt: time.Now().Add(time.Hour)}, nil
+		},
+	}
+
+	r := gin.New()
+	r.Use(SessionAuthMiddleware(mockMgr, testCookies))
+	r.GET("/test", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	other := session.NewCookieSigner("another-session-secret-of-32-characters")
+	signed := testCookies.Sign("valid-session-id")
+	for _, value := range []string{
+		"valid-session-id",
+		other.Sign("valid-session-id"),
+		signed[:len(signed)-2] + "xx",
+		"forged-id" + signed[len("valid-session-id"):],
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.AddCookie(&http.Cookie{Name: session.CookieName, Value: value})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for cookie %q, got %d", value, w.Code)
+		}
+	}
+	if lookups != 0 {
+		t.Errorf("Expected no session lookups for bad cookies, got %d", lookups)
+	}
+}
+
+func TestCSRFMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockMgr := &mockSessionManager{
+		getFunc: func(ctx context.Context, sessionID string) (*session.Session, error) {
+			return &session.Session{
+				ID:        sessionID,
+				UserID:    "test-user-id",
+				ExpiresAt: time.Now().Add(time.Hour),
+				CSRFToken: "csrf-token",
+			}, nil
+		},
+	}
+
+	r := gin.New()
+	r.Use(SessionAuthMiddleware(mockMgr, testCookies), CSRFMiddleware())
+	r.Any("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		method string
+		token  string
+		want   int
+	}{
+		{http.MethodGet, "", http.StatusOK},
+		{http.MethodPost, "", http.StatusForbidden},
+		{http.MethodPost, "wrong-token", http.StatusForbidden},
+		{http.MethodDelete, "csrf-token", http.StatusOK},
+		{http.MethodPatch, "csrf-token", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/test", nil)
+		req.AddCookie(&http.Cookie{Name: "session_id", Value: testCookies.Sign("valid-session-id")})
+		if tt.token != "" {
+			req.Header.Set(session.CSRFHeader, tt.token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tt.want {
+			t.Errorf("%s with token %q: expected status %d, got %d", tt.method, tt.token, tt.want, w.Code)
+		}
+	}
+}
+
+func TestCSRFMiddleware_SessionWithoutToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Sessions created before CSRF tokens existed must fetch one first
+	mockMgr := &mockSessionManager{
+		getFunc: func(ctx context.Context, sessionID string) (*session.Session, error) {
+			return &session.Session{ID: sessionID, ExpiresAt: time.Now().Add(time.Hour)}, nil
+		},
+	}
+
+	r := gin.New()
+	r.Use(SessionAuthMiddleware(mockMgr, testCookies), CSRFMiddleware())
+	r.POST("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/test", nil)
+	req.AddCookie(&http.Cookie{Name: "session_id", Value: testCookies.Sign("valid-session-id")})
+	req.Header.Set(session.CSRFHeader, "")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", w.Code)
+	}
+}
+
+func TestOptionalSessionAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockMgr := &mockSessionManager{
+		getFunc: func(ctx context.Context, sessionID string) (*session.Session, error) {
+			if sessionID != "valid-session-id" {
+				return nil, errors.New("session not found")
+			}
+			return &session.Session{
+				ID:        sessionID,
+				UserID:    "test-user-id",
+				Email:     "test@example.com",
+				ExpiresAt: time.Now().Add(1 * time.Hour),
+			}, nil
+		},
+	}
+
+	r := gin.New()
+	r.Use(OptionalSessionAuthMiddleware(mockMgr, testCookies))
+	r.GET("/test", func(c *gin.Context) {
+		c.String(http.StatusOK, c.Request.Header.Get("X-User-ID"))
+	})
+
+	tests := []struct {
+		name   string
+		cookie string
+		want   string
+	}{
+		{name: "valid session", cookie: testCookies.Sign("valid-session-id"), want: "test-user-id"},
+		{name: "no cookie"},
+		{name: "unknown session", cookie: testCookies.Sign("other-session-id")},
+		{name: "unsigned cookie", cookie: "valid-session-id"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		if tt.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "session_id", Value: tt.cookie})
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d", tt.name, w.Code)
+		}
+		if got := w.Body.String(); got != tt.want {
+			t.Errorf("%s: expected user %q, got %q", tt.name, tt.want, got)
+		}
+		if w.Header().Get("Vary") != "Cookie" {
+			t.Errorf("%s: expected Vary: Cookie, got %q", tt.name, w.Header().Get("Vary"))
+		}
+	}
+}
+
+func TestOptionalSessionAuthMiddleware_StripsClientIdentity(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(StripIdentityMiddleware(), OptionalSessionAuthMiddleware(&mockSessionManager{}, testCookies))
+	r.GET("/test", func(c *gin.Context) {
+		c.String(http.StatusOK, c.Request.Header.Get("X-User-ID"))
+	})
+
+	// Anonymous requests must not be able to claim an identity themselves
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("X-User-ID", "spoofed-user")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if got := w.Body.String(); got != "" {
+		t.Errorf("Expected no user for an anonymous request, got %q", got)
+	}
+}
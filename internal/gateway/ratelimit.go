@@ -0,0 +1,243 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"instant/internal/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// RateLimit allows Requests requests per sliding Window
+type RateLimit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// Enabled reports whether the limit should be enforced
+func (l RateLimit) Enabled() bool {
+	return l.Requests > 0 && l.Window > 0
+}
+
+// String formats the limit the same way ParseRateLimit reads it
+func (l RateLimit) String() string {
+	return fmt.Sprintf("%d/%s", l.Requests, l.Window)
+}
+
+// ParseRateLimit parses a limit written as "<requests>/<window>", e.g. "5/10m".
+// "off" or "0" disables the limit.
+func ParseRateLimit(s string) (RateLimit, error) {
+	s = strings.TrimSpace(s)
+	if s == "off" || s == "0" {
+		return RateLimit{}, nil
+	}
+
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q: expected <requests>/<window>", s)
+	}
+
+	requests, err := strconv.Atoi(parts[0])
+	if err != nil || requests < 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q: bad request count", s)
+	}
+	window, err := time.ParseDuration(parts[1])
+	if err != nil || window <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q: bad window", s)
+	}
+
+	return RateLimit{Requests: requests, Window: window}, nil
+}
+
+// RateLimitConfig holds the limits for each gateway route group
+type RateLimitConfig struct {
+	// RequestCode limits POST /auth/request-code per client IP.
+	// Every allowed request sends an email, so this is the tightest limit.
+	RequestCode RateLimit
+	// VerifyCode limits POST /auth/verify-code per client IP
+	VerifyCode RateLimit
+	// API limits /api/* per authenticated user
+	API RateLimit
+}
+
+// DefaultRateLimitConfig returns the limits used when nothing is configured
+func DefaultRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		RequestCode: RateLimit{Requests: 5, Window: 10 * time.Minute},
+		VerifyCode:  RateLimit{Requests: 20, Window: 10 * time.Minute},
+		API:         RateLimit{Requests: 300, Window: time.Minute},
+	}
+}
+
+// LoadRateLimitConfig reads per-group limits from environment variables:
+// RATE_LIMIT_REQUEST_CODE, RATE_LIMIT_VERIFY_CODE and RATE_LIMIT_API
+func LoadRateLimitConfig() (RateLimitConfig, error) {
+	cfg := DefaultRateLimitConfig()
+
+	groups := []struct {
+		env   string
+		limit *RateLimit
+	}{
+		{"RATE_LIMIT_REQUEST_CODE", &cfg.RequestCode},
+		{"RATE_LIMIT_VERIFY_CODE", &cfg.VerifyCode},
+		{"RATE_LIMIT_API", &cfg.API},
+	}
+
+	for _, g := range groups {
+		v := os.Getenv(g.env)
+		if v == "" {
+			continue
+		}
+		limit, err := ParseRateLimit(v)
+		if err != nil {
+			return cfg, fmt.Errorf("%s: %w", g.env, err)
+		}
+		*g.limit = limit
+	}
+
+	return cfg, nil
+}
+
+// RateLimitResult is the outcome of a single rate limit check
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining int
+	// ResetAt is when the oldest request in the window expires
+	ResetAt time.Time
+}
+
+// RateLimiter counts requests per key
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, limit RateLimit) (*RateLimitResult, error)
+}
+
+// slidingWindowScript keeps a sorted set of request timestamps per key.
+// It drops entries older than the window, then records the request only if
+// the limit has not been reached yet.
+// Returns {allowed, count, oldest timestamp in ms}.
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', key, now, ARGV[4])
+	count = count + 1
+	allowed = 1
+end
+redis.call('PEXPIRE', key, window)
+
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+local oldestTs = now
+if oldest[2] then
+	oldestTs = tonumber(oldest[2])
+end
+return {allowed, count, oldestTs}
+`)
+
+// redisRateLimiter implements a sliding window log on top of Redis
+type redisRateLimiter struct {
+	client *redis.Client
+}
+
+// NewRedisRateLimiter creates a rate limiter shared by all gateway instances
+func NewRedisRateLimiter(client *redis.Client) RateLimiter {
+	return &redisRateLimiter{client: client}
+}
+
+// Allow records a request for key and reports whether it fits in the limit
+func (l *redisRateLimiter) Allow(ctx context.Context, key string, limit RateLimit) (*RateLimitResult, error) {
+	now := time.Now().UnixMilli()
+	window := limit.Window.Milliseconds()
+
+	res, err := slidingWindowScript.Run(ctx, l.client, []string{"ratelimit:" + key},
+		now, window, limit.Requests, uuid.New().String()).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("rate limit check: %w", err)
+	}
+
+	remaining := limit.Requests - int(res[1])
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return &RateLimitResult{
+		Allowed:   res[0] == 1,
+		Remaining: remaining,
+		ResetAt:   time.UnixMilli(res[2] + window),
+	}, nil
+}
+
+// KeyFunc picks the identity a request is counted against
+type KeyFunc func(c *gin.Context) string
+
+// KeyByIP counts requests per client IP
+func KeyByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// KeyByUserID counts requests per authenticated user, falling back to the
+// client IP when no session was validated
+func KeyByUserID(c *gin.Context) string {
+	if userID := c.GetString("user_id"); userID != "" {
+		return "user:" + userID
+	}
+	return KeyByIP(c)
+}
+
+// RateLimitMiddleware rejects requests over the limit with 429 Too Many Requests.
+// Counters are namespaced by group so each route group has its own budget.
+// If Redis is unavailable the request is let through rather than failing the gateway.
+func RateLimitMiddleware(limiter RateLimiter, group string, limit RateLimit, keyFunc KeyFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limiter == nil || !limit.Enabled() {
+			c.Next()
+			return
+		}
+
+		result, err := limiter.Allow(c.Request.Context(), group+":"+keyFunc(c), limit)
+		if err != nil {
+			logger.FromContext(c.Request.Context()).Warn("Rate limiter unavailable, allowing request",
+				"group", group,
+				"error", err.Error(),
+			)
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit.Requests))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(result.ResetAt.Unix(), 10))
+
+		if !result.Allowed {
+			retryAfter := int(time.Until(result.ResetAt).Seconds() + 0.999)
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+
+			logger.FromContext(c.Request.Context()).Warn("Rate limit exceeded",
+				"group", group,
+				"client_ip", c.ClientIP(),
+			)
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error": "too many requests",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
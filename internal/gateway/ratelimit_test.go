@@ -0,0 +1,207 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"instant/internal/consul"
+	"instant/internal/identity"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Mock rate limiter that counts requests in memory
+type mockRateLimiter struct {
+	counts    map[string]int
+	err       error
+	lastKey   string
+	resetTime time.Time
+}
+
+func newMockRateLimiter() *mockRateLimiter {
+	return &mockRateLimiter{
+		counts:    make(map[string]int),
+		resetTime: time.Now().Add(30 * time.Second),
+	}
+}
+
+func (m *mockRateLimiter) Allow(ctx context.Context, key string, limit RateLimit) (*RateLimitResult, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	m.lastKey = key
+	if m.counts[key] >= limit.Requests {
+		return &RateLimitResult{Allowed: false, Remaining: 0, ResetAt: m.resetTime}, nil
+	}
+	m.counts[key]++
+	return &RateLimitResult{Allowed: true, Remaining: limit.Requests - m.counts[key], ResetAt: m.resetTime}, nil
+}
+
+func TestRateLimitMiddleware_RejectsOverLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	limiter := newMockRateLimiter()
+	r := gin.New()
+	r.Use(RateLimitMiddleware(limiter, "test", RateLimit{Requests: 2, Window: time.Minute}, KeyByIP))
+	r.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+	})
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected status 200, got %d", i+1, w.Code)
+		}
+		if w.Header().Get("X-RateLimit-Limit") != "2" {
+			t.Errorf("Expected X-RateLimit-Limit 2, got %q", w.Header().Get("X-RateLimit-Limit"))
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header")
+	}
+	if w.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("Expected X-RateLimit-Remaining 0, got %q", w.Header().Get("X-RateLimit-Remaining"))
+	}
+	if w.Header().Get("X-RateLimit-Reset") == "" {
+		t.Error("Expected X-RateLimit-Reset header")
+	}
+}
+
+func TestRateLimitMiddleware_KeyByUserID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	limiter := newMockRateLimiter()
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user_id", "test-user-id")
+		c.Next()
+	})
+	r.Use(RateLimitMiddleware(limiter, "api", RateLimit{Requests: 10, Window: time.Minute}, KeyByUserID))
+	r.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+	if limiter.lastKey != "api:user:test-user-id" {
+		t.Errorf("Expected key api:user:test-user-id, got %s", limiter.lastKey)
+	}
+}
+
+func TestRateLimitMiddleware_FailsOpen(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	limiter := newMockRateLimiter()
+	limiter.err = errors.New("redis unavailable")
+
+	r := gin.New()
+	r.Use(RateLimitMiddleware(limiter, "test", RateLimit{Requests: 1, Window: time.Minute}, KeyByIP))
+	r.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 when limiter fails, got %d", w.Code)
+	}
+}
+
+func TestParseRateLimit(t *testing.T) {
+	limit, err := ParseRateLimit("5/10m")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if limit.Requests != 5 || limit.Window != 10*time.Minute {
+		t.Errorf("Expected 5/10m, got %s", limit)
+	}
+
+	off, err := ParseRateLimit("off")
+	if err != nil || off.Enabled() {
+		t.Errorf("Expected disabled limit, got %s (err %v)", off, err)
+	}
+
+	if _, err := ParseRateLimit("five per minute"); err == nil {
+		t.Error("Expected error for malformed limit")
+	}
+}
+
+func TestRouter_RateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	newRouter := func(trusted []string) (*Router, *mockRateLimiter) {
+		limiter := newMockRateLimiter()
+		router, err := NewRouter(&mockDiscovery{instances: []*consul.ServiceInstance{instanceFor(t, "up", upstream)}}, &mockSessionManager{}, RouterConfig{
+			Routes: RouteTable{Routes: []Route{
+				{PathPrefix: "/auth/request-code", Service: "auth-service", StripPrefix: "/auth", Auth: AuthPublic, RateLimit: "request-code"},
+			}},
+			Signer:         identity.NewSigner("test-secret-that-is-at-least-32-bytes-long"),
+			Cookies:        testCookies,
+			RateLimiter:    limiter,
+			RateLimits:     RateLimitConfig{RequestCode: RateLimit{Requests: 2, Window: time.Minute}},
+			Proxy:          DefaultProxyConfig(),
+			TrustedProxies: trusted,
+		})
+		if err != nil {
+			t.Fatalf("NewRouter failed: %v", err)
+		}
+		return router, limiter
+	}
+	send := func(router *Router, forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodPost, "/auth/request-code", nil)
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// A client picking a new X-Forwarded-For per request is still one client
+	router, _ := newRouter(nil)
+	codes := []int{
+		send(router, "198.51.100.1"),
+		send(router, "198.51.100.2"),
+		send(router, "198.51.100.3"),
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK || codes[2] != http.StatusTooManyRequests {
+		t.Errorf("Expected the third spoofed request to be limited, got %v", codes)
+	}
+
+	// Behind a trusted load balancer the forwarded client IP is used
+	router, limiter := newRouter([]string{"192.0.2.0/24"}) // httptest's remote address
+	send(router, "198.51.100.1")
+	if limiter.lastKey != "request-code:ip:198.51.100.1" {
+		t.Errorf("Expected the forwarded IP from a trusted proxy, got key %s", limiter.lastKey)
+	}
+}
+
+func TestLoadTrustedProxies(t *testing.T) {
+	t.Setenv("GATEWAY_TRUSTED_PROXIES", "10.0.0.0/8, 192.0.2.1")
+	proxies, err := LoadTrustedProxies()
+	if err != nil || len(proxies) != 2 {
+		t.Errorf("Expected two trusted proxies, got %v, %v", proxies, err)
+	}
+
+	t.Setenv("GATEWAY_TRUSTED_PROXIES", "10.0.0.0/8,load-balancer")
+	if _, err := LoadTrustedProxies(); err == nil {
+		t.Error("Expected an invalid entry to be rejected")
+	}
+}
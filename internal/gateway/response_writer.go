@@ -1,6 +1,10 @@
 package gateway
 
 import (
+	"bufio"
+	"net"
+	"net/http"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -33,6 +37,26 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// Flush sends buffered data to the client, so proxied streams are not held back
+func (rw *responseWriter) Flush() {
+	rw.ResponseWriter.Flush()
+}
+
+// Hijack hands the connection over for a protocol upgrade; the request is
+// logged as 101 Switching Protocols
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := rw.ResponseWriter.Hijack()
+	if err == nil {
+		rw.status = http.StatusSwitchingProtocols
+	}
+	return conn, brw, err
+}
+
+// Unwrap returns the wrapped writer for http.ResponseController
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // Status returns the captured status code
 func (rw *responseWriter) Status() int {
 	return rw.status
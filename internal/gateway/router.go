@@ -4,108 +4,194 @@
 package gateway
 
 import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
 	"instant/internal/consul"
+	"instant/internal/metrics"
 	"instant/internal/session"
+	"instant/internal/tracing"
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
-// SetupRouter configures and returns the gateway router
-func SetupRouter(consulClient *consul.Client, sessionMgr session.Manager) *gin.Engine {
-	// Set Gin to release mode for production
-	// gin.SetMode(gin.ReleaseMode)
+// routeMethods are the methods a route without Methods is registered for
+var routeMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
 
-	r := gin.New()
+// Router is the gateway's HTTP handler. Proxied routes come from a
+// RouteTable and can be replaced while the gateway runs: requests already
+// being served finish on the routes they started with.
+type Router struct {
+	proxy      *ProxyHandler
+	sessionMgr session.Manager
+	cfg        RouterConfig
 
-	// Global middleware
-	r.Use(gin.Recovery())
-	r.Use(RequestIDMiddleware()) // Must be first to ensure request_id is available
-	r.Use(LoggingMiddleware())
-	r.Use(CORSMiddleware())
+	engine atomic.Pointer[gin.Engine]
+}
 
-	// Create proxy handler
-	proxyHandler := NewProxyHandler(consulClient)
+// NewRouter builds the gateway router with the routes in cfg.Routes.
+// Upstream state such as circuit breakers and balancers is kept across reloads.
+func NewRouter(discovery consul.ServiceDiscovery, sessionMgr session.Manager, cfg RouterConfig) (*Router, error) {
+	r := &Router{
+		proxy:      NewProxyHandler(discovery, cfg.Signer, cfg.Proxy),
+		sessionMgr: sessionMgr,
+		cfg:        cfg,
+	}
 
-	// Gateway health check
-	r.GET("/health", proxyHandler.Health)
+	if err := r.Reload(cfg.Routes); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
 
-	// Swagger documentation
-	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
-
-	// Auth routes - forward to auth service
-	auth := r.Group("/auth")
-	{
-		// Public auth endpoints (no session required)
-		auth.POST("/request-code", proxyHandler.ProxyWithPathRewrite("auth-service", "/auth"))
-		auth.POST("/verify-code", proxyHandler.ProxyWithPathRewrite("auth-service", "/auth"))
-		auth.POST("/logout", proxyHandler.ProxyWithPathRewrite("auth-service", "/auth"))
-
-		// Protected user management endpoints (require valid session)
-		users := auth.Group("/users")
-		users.Use(SessionAuthMiddleware(sessionMgr))
-		{
-			users.PATCH("/:id", proxyHandler.ProxyWithPathRewrite("auth-service", "/auth"))
-			users.GET("/:id/request-delete-code", proxyHandler.ProxyWithPathRewrite("auth-service", "/auth"))
-			users.POST("/:id/delete", proxyHandler.ProxyWithPathRewrite("auth-service", "/auth"))
-		}
+// ServeHTTP serves the request with the current routes
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.engine.Load().ServeHTTP(w, req)
+}
+
+// CloseStreams ends every proxied WebSocket and SSE stream; register it
+// with http.Server.RegisterOnShutdown so shutdown does not wait for them
+func (r *Router) CloseStreams() {
+	r.proxy.CloseStreams()
+}
+
+// Reload switches to a new route table. An invalid table is rejected and
+// the current routes stay in place.
+func (r *Router) Reload(table RouteTable) error {
+	if err := table.Validate(); err != nil {
+		return err
 	}
 
-	// Protected routes - require valid session
-	api := r.Group("/api")
-	api.Use(SessionAuthMiddleware(sessionMgr))
-	{
-		// Posts service
-		// Routes like /api/posts/* -> posts-service/posts/*
-		posts := api.Group("/posts")
-		{
-			posts.Any("/*path", proxyHandler.ProxyWithPathRewrite("posts-service", "/api"))
-			posts.Any("", proxyHandler.ProxyWithPathRewrite("posts-service", "/api"))
-		}
+	engine, err := r.build(table)
+	if err != nil {
+		return err
+	}
 
-		// Users endpoints (for posts service)
-		// Routes like /api/users/:id/posts -> posts-service/users/:id/posts
-		users := api.Group("/users")
-		{
-			users.Any("/*path", proxyHandler.ProxyWithPathRewrite("posts-service", "/api"))
-		}
+	r.engine.Store(engine)
+	return nil
+}
 
-		// Comments service (when implemented)
-		comments := api.Group("/comments")
-		{
-			comments.Any("/*path", proxyHandler.ProxyWithPathRewrite("comments-service", "/api/comments"))
-			comments.Any("", proxyHandler.ProxyRequest("comments-service"))
+// build creates an engine with the fixed gateway endpoints and the routes
+// of table
+func (r *Router) build(table RouteTable) (engine *gin.Engine, err error) {
+	// gin panics on conflicting routes; report that as an invalid table
+	defer func() {
+		if p := recover(); p != nil {
+			engine, err = nil, fmt.Errorf("invalid route table: %v", p)
 		}
+	}()
 
-		// Likes service (when implemented)
-		likes := api.Group("/likes")
-		{
-			likes.Any("/*path", proxyHandler.ProxyWithPathRewrite("likes-service", "/api/likes"))
-			likes.Any("", proxyHandler.ProxyRequest("likes-service"))
-		}
+	// Set Gin to release mode for production
+	// gin.SetMode(gin.ReleaseMode)
+
+	e := gin.New()
+
+	// Client IPs key rate limits and logs: only believe X-Forwarded-For from
+	// our own load balancers, or anyone could pick a fresh IP per request
+	if err := e.SetTrustedProxies(r.cfg.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+
+	// Global middleware
+	e.Use(gin.Recovery())
+	e.Use(RequestIDMiddleware()) // Must be first to ensure request_id is available
+	e.Use(tracing.Middleware())
+	e.Use(LoggingMiddleware())
+	e.Use(metrics.Middleware())
+	e.Use(CORSMiddleware(r.cfg.CORS)) // Answers all preflight requests
+	e.Use(StripIdentityMiddleware())  // Never trust identity headers from clients
+
+	// Gateway health check
+	e.GET("/health", r.proxy.Health)
 
-		// Follow service (when implemented)
-		follow := api.Group("/follow")
+	// Admin endpoints, only exposed when a token is configured
+	if r.cfg.AdminToken != "" {
+		admin := e.Group("/admin")
+		admin.Use(AdminAuthMiddleware(r.cfg.AdminToken))
 		{
-			follow.Any("/*path", proxyHandler.ProxyWithPathRewrite("follow-service", "/api/follow"))
-			follow.Any("", proxyHandler.ProxyRequest("follow-service"))
+			admin.GET("/breakers", r.proxy.Breakers)
 		}
 
-		// Feed service (when implemented)
-		feed := api.Group("/feed")
-		{
-			feed.Any("/*path", proxyHandler.ProxyWithPathRewrite("feed-service", "/api/feed"))
-			feed.Any("", proxyHandler.ProxyRequest("feed-service"))
+		// The gateway is public, so its metrics need the admin token too
+		e.GET(metrics.Path, AdminAuthMiddleware(r.cfg.AdminToken), gin.WrapH(metrics.Handler()))
+	} else {
+		e.GET(metrics.Path, gin.WrapH(metrics.Handler()))
+	}
+
+	// Swagger documentation
+	e.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+
+	// Proxied routes. Routes that list methods take precedence over routes
+	// for all methods on the same prefix.
+	rateLimits := table.rateLimitPolicies(r.cfg.RateLimits)
+	taken := make(map[string]bool) // "METHOD path" -> taken by a route listing methods
+	for _, explicit := range []bool{true, false} {
+		for _, route := range table.Routes {
+			if (len(route.Methods) > 0) != explicit {
+				continue
+			}
+
+			handlers, err := r.handlers(route, rateLimits)
+			if err != nil {
+				return nil, err
+			}
+
+			methods := route.Methods
+			if len(methods) == 0 {
+				methods = routeMethods
+			}
+
+			// The prefix itself and everything below it
+			prefix := strings.TrimSuffix(route.PathPrefix, "/")
+			for _, path := range []string{prefix, prefix + "/*path"} {
+				if path == "" {
+					continue
+				}
+				for _, method := range methods {
+					key := method + " " + path
+					if byExplicit, ok := taken[key]; ok {
+						if explicit || !byExplicit {
+							return nil, fmt.Errorf("route %s: %s is routed twice", route.PathPrefix, key)
+						}
+						continue
+					}
+					taken[key] = explicit
+					e.Handle(method, path, handlers...)
+				}
+			}
 		}
+	}
 
-		// Files service
-		files := api.Group("/files")
-		{
-			files.Any("/*path", proxyHandler.ProxyWithPathRewrite("files-service", "/api"))
-			files.Any("", proxyHandler.ProxyWithPathRewrite("files-service", "/api"))
+	return e, nil
+}
+
+// handlers returns the middleware chain and proxy handler of a route
+func (r *Router) handlers(route Route, rateLimits map[string]rateLimitRule) ([]gin.HandlerFunc, error) {
+	var handlers []gin.HandlerFunc
+
+	// Session routes need the session's CSRF token for changes
+	switch route.Auth {
+	case AuthPublic:
+	case AuthOptional:
+		handlers = append(handlers, OptionalSessionAuthMiddleware(r.sessionMgr, r.cfg.Cookies), CSRFMiddleware())
+	default:
+		handlers = append(handlers, SessionAuthMiddleware(r.sessionMgr, r.cfg.Cookies), CSRFMiddleware())
+	}
+
+	if route.RateLimit != "" {
+		rule, ok := rateLimits[route.RateLimit]
+		if !ok {
+			return nil, fmt.Errorf("route %s: unknown rate limit %q", route.PathPrefix, route.RateLimit)
 		}
+		handlers = append(handlers, RateLimitMiddleware(r.cfg.RateLimiter, route.RateLimit, rule.limit, rule.key))
 	}
 
-	return r
+	return append(handlers, r.proxy.ProxyRoute(route)), nil
 }
@@ -0,0 +1,312 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"instant/internal/consul"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Auth requirements of a route
+const (
+	// AuthPublic proxies without looking at the session
+	AuthPublic = "public"
+	// AuthSession rejects requests without a valid session
+	AuthSession = "session"
+	// AuthOptional forwards the identity of a valid session and lets
+	// anonymous requests through
+	AuthOptional = "optional"
+)
+
+// Rate limit keys of a RateLimitPolicy
+const (
+	RateLimitKeyIP   = "ip"
+	RateLimitKeyUser = "user"
+)
+
+// defaultRoutesPollInterval is how often GATEWAY_ROUTES_FILE is checked for changes
+const defaultRoutesPollInterval = 5 * time.Second
+
+//go:embed routes.yaml
+var defaultRoutes []byte
+
+// Route proxies requests under PathPrefix to an upstream service
+type Route struct {
+	// PathPrefix matches the path itself and every path below it
+	PathPrefix string `yaml:"path_prefix"`
+	// Methods limits the route to these methods; empty means all
+	Methods []string `yaml:"methods"`
+	// Service is the Consul name of the upstream service
+	Service string `yaml:"service"`
+	// StripPrefix is removed from the path before proxying
+	StripPrefix string `yaml:"strip_prefix"`
+	// Auth is AuthPublic, AuthSession or AuthOptional; empty means AuthSession
+	Auth string `yaml:"auth"`
+	// RateLimit names the rate limit policy; empty means no limit
+	RateLimit string `yaml:"rate_limit"`
+	// Timeout overrides the upstream timeout of the service for this route
+	Timeout time.Duration `yaml:"timeout"`
+	// IdleTimeout overrides the stream idle timeout for WebSocket and SSE
+	// requests on this route
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+	// Balancer overrides the load-balancing strategy of the service for this
+	// route (see NewBalancer)
+	Balancer string `yaml:"balancer"`
+}
+
+// RateLimitPolicy is a named rate limit routes can refer to
+type RateLimitPolicy struct {
+	// Limit is written as "<requests>/<window>", see ParseRateLimit
+	Limit string `yaml:"limit"`
+	// Key is RateLimitKeyIP or RateLimitKeyUser
+	Key string `yaml:"key"`
+}
+
+// RouteTable is the set of proxied routes. It is read from YAML or JSON.
+type RouteTable struct {
+	// RateLimits adds or replaces rate limit policies. The policies
+	// request-code, verify-code and api are built from RateLimitConfig.
+	RateLimits map[string]RateLimitPolicy `yaml:"rate_limits"`
+	Routes     []Route                    `yaml:"routes"`
+}
+
+// DefaultRouteTable returns the routes compiled into the gateway
+func DefaultRouteTable() RouteTable {
+	table, err := ParseRouteTable(defaultRoutes)
+	if err != nil {
+		panic(fmt.Sprintf("invalid default route table: %v", err))
+	}
+	return table
+}
+
+// ParseRouteTable reads a route table written in YAML or JSON and checks it.
+// Unknown fields are rejected so typos don't silently change routing.
+func ParseRouteTable(data []byte) (RouteTable, error) {
+	var table RouteTable
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&table); err != nil {
+		return table, fmt.Errorf("failed to parse route table: %w", err)
+	}
+
+	return table, table.Validate()
+}
+
+// Validate checks every route and rate limit policy
+func (t RouteTable) Validate() error {
+	if len(t.Routes) == 0 {
+		return errors.New("route table has no routes")
+	}
+
+	for name, policy := range t.RateLimits {
+		if _, err := ParseRateLimit(policy.Limit); err != nil {
+			return fmt.Errorf("rate limit %s: %w", name, err)
+		}
+		if policy.Key != RateLimitKeyIP && policy.Key != RateLimitKeyUser {
+			return fmt.Errorf("rate limit %s: key must be %s or %s", name, RateLimitKeyIP, RateLimitKeyUser)
+		}
+	}
+
+	for i, route := range t.Routes {
+		name := fmt.Sprintf("route %d (%s)", i, route.PathPrefix)
+
+		if !strings.HasPrefix(route.PathPrefix, "/") {
+			return fmt.Errorf("%s: path_prefix must start with /", name)
+		}
+		if route.Service == "" {
+			return fmt.Errorf("%s: service is required", name)
+		}
+		if route.StripPrefix != "" && !strings.HasPrefix(route.PathPrefix, route.StripPrefix) {
+			return fmt.Errorf("%s: strip_prefix %q is not a prefix of the path", name, route.StripPrefix)
+		}
+		switch route.Auth {
+		case "", AuthPublic, AuthSession, AuthOptional:
+		default:
+			return fmt.Errorf("%s: unknown auth %q", name, route.Auth)
+		}
+		for _, method := range route.Methods {
+			if !validMethod(method) {
+				return fmt.Errorf("%s: unknown method %q", name, method)
+			}
+		}
+		if route.Timeout < 0 || route.IdleTimeout < 0 {
+			return fmt.Errorf("%s: negative timeout", name)
+		}
+		if route.Balancer != "" {
+			if _, err := NewBalancer(route.Balancer); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// rateLimitPolicies returns the policies routes can use: the ones built from
+// limits, replaced or extended by the table's own
+func (t RouteTable) rateLimitPolicies(limits RateLimitConfig) map[string]rateLimitRule {
+	rules := map[string]rateLimitRule{
+		"request-code": {limit: limits.RequestCode, key: KeyByIP},
+		"verify-code":  {limit: limits.VerifyCode, key: KeyByIP},
+		"api":          {limit: limits.API, key: KeyByUserID},
+	}
+
+	for name, policy := range t.RateLimits {
+		limit, _ := ParseRateLimit(policy.Limit) // checked by Validate
+		key := KeyByIP
+		if policy.Key == RateLimitKeyUser {
+			key = KeyByUserID
+		}
+		rules[name] = rateLimitRule{limit: limit, key: key}
+	}
+
+	return rules
+}
+
+// rateLimitRule is a resolved RateLimitPolicy
+type rateLimitRule struct {
+	limit RateLimit
+	key   KeyFunc
+}
+
+// validMethod reports whether method is a method routes can be limited to
+func validMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// RouteSource is where the route table is loaded from. Without a file or
+// Consul key the default table is used and never reloaded.
+type RouteSource struct {
+	// File is a YAML or JSON route table, checked for changes every PollInterval
+	File         string
+	PollInterval time.Duration
+	// ConsulKey is a Consul KV key holding the route table, watched with
+	// blocking queries
+	ConsulKey string
+}
+
+// LoadRouteSource reads GATEWAY_ROUTES_FILE, GATEWAY_ROUTES_POLL_INTERVAL
+// and GATEWAY_ROUTES_CONSUL_KEY
+func LoadRouteSource() (RouteSource, error) {
+	src := RouteSource{
+		File:         os.Getenv("GATEWAY_ROUTES_FILE"),
+		PollInterval: defaultRoutesPollInterval,
+		ConsulKey:    os.Getenv("GATEWAY_ROUTES_CONSUL_KEY"),
+	}
+
+	if src.File != "" && src.ConsulKey != "" {
+		return src, errors.New("GATEWAY_ROUTES_FILE and GATEWAY_ROUTES_CONSUL_KEY are mutually exclusive")
+	}
+
+	if v := os.Getenv("GATEWAY_ROUTES_POLL_INTERVAL"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil || interval <= 0 {
+			return src, fmt.Errorf("GATEWAY_ROUTES_POLL_INTERVAL: invalid duration %q", v)
+		}
+		src.PollInterval = interval
+	}
+
+	return src, nil
+}
+
+// String describes the source for logs
+func (s RouteSource) String() string {
+	switch {
+	case s.File != "":
+		return "file " + s.File
+	case s.ConsulKey != "":
+		return "consul key " + s.ConsulKey
+	default:
+		return "built-in"
+	}
+}
+
+// Load reads the route table from the source
+func (s RouteSource) Load(client *consul.Client) (RouteTable, error) {
+	switch {
+	case s.File != "":
+		data, err := os.ReadFile(s.File)
+		if err != nil {
+			return RouteTable{}, fmt.Errorf("failed to read route table: %w", err)
+		}
+		return ParseRouteTable(data)
+	case s.ConsulKey != "":
+		data, _, err := client.GetKey(s.ConsulKey)
+		if err != nil {
+			return RouteTable{}, fmt.Errorf("failed to read route table: %w", err)
+		}
+		if data == nil {
+			return RouteTable{}, fmt.Errorf("route table key %s does not exist", s.ConsulKey)
+		}
+		return ParseRouteTable(data)
+	default:
+		return DefaultRouteTable(), nil
+	}
+}
+
+// Watch calls apply with the new table whenever the source changes, until
+// ctx is done. Tables that fail to parse or apply are logged and the current
+// routes stay in place.
+func (s RouteSource) Watch(ctx context.Context, client *consul.Client, apply func(RouteTable) error) {
+	update := func(data []byte) {
+		table, err := ParseRouteTable(data)
+		if err == nil {
+			err = apply(table)
+		}
+		if err != nil {
+			slog.Error("Ignoring invalid route table", "source", s.String(), "error", err)
+			return
+		}
+		slog.Info("Route table reloaded", "source", s.String(), "routes", len(table.Routes))
+	}
+
+	switch {
+	case s.File != "":
+		s.pollFile(ctx, update)
+	case s.ConsulKey != "":
+		client.WatchKey(ctx, s.ConsulKey, update)
+	}
+}
+
+// pollFile calls update with the file's content whenever it changes
+func (s RouteSource) pollFile(ctx context.Context, update func([]byte)) {
+	last, _ := os.ReadFile(s.File)
+
+	ticker := time.NewTicker(s.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		data, err := os.ReadFile(s.File)
+		if err != nil {
+			slog.Warn("Failed to read route table, keeping current routes", "file", s.File, "error", err)
+			continue
+		}
+		if bytes.Equal(data, last) {
+			continue
+		}
+		last = data
+		update(data)
+	}
+}
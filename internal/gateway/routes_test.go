@@ -0,0 +1,179 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"instant/internal/consul"
+	"instant/internal/identity"
+	"instant/internal/session"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestRouter routes to a single upstream that echoes the path it got and
+// the user it was called for, and the email of a verified identity
+func newTestRouter(t *testing.T, table RouteTable) *Router {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	signer := identity.NewSigner("test-secret-that-is-at-least-32-bytes-long")
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Upstream-Path", r.URL.Path)
+		w.Header().Set("X-Upstream-User", r.Header.Get("X-User-ID"))
+		if id, err := signer.Verify(r.Header); err == nil {
+			w.Header().Set("X-Upstream-Email", id.Email)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(upstream.Close)
+
+	mockMgr := &mockSessionManager{
+		getFunc: func(ctx context.Context, sessionID string) (*session.Session, error) {
+			return &session.Session{ID: sessionID, UserID: "user-1", Email: "user-1@example.com", ExpiresAt: time.Now().Add(time.Hour)}, nil
+		},
+	}
+
+	router, err := NewRouter(&mockDiscovery{instances: []*consul.ServiceInstance{instanceFor(t, "up", upstream)}}, mockMgr, RouterConfig{
+		Routes:  table,
+		Signer:  signer,
+		Cookies: testCookies,
+		Proxy:   DefaultProxyConfig(),
+	})
+	if err != nil {
+		t.Fatalf("NewRouter failed: %v", err)
+	}
+	return router
+}
+
+func serve(router http.Handler, method, path string, signedIn bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if signedIn {
+		req.AddCookie(&http.Cookie{Name: session.CookieName, Value: testCookies.Sign("session-1")})
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestDefaultRouteTable(t *testing.T) {
+	router := newTestRouter(t, DefaultRouteTable())
+
+	w := serve(router, http.MethodGet, "/api/posts/42", true)
+	if w.Code != http.StatusOK || w.Header().Get("X-Upstream-Path") != "/posts/42" {
+		t.Errorf("Expected /api/posts/42 to reach /posts/42, got %d %q", w.Code, w.Header().Get("X-Upstream-Path"))
+	}
+
+	// Posts and profiles are readable signed out; changes need a session
+	if w := serve(router, http.MethodGet, "/api/users/7/posts", false); w.Code != http.StatusOK || w.Header().Get("X-Upstream-User") != "" {
+		t.Errorf("Expected an anonymous read of user posts, got %d user=%q", w.Code, w.Header().Get("X-Upstream-User"))
+	}
+	if w := serve(router, http.MethodDelete, "/api/posts/42", false); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected session routes to require a session, got %d", w.Code)
+	}
+	if w := serve(router, http.MethodGet, "/api/comments/posts/42/comments", false); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected other services to require a session, got %d", w.Code)
+	}
+}
+
+func TestParseRouteTableRejectsInvalidTables(t *testing.T) {
+	for name, data := range map[string]string{
+		"unknown field":     "routes:\n  - path_prefix: /api/x\n    service: x\n    prefix_strip: /api\n",
+		"no service":        "routes:\n  - path_prefix: /api/x\n",
+		"unknown auth":      "routes:\n  - path_prefix: /api/x\n    service: x\n    auth: maybe\n",
+		"bad strip prefix":  "routes:\n  - path_prefix: /api/x\n    service: x\n    strip_prefix: /auth\n",
+		"bad rate limit":    "rate_limits:\n  search: {limit: lots, key: ip}\nroutes:\n  - path_prefix: /api/x\n    service: x\n",
+		"relative prefix":   "routes:\n  - path_prefix: api/x\n    service: x\n",
+		"negative idle":     "routes:\n  - path_prefix: /api/x\n    service: x\n    idle_timeout: -1s\n",
+		"unknown balancer":  "routes:\n  - path_prefix: /api/x\n    service: x\n    balancer: fastest\n",
+		"no routes":         "routes: []\n",
+		"invalid json/yaml": "{routes: [",
+	} {
+		if _, err := ParseRouteTable([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	// JSON is accepted as well
+	table, err := ParseRouteTable([]byte(`{"routes": [{"path_prefix": "/api/x", "service": "x", "timeout": "2s"}]}`))
+	if err != nil || table.Routes[0].Timeout != 2*time.Second {
+		t.Errorf("Expected a JSON table to parse, got %+v, %v", table, err)
+	}
+}
+
+func TestRouterReload(t *testing.T) {
+	router := newTestRouter(t, RouteTable{Routes: []Route{
+		{PathPrefix: "/api/old", Service: "test-service", StripPrefix: "/api", Auth: AuthPublic},
+	}})
+
+	if w := serve(router, http.MethodGet, "/api/old/1", false); w.Code != http.StatusOK {
+		t.Fatalf("Expected the initial route to work, got %d", w.Code)
+	}
+
+	if err := router.Reload(RouteTable{Routes: []Route{
+		{PathPrefix: "/api/new", Service: "test-service", StripPrefix: "/api", Auth: AuthPublic},
+	}}); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if w := serve(router, http.MethodGet, "/api/old/1", false); w.Code != http.StatusNotFound {
+		t.Errorf("Expected the removed route to be gone, got %d", w.Code)
+	}
+	if w := serve(router, http.MethodGet, "/api/new/1", false); w.Code != http.StatusOK {
+		t.Errorf("Expected the added route to work, got %d", w.Code)
+	}
+
+	// Tables that cannot be built leave the current routes in place
+	invalid := []RouteTable{
+		{Routes: []Route{{PathPrefix: "/api/x", Service: "x", RateLimit: "missing"}}},
+		{Routes: []Route{{PathPrefix: "/api/x", Service: "x"}, {PathPrefix: "/api/x", Service: "y"}}},
+		{Routes: []Route{{PathPrefix: "/health", Service: "x"}}},
+	}
+	for i, table := range invalid {
+		if err := router.Reload(table); err == nil {
+			t.Errorf("table %d: expected Reload to fail", i)
+		}
+	}
+	if w := serve(router, http.MethodGet, "/api/new/1", false); w.Code != http.StatusOK {
+		t.Errorf("Expected the routes to survive a failed reload, got %d", w.Code)
+	}
+}
+
+func TestRouterAuthModes(t *testing.T) {
+	router := newTestRouter(t, RouteTable{Routes: []Route{
+		{PathPrefix: "/api/posts", Methods: []string{http.MethodGet}, Service: "test-service", StripPrefix: "/api", Auth: AuthOptional},
+		{PathPrefix: "/api/posts", Service: "test-service", StripPrefix: "/api"},
+	}})
+
+	// Optional reads go through anonymously, and with the user when signed in
+	w := serve(router, http.MethodGet, "/api/posts/1", false)
+	if w.Code != http.StatusOK || w.Header().Get("X-Upstream-User") != "" {
+		t.Errorf("Expected an anonymous read, got %d user=%q", w.Code, w.Header().Get("X-Upstream-User"))
+	}
+	w = serve(router, http.MethodGet, "/api/posts/1", true)
+	if w.Code != http.StatusOK || w.Header().Get("X-Upstream-User") != "user-1" {
+		t.Errorf("Expected the signed-in user to be forwarded, got %d user=%q", w.Code, w.Header().Get("X-Upstream-User"))
+	}
+	if email := w.Header().Get("X-Upstream-Email"); email != "user-1@example.com" {
+		t.Errorf("Expected the session's email in the signed identity, got %q", email)
+	}
+
+	// Other methods fall back to the session route
+	if w := serve(router, http.MethodDelete, "/api/posts/1", false); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected DELETE to require a session, got %d", w.Code)
+	}
+	if w := serve(router, http.MethodDelete, "/api/posts/1", true); w.Code != http.StatusForbidden {
+		t.Errorf("Expected DELETE without CSRF token to be rejected, got %d", w.Code)
+	}
+}
+
+func TestLoadRouteSource(t *testing.T) {
+	t.Setenv("GATEWAY_ROUTES_FILE", "routes.yaml")
+	t.Setenv("GATEWAY_ROUTES_CONSUL_KEY", "gateway/routes")
+	if _, err := LoadRouteSource(); err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+		t.Errorf("Expected file and Consul key together to be rejected, got %v", err)
+	}
+}
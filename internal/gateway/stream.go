@@ -0,0 +1,265 @@
+package gateway
+
+import (
+	"bufio"
+	"context"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"instant/internal/metrics"
+)
+
+// Kinds of long-lived streams the gateway proxies
+const (
+	StreamWebSocket = "websocket"
+	StreamSSE       = "sse"
+)
+
+// streamKind returns the kind of stream a request asks for: StreamWebSocket
+// for WebSocket upgrades, StreamSSE for requests accepting text/event-stream,
+// or "" for ordinary requests
+func streamKind(req *http.Request) string {
+	if isWebSocketUpgrade(req) {
+		return StreamWebSocket
+	}
+	if req.Method == http.MethodGet && strings.Contains(req.Header.Get("Accept"), "text/event-stream") {
+		return StreamSSE
+	}
+	return ""
+}
+
+// isWebSocketUpgrade reports whether the request asks to switch to WebSocket
+func isWebSocketUpgrade(req *http.Request) bool {
+	if !strings.EqualFold(req.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	for _, value := range req.Header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stream is one proxy attempt of a WebSocket or SSE request. Until the
+// upstream answers it is bounded by the upstream timeout like any request;
+// once the stream is established it stays open until the client or upstream
+// closes it, nothing has moved for the idle timeout, or the gateway shuts down.
+type stream struct {
+	kind    string
+	service string
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	timedOut atomic.Bool // no response within the upstream timeout
+
+	timeout    *time.Timer
+	idle       *idleTimer
+	stopOnShut func() bool
+	closed     func() // set once the stream is established
+}
+
+// openStream starts an attempt for a stream request; close must be called
+// when the attempt is over
+func (h *ProxyHandler) openStream(parent context.Context, kind, service string, timeout, idleTimeout time.Duration) *stream {
+	ctx, cancel := context.WithCancel(parent)
+	s := &stream{
+		kind:    kind,
+		service: service,
+		ctx:     ctx,
+		cancel:  cancel,
+		idle:    newIdleTimer(idleTimeout, cancel),
+	}
+	s.timeout = time.AfterFunc(timeout, func() {
+		s.timedOut.Store(true)
+		cancel()
+	})
+	s.stopOnShut = context.AfterFunc(h.streams, cancel)
+	return s
+}
+
+// established is called with the upstream's response. A switched protocol
+// or an event stream lifts the upstream timeout and starts the idle timeout;
+// any other response stays bounded by the upstream timeout.
+func (s *stream) established(w http.ResponseWriter, resp *http.Response) {
+	switch s.kind {
+	case StreamWebSocket:
+		if resp.StatusCode != http.StatusSwitchingProtocols {
+			return
+		}
+	case StreamSSE:
+		if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/event-stream" {
+			return
+		}
+	}
+
+	if !s.timeout.Stop() {
+		return // timed out while the response was on its way
+	}
+	s.idle.start()
+	s.closed = metrics.StreamOpened(s.service, s.kind)
+
+	// The server's read and write timeouts would cut the stream short; the
+	// idle timeout takes their place. Hijacked connections are handled by
+	// streamWriter.
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(time.Time{})
+	_ = rc.SetWriteDeadline(time.Time{})
+}
+
+// close ends the attempt and releases its timers
+func (s *stream) close() {
+	s.cancel()
+	s.timeout.Stop()
+	s.idle.stop()
+	s.stopOnShut()
+	if s.closed != nil {
+		s.closed()
+	}
+}
+
+// writer wraps w so data sent to the client counts as stream activity
+func (s *stream) writer(w http.ResponseWriter) http.ResponseWriter {
+	return &streamWriter{ResponseWriter: w, idle: s.idle}
+}
+
+// streamWriter marks every write as activity on the idle timer. A hijacked
+// connection is wrapped so reads from the client count as well.
+type streamWriter struct {
+	http.ResponseWriter
+	idle *idleTimer
+}
+
+func (w *streamWriter) Write(b []byte) (int, error) {
+	w.idle.touch()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *streamWriter) Flush() {
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *streamWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Deadlines left by the server's timeouts would cut the connection
+	_ = conn.SetDeadline(time.Time{})
+	return &idleConn{Conn: conn, idle: w.idle}, brw, nil
+}
+
+// Unwrap returns the wrapped writer for http.ResponseController
+func (w *streamWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// idleConn is a hijacked client connection whose traffic in either direction
+// counts as stream activity
+type idleConn struct {
+	net.Conn
+	idle *idleTimer
+}
+
+func (c *idleConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.idle.touch()
+	}
+	return n, err
+}
+
+func (c *idleConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.idle.touch()
+	}
+	return n, err
+}
+
+// CloseWrite lets the proxy pass on a half-close from the upstream
+func (c *idleConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return c.Conn.Close()
+}
+
+// idleTimer calls expire once it has not been touched for the timeout after
+// start. A nil idleTimer (no timeout) never expires.
+type idleTimer struct {
+	timeout time.Duration
+	expire  func()
+	last    atomic.Int64 // unix nanoseconds of the last activity
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	stopped bool
+}
+
+func newIdleTimer(timeout time.Duration, expire func()) *idleTimer {
+	if timeout <= 0 {
+		return nil
+	}
+	return &idleTimer{timeout: timeout, expire: expire}
+}
+
+// start begins counting idle time from now
+func (t *idleTimer) start() {
+	if t == nil {
+		return
+	}
+	t.touch()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.stopped && t.timer == nil {
+		t.timer = time.AfterFunc(t.timeout, t.check)
+	}
+}
+
+// touch records activity. It is cheap enough to call on every read and write.
+func (t *idleTimer) touch() {
+	if t != nil {
+		t.last.Store(time.Now().UnixNano())
+	}
+}
+
+func (t *idleTimer) stop() {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stopped = true
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+}
+
+// check expires the timer, or waits again if there was activity meanwhile
+func (t *idleTimer) check() {
+	t.mu.Lock()
+	if t.stopped {
+		t.mu.Unlock()
+		return
+	}
+	if wait := t.timeout - time.Since(time.Unix(0, t.last.Load())); wait > 0 {
+		t.timer.Reset(wait)
+		t.mu.Unlock()
+		return
+	}
+	t.stopped = true
+	t.mu.Unlock()
+
+	t.expire()
+}
@@ -0,0 +1,182 @@
+package gateway
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"instant/internal/consul"
+	"instant/internal/identity"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newStreamGateway serves a proxy to upstream on a real server, as streams
+// need flushing and hijacking
+func newStreamGateway(t *testing.T, upstream *httptest.Server, cfg ProxyConfig) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	h := NewProxyHandler(&mockDiscovery{instances: []*consul.ServiceInstance{instanceFor(t, "up", upstream)}},
+		identity.NewSigner("test-secret-that-is-at-least-32-bytes-long"), cfg)
+	r := gin.New()
+	r.Use(LoggingMiddleware())
+	r.Any("/*path", h.ProxyRequest("test-service"))
+
+	gateway := httptest.NewServer(r)
+	t.Cleanup(gateway.Close)
+	return gateway
+}
+
+func TestProxy_SSEOutlivesUpstreamTimeout(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < 3; i++ {
+			io.WriteString(w, "data: tick\n\n")
+			w.(http.Flusher).Flush()
+			time.Sleep(60 * time.Millisecond)
+		}
+	}))
+	defer upstream.Close()
+
+	cfg := DefaultProxyConfig()
+	cfg.Timeout = 50 * time.Millisecond
+	gateway := newStreamGateway(t, upstream, cfg)
+
+	req, _ := http.NewRequest(http.MethodGet, gateway.URL+"/events", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// The first event must arrive before the stream ends
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil || line != "data: tick\n" {
+		t.Fatalf("Expected the first event to be flushed, got %q, %v", line, err)
+	}
+
+	rest, _ := io.ReadAll(resp.Body)
+	if got := strings.Count(string(rest), "data: tick"); got != 2 {
+		t.Errorf("Expected the stream to outlive the upstream timeout, got %d more events", got)
+	}
+}
+
+func TestProxy_SSEIdleTimeout(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: hello\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer upstream.Close()
+
+	cfg := DefaultProxyConfig()
+	cfg.StreamIdleTimeout = 100 * time.Millisecond
+	gateway := newStreamGateway(t, upstream, cfg)
+
+	req, _ := http.NewRequest(http.MethodGet, gateway.URL+"/events", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	done := make(chan struct{})
+	go func() {
+		io.ReadAll(resp.Body)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the idle stream to be closed")
+	}
+}
+
+func TestProxy_WebSocketUpgrade(t *testing.T) {
+	// A minimal upstream that switches protocols and echoes what it receives
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isWebSocketUpgrade(r) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		brw.Flush()
+		io.Copy(conn, brw)
+	}))
+	defer upstream.Close()
+
+	cfg := DefaultProxyConfig()
+	cfg.Timeout = 50 * time.Millisecond
+	cfg.StreamIdleTimeout = 200 * time.Millisecond
+	gateway := newStreamGateway(t, upstream, cfg)
+
+	conn, err := net.Dial("tcp", gateway.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	io.WriteString(conn, "GET /socket HTTP/1.1\r\nHost: gateway\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n")
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatalf("Failed to read upgrade response: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected status 101, got %d", resp.StatusCode)
+	}
+
+	// Traffic keeps the connection open past the upstream timeout
+	for i := 0; i < 3; i++ {
+		time.Sleep(60 * time.Millisecond)
+		io.WriteString(conn, "ping\n")
+		line, err := br.ReadString('\n')
+		if err != nil || line != "ping\n" {
+			t.Fatalf("Expected echo %d, got %q, %v", i, line, err)
+		}
+	}
+
+	// Without traffic it is closed after the idle timeout
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := br.ReadByte(); err != io.EOF {
+		t.Errorf("Expected the idle connection to be closed, got %v", err)
+	}
+}
+
+func TestStreamKind(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		want    string
+	}{
+		{"websocket", http.MethodGet, map[string]string{"Connection": "keep-alive, Upgrade", "Upgrade": "WebSocket"}, StreamWebSocket},
+		{"upgrade without connection token", http.MethodGet, map[string]string{"Upgrade": "websocket"}, ""},
+		{"event stream", http.MethodGet, map[string]string{"Accept": "text/event-stream"}, StreamSSE},
+		{"event stream post", http.MethodPost, map[string]string{"Accept": "text/event-stream"}, ""},
+		{"ordinary request", http.MethodGet, map[string]string{"Accept": "application/json"}, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/", nil)
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+		if got := streamKind(req); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package identity
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testSecret = "test-secret-that-is-at-least-32-characters"
+
+func TestSigner_InjectAndVerify(t *testing.T) {
+	signer := NewSigner(testSecret)
+
+	h := http.Header{}
+	signer.Inject(h, "test-user-id", "test@example.com")
+
+	id, err := signer.Verify(h)
+	if err != nil {
+		t.Fatalf("Expected valid identity, got %v", err)
+	}
+	if id.UserID != "test-user-id" || id.Email != "test@example.com" {
+		t.Errorf("Unexpected identity %+v", id)
+	}
+}
+
+func TestSigner_RejectsUnsignedHeaders(t *testing.T) {
+	signer := NewSigner(testSecret)
+
+	h := http.Header{}
+	h.Set(HeaderUserID, "test-user-id")
+
+	if _, err := signer.Verify(h); err != ErrMissingIdentity {
+		t.Errorf("Expected ErrMissingIdentity, got %v", err)
+	}
+}
+
+func TestSigner_RejectsTamperedUserID(t *testing.T) {
+	signer := NewSigner(testSecret)
+
+	h := http.Header{}
+	signer.Inject(h, "test-user-id", "test@example.com")
+	h.Set(HeaderUserID, "other-user-id")
+
+	if _, err := signer.Verify(h); err != ErrInvalidSignature {
+		t.Errorf("Expected ErrInvalidSignature, got %v", err)
+	}
+}
+
+func TestSigner_RejectsOtherSecret(t *testing.T) {
+	h := http.Header{}
+	NewSigner("another-secret-that-is-at-least-32-chars").Inject(h, "test-user-id", "")
+
+	if _, err := NewSigner(testSecret).Verify(h); err != ErrInvalidSignature {
+		t.Errorf("Expected ErrInvalidSignature, got %v", err)
+	}
+}
+
+func TestSigner_RejectsExpiredSignature(t *testing.T) {
+	signer := NewSigner(testSecret)
+
+	ts := strconv.FormatInt(time.Now().Add(-2*DefaultMaxAge).Unix(), 10)
+	h := http.Header{}
+	h.Set(HeaderUserID, "test-user-id")
+	h.Set(HeaderTimestamp, ts)
+	h.Set(HeaderSignature, signer.sign("test-user-id", "", ts))
+
+	if _, err := signer.Verify(h); err != ErrExpiredIdentity {
+		t.Errorf("Expected ErrExpiredIdentity, got %v", err)
+	}
+}
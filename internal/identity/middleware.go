@@ -0,0 +1,106 @@
+package identity
+
+import (
+	"context"
+	"net/http"
+
+	"instant/internal/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Context keys set by the middlewares
+const (
+	ContextUserID = "user_id"
+	ContextEmail  = "email"
+)
+
+// RequireUser verifies the signed identity from the gateway and rejects
+// the request with 401 if it is missing or invalid
+func RequireUser(signer *Signer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := signer.Verify(c.Request.Header)
+		if err != nil {
+			if err != ErrMissingIdentity {
+				logger.FromContext(c.Request.Context()).Warn("Rejected identity",
+					"method", c.Request.Method,
+					"path", c.Request.URL.Path,
+					"error", err)
+			}
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "unauthorized",
+			})
+			return
+		}
+
+		c.Set(ContextUserID, id.UserID)
+		c.Set(ContextEmail, id.Email)
+		c.Request = c.Request.WithContext(logger.WithUserID(c.Request.Context(), id.UserID))
+		c.Next()
+	}
+}
+
+// OptionalUser verifies the signed identity if present but never rejects the request.
+// Unsigned or invalid identities are ignored.
+func OptionalUser(signer *Signer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if id, err := signer.Verify(c.Request.Header); err == nil {
+			c.Set(ContextUserID, id.UserID)
+			c.Set(ContextEmail, id.Email)
+			c.Request = c.Request.WithContext(logger.WithUserID(c.Request.Context(), id.UserID))
+		}
+		c.Next()
+	}
+}
+
+// UserID returns the verified user ID, or "" if the request is anonymous
+func UserID(c *gin.Context) string {
+	return c.GetString(ContextUserID)
+}
+
+// UserUUID returns the verified user ID parsed as a UUID
+func UserUUID(c *gin.Context) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(UserID(c))
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// Email returns the verified user email, or "" if the request is anonymous
+func Email(c *gin.Context) string {
+	return c.GetString(ContextEmail)
+}
+
+// WriteTracker records recent writes per user so their reads can be pinned to
+// the primary database. It is implemented by *database.WriteTracker.
+type WriteTracker interface {
+	MarkWrite(key string)
+	Context(ctx context.Context, key string) context.Context
+}
+
+// ReadYourWrites routes the reads of a user who has just written to the
+// primary database, so they see their own changes before replicas catch up.
+// Successful mutating requests mark the user as having written. It must run
+// after RequireUser or OptionalUser; a nil tracker disables it.
+func ReadYourWrites(tracker WriteTracker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := UserID(c)
+		if tracker == nil || userID == "" {
+			c.Next()
+			return
+		}
+
+		c.Request = c.Request.WithContext(tracker.Context(c.Request.Context(), userID))
+		c.Next()
+
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return
+		}
+		if c.Writer.Status() < http.StatusBadRequest {
+			tracker.MarkWrite(userID)
+		}
+	}
+}
@@ -11,6 +11,8 @@ type Config struct {
 	Brokers           string
 	EmailEventsTopic  string
 	EmailDLQTopic     string
+	PostEventsTopic   string
+	FollowEventsTopic string
 	ConsumerGroup     string
 	EnableIdempotence bool
 	Acks              string
@@ -33,6 +35,16 @@ func LoadConfig() (*Config, error) {
 		emailDLQTopic = "email-events-dlq" // Default
 	}
 
+	postEventsTopic := os.Getenv("KAFKA_TOPIC_POST_EVENTS")
+	if postEventsTopic == "" {
+		postEventsTopic = "post-created" // Default
+	}
+
+	followEventsTopic := os.Getenv("KAFKA_TOPIC_FOLLOW_EVENTS")
+	if followEventsTopic == "" {
+		followEventsTopic = "follow-changed" // Default
+	}
+
 	consumerGroup := os.Getenv("KAFKA_CONSUMER_GROUP")
 	if consumerGroup == "" {
 		consumerGroup = "email-service-group" // Default
@@ -42,6 +54,8 @@ func LoadConfig() (*Config, error) {
 		Brokers:           brokers,
 		EmailEventsTopic:  emailEventsTopic,
 		EmailDLQTopic:     emailDLQTopic,
+		PostEventsTopic:   postEventsTopic,
+		FollowEventsTopic: followEventsTopic,
 		ConsumerGroup:     consumerGroup,
 		EnableIdempotence: true, // Always enable for exactly-once
 		Acks:              "all", // Wait for all replicas
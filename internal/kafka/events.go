@@ -0,0 +1,28 @@
+package kafka
+
+import "time"
+
+// PostCreatedEvent is published by posts-service when a post is created and
+// consumed by feed-service to fan the post out to follower timelines
+type PostCreatedEvent struct {
+	// EventID is a unique identifier for this event (UUID v4)
+	EventID string `json:"event_id"`
+
+	PostID    int64     `json:"post_id"`
+	AuthorID  string    `json:"author_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// FollowChangedEvent is published by follow-service when a user follows or
+// unfollows an account and consumed by feed-service to rebuild the
+// follower's timeline
+type FollowChangedEvent struct {
+	// EventID is a unique identifier for this event (UUID v4)
+	EventID string `json:"event_id"`
+
+	FollowerID string `json:"follower_id"`
+	FolloweeID string `json:"followee_id"`
+	// Following is true for a follow and false for an unfollow
+	Following bool      `json:"following"`
+	ChangedAt time.Time `json:"changed_at"`
+}
@@ -1,10 +1,14 @@
 package kafka
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log/slog"
 
+	"instant/internal/metrics"
+	"instant/internal/tracing"
+
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 )
 
@@ -51,9 +55,10 @@ func NewProducer(config *Config, logger *slog.Logger) (*Producer, error) {
 	return producer, nil
 }
 
-// PublishEmailEvent publishes an email event to Kafka
+// PublishEmailEvent publishes an email event to Kafka.
+// Like the other Publish methods it carries the trace context of ctx in the message headers.
 // Equivalent to Python: producer.send('email-events', event_data)
-func (p *Producer) PublishEmailEvent(topic string, event interface{}) error {
+func (p *Producer) PublishEmailEvent(ctx context.Context, topic string, event interface{}) error {
 	// Serialize to JSON (like Python's json.dumps)
 	jsonData, err := json.Marshal(event)
 	if err != nil {
@@ -69,9 +74,13 @@ func (p *Producer) PublishEmailEvent(topic string, event interface{}) error {
 		Value: jsonData,
 	}
 
+	span := startProducerSpan(ctx, msg)
+
 	// Produce message (non-blocking, uses delivery reports)
 	err = p.producer.Produce(msg, nil)
+	tracing.End(span, err)
 	if err != nil {
+		metrics.KafkaProduced(topic, err)
 		return fmt.Errorf("failed to produce message: %w", err)
 	}
 
@@ -84,7 +93,7 @@ func (p *Producer) PublishEmailEvent(topic string, event interface{}) error {
 
 // PublishEmailEventSync publishes an email event and waits for confirmation
 // Use this for critical events where you need immediate feedback
-func (p *Producer) PublishEmailEventSync(topic string, event interface{}) error {
+func (p *Producer) PublishEmailEventSync(ctx context.Context, topic string, event interface{}) error {
 	// Serialize to JSON
 	jsonData, err := json.Marshal(event)
 	if err != nil {
@@ -100,6 +109,8 @@ func (p *Producer) PublishEmailEventSync(topic string, event interface{}) error
 		Value: jsonData,
 	}
 
+	span := startProducerSpan(ctx, msg)
+
 	// Create delivery channel for this message
 	deliveryChan := make(chan kafka.Event)
 
@@ -107,6 +118,8 @@ func (p *Producer) PublishEmailEventSync(topic string, event interface{}) error
 	err = p.producer.Produce(msg, deliveryChan)
 	if err != nil {
 		close(deliveryChan)
+		metrics.KafkaProduced(topic, err)
+		tracing.End(span, err)
 		return fmt.Errorf("failed to produce message: %w", err)
 	}
 
@@ -115,6 +128,8 @@ func (p *Producer) PublishEmailEventSync(topic string, event interface{}) error
 	close(deliveryChan)
 
 	m := e.(*kafka.Message)
+	metrics.KafkaProduced(topic, m.TopicPartition.Error)
+	tracing.End(span, m.TopicPartition.Error)
 	if m.TopicPartition.Error != nil {
 		return fmt.Errorf("delivery failed: %w", m.TopicPartition.Error)
 	}
@@ -127,11 +142,46 @@ func (p *Producer) PublishEmailEventSync(topic string, event interface{}) error
 	return nil
 }
 
-// handleDeliveryReports processes asynchronous delivery reports
+// PublishEvent publishes an event with a partition key, so events sharing
+// the key are delivered in order
+func (p *Producer) PublishEvent(ctx context.Context, topic, key string, event interface{}) error {
+	jsonData, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	msg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{
+			Topic:     &topic,
+			Partition: kafka.PartitionAny,
+		},
+		Key:   []byte(key),
+		Value: jsonData,
+	}
+
+	span := startProducerSpan(ctx, msg)
+	err = p.producer.Produce(msg, nil)
+	tracing.End(span, err)
+	if err != nil {
+		metrics.KafkaProduced(topic, err)
+		return fmt.Errorf("failed to produce message: %w", err)
+	}
+
+	p.logger.Debug("Event published to Kafka",
+		"topic", topic,
+		"key", key,
+		"size", len(jsonData))
+
+	return nil
+}
+
+// handleDeliveryReports processes asynchronous delivery reports.
+// Successful produces are counted here, once the broker has acknowledged them.
 func (p *Producer) handleDeliveryReports() {
 	for e := range p.producer.Events() {
 		switch ev := e.(type) {
 		case *kafka.Message:
+			metrics.KafkaProduced(*ev.TopicPartition.Topic, ev.TopicPartition.Error)
 			if ev.TopicPartition.Error != nil {
 				p.logger.Error("Delivery failed",
 					"topic", *ev.TopicPartition.Topic,
@@ -0,0 +1,100 @@
+package kafka
+
+import (
+	"context"
+	"strconv"
+
+	"instant/internal/logger"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("instant/internal/kafka")
+
+// headerCarrier exposes Kafka message headers to the OpenTelemetry propagator
+type headerCarrier struct {
+	msg *kafka.Message
+}
+
+func (h headerCarrier) Get(key string) string {
+	for _, header := range h.msg.Headers {
+		if header.Key == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+func (h headerCarrier) Set(key, value string) {
+	for i, header := range h.msg.Headers {
+		if header.Key == key {
+			h.msg.Headers[i].Value = []byte(value)
+			return
+		}
+	}
+	h.msg.Headers = append(h.msg.Headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (h headerCarrier) Keys() []string {
+	keys := make([]string, len(h.msg.Headers))
+	for i, header := range h.msg.Headers {
+		keys[i] = header.Key
+	}
+	return keys
+}
+
+// InjectTrace writes the trace context of ctx into the message headers
+func InjectTrace(ctx context.Context, msg *kafka.Message) {
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier{msg: msg})
+}
+
+// ExtractTrace returns ctx with the trace context carried in the message headers
+func ExtractTrace(ctx context.Context, msg *kafka.Message) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, headerCarrier{msg: msg})
+}
+
+// StartConsumerSpan continues the producer's trace for a consumed message.
+// The returned context also carries the request ID of the request that
+// produced the message. The caller must end the returned span.
+func StartConsumerSpan(ctx context.Context, msg *kafka.Message, group string) (context.Context, trace.Span) {
+	topic := ""
+	if msg.TopicPartition.Topic != nil {
+		topic = *msg.TopicPartition.Topic
+	}
+
+	ctx = ExtractTrace(ctx, msg)
+	if requestID := (headerCarrier{msg: msg}).Get(logger.RequestIDHeader); logger.ValidRequestID(requestID) {
+		ctx = logger.WithRequestID(ctx, requestID)
+	}
+
+	return tracer.Start(ctx, "process "+topic,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypeProcess,
+			semconv.MessagingDestinationName(topic),
+			semconv.MessagingConsumerGroupName(group),
+			semconv.MessagingDestinationPartitionID(strconv.FormatInt(int64(msg.TopicPartition.Partition), 10)),
+		))
+}
+
+// startProducerSpan starts a span for sending msg and injects its context
+// and the request ID into the message headers, so consumers continue the
+// same trace and log under the same request ID
+func startProducerSpan(ctx context.Context, msg *kafka.Message) trace.Span {
+	ctx, span := tracer.Start(ctx, "send "+*msg.TopicPartition.Topic,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypeSend,
+			semconv.MessagingDestinationName(*msg.TopicPartition.Topic),
+		))
+	InjectTrace(ctx, msg)
+	if requestID := logger.RequestID(ctx); requestID != "" {
+		headerCarrier{msg: msg}.Set(logger.RequestIDHeader, requestID)
+	}
+	return span
+}
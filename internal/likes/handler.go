@@ -3,6 +3,8 @@ package likes
 import (
 	"net/http"
 
+	"instant/internal/identity"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -26,7 +28,7 @@ func NewHandler(svc Service) *Handler { return &Handler{svc: svc} }
 // @Security SessionAuth
 // @Router /api/likes [post]
 func (h *Handler) Like(c *gin.Context) {
-	userID := c.GetHeader("X-User-ID")
+	userID := identity.UserID(c)
 	if userID == "" {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
@@ -56,7 +58,7 @@ func (h *Handler) Like(c *gin.Context) {
 // @Security SessionAuth
 // @Router /api/likes/{post_id} [delete]
 func (h *Handler) Unlike(c *gin.Context) {
-	userID := c.GetHeader("X-User-ID")
+	userID := identity.UserID(c)
 	if userID == "" {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
@@ -101,7 +103,7 @@ func (h *Handler) Count(c *gin.Context) {
 // @Security SessionAuth
 // @Router /api/posts/{post_id}/likes/me [get]
 func (h *Handler) IsLiked(c *gin.Context) {
-	userID := c.GetHeader("X-User-ID")
+	userID := identity.UserID(c)
 	if userID == "" {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
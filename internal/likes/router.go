@@ -1,19 +1,31 @@
 package likes
 
-import "github.com/gin-gonic/gin"
+import (
+	"instant/internal/identity"
+	"instant/internal/logger"
+	"instant/internal/metrics"
+	"instant/internal/tracing"
 
-func SetupRouter(svc Service) *gin.Engine {
+	"github.com/gin-gonic/gin"
+)
+
+func SetupRouter(svc Service, signer *identity.Signer, writes identity.WriteTracker) *gin.Engine {
 	r := gin.Default()
+	r.Use(logger.Middleware())
+	r.Use(tracing.Middleware())
+	metrics.Instrument(r) // request metrics and /metrics
 	h := NewHandler(svc)
 
 	// Health
 	r.GET("/health", h.Health)
 
-	// Likes
-	r.POST("/", h.Like)
-	r.DELETE("/:post_id", h.Unlike)
-	r.GET("/:post_id/likes/count", h.Count)
-	r.GET("/:post_id/likes/me", h.IsLiked)
+	// Likes (identity signed by the gateway)
+	api := r.Group("/")
+	api.Use(identity.RequireUser(signer), identity.ReadYourWrites(writes))
+	api.POST("/", h.Like)
+	api.DELETE("/:post_id", h.Unlike)
+	api.GET("/:post_id/likes/count", h.Count)
+	api.GET("/:post_id/likes/me", h.IsLiked)
 
 	return r
 }
@@ -0,0 +1,50 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+)
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	userIDKey
+)
+
+// WithRequestID returns ctx carrying the request ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestID returns the request ID carried by ctx, or ""
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// WithUserID returns ctx carrying the authenticated user ID
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDKey, userID)
+}
+
+// UserID returns the user ID carried by ctx, or ""
+func UserID(ctx context.Context) string {
+	userID, _ := ctx.Value(userIDKey).(string)
+	return userID
+}
+
+// FromContext returns the default logger with the request_id and user_id
+// carried by ctx attached, so log lines from every service handling the same
+// request can be joined on one ID. The service attribute comes from the
+// default logger installed with ForService.
+func FromContext(ctx context.Context) *slog.Logger {
+	l := slog.Default()
+	if requestID := RequestID(ctx); requestID != "" {
+		l = l.With("request_id", requestID)
+	}
+	if userID := UserID(ctx); userID != "" {
+		l = l.With("user_id", userID)
+	}
+	return l
+}
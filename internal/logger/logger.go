@@ -22,6 +22,9 @@ const (
 //
 // LOG_LEVEL options: debug, info, warn, error (default: info)
 // LOG_FORMAT options: json, text (default: json)
+//
+// Sensitive attributes are redacted with RedactPolicyFromEnv unless
+// LOG_REDACT=false or APP_ENV is local, dev or development.
 func New() *slog.Logger {
 	level := getLogLevel()
 	format := getLogFormat()
@@ -43,6 +46,10 @@ func New() *slog.Logger {
 		handler = slog.NewJSONHandler(os.Stdout, opts)
 	}
 
+	if redactionEnabled() {
+		handler = NewRedactHandler(handler, RedactPolicyFromEnv())
+	}
+
 	return slog.New(handler)
 }
 
@@ -78,6 +85,11 @@ func getLogFormat() LogFormat {
 	}
 }
 
+// ForService creates a logger with New and tags every line with the service name
+func ForService(name string) *slog.Logger {
+	return New().With("service", name)
+}
+
 // SetDefault sets the given logger as the default slog logger
 func SetDefault(logger *slog.Logger) {
 	slog.SetDefault(logger)
@@ -0,0 +1,48 @@
+package logger
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the request ID between the gateway and services
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds inbound IDs so clients cannot bloat log lines
+const maxRequestIDLength = 128
+
+// Middleware attaches a request ID to the request context and echoes it in
+// the X-Request-ID response header. An inbound ID is reused when it is well
+// formed, so a request keeps the same ID from the gateway through every
+// service; otherwise a new one is generated.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !ValidRequestID(requestID) {
+			requestID = uuid.New().String()
+		}
+
+		c.Set("request_id", requestID)
+		c.Header(RequestIDHeader, requestID)
+		c.Request = c.Request.WithContext(WithRequestID(c.Request.Context(), requestID))
+
+		c.Next()
+	}
+}
+
+// ValidRequestID reports whether id is safe to reuse: non-empty, bounded in
+// length and limited to letters, digits and "-", "_", ".", ":"
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
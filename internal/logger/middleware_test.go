@@ -0,0 +1,74 @@
+package logger
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMiddlewareRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		inbound string
+		reused  bool
+	}{
+		{"missing", "", false},
+		{"valid", "3f2c9a1e-7b1d-4c55-9a0e-2f6d8c1b7e40", true},
+		{"log injection", "abc\n{\"level\":\"ERROR\"}", false},
+		{"too long", strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(Middleware())
+
+			var fromCtx string
+			r.GET("/", func(c *gin.Context) {
+				fromCtx = RequestID(c.Request.Context())
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.inbound != "" {
+				req.Header.Set(RequestIDHeader, tt.inbound)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			got := w.Header().Get(RequestIDHeader)
+			if !ValidRequestID(got) {
+				t.Fatalf("Expected a valid request ID in the response, got %q", got)
+			}
+			if (got == tt.inbound) != tt.reused {
+				t.Errorf("Expected reused=%v, got %q for inbound %q", tt.reused, got, tt.inbound)
+			}
+			if fromCtx != got {
+				t.Errorf("Expected request context to carry %q, got %q", got, fromCtx)
+			}
+		})
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)).With("service", "test-service"))
+	defer slog.SetDefault(prev)
+
+	ctx := WithUserID(WithRequestID(t.Context(), "req-1"), "user-1")
+	FromContext(ctx).Info("hello")
+
+	line := buf.String()
+	for _, want := range []string{"service=test-service", "request_id=req-1", "user_id=user-1"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("Expected %q in %q", want, line)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package logger
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// RedactMode is how a sensitive attribute value is replaced
+type RedactMode string
+
+const (
+	// RedactMask keeps a hint of the value, e.g. "j***@example.com"
+	RedactMask RedactMode = "mask"
+	// RedactHash replaces the value with a keyed hash, so lines about the
+	// same value can still be correlated without revealing it
+	RedactHash RedactMode = "hash"
+	// RedactRemove replaces the value entirely
+	RedactRemove RedactMode = "remove"
+)
+
+// redactedValue replaces values removed with RedactRemove
+const redactedValue = "[REDACTED]"
+
+// emailPattern finds email addresses inside messages and free-form values
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// RedactPolicy decides which attributes are redacted and how
+type RedactPolicy struct {
+	// Keys maps lowercase attribute keys to their redaction mode. Keys inside
+	// groups are matched by their own name.
+	Keys map[string]RedactMode
+	// Salt keys the hashes of RedactHash. Without it hashes are plain SHA-256,
+	// which low-entropy values like emails do not survive a dictionary attack.
+	Salt []byte
+}
+
+// DefaultRedactPolicy masks emails, hashes session IDs and removes
+// verification codes, passwords and tokens. Email addresses found in
+// messages and other string values are always masked.
+func DefaultRedactPolicy() RedactPolicy {
+	return RedactPolicy{
+		Keys: map[string]RedactMode{
+			"email":             RedactMask,
+			"recipient":         RedactMask,
+			"to":                RedactMask,
+			"session_id":        RedactHash,
+			"session":           RedactHash,
+			"code":              RedactRemove,
+			"verification_code": RedactRemove,
+			"password":          RedactRemove,
+			"token":             RedactRemove,
+			"secret":            RedactRemove,
+			"authorization":     RedactRemove,
+			"cookie":            RedactRemove,
+		},
+	}
+}
+
+// RedactPolicyFromEnv extends DefaultRedactPolicy with LOG_REDACT_KEYS, a
+// comma-separated list of key or key:mode entries (mode defaults to mask),
+// and keys hashes with LOG_REDACT_SALT. Unknown modes fall back to mask.
+func RedactPolicyFromEnv() RedactPolicy {
+	policy := DefaultRedactPolicy()
+	policy.Salt = []byte(os.Getenv("LOG_REDACT_SALT"))
+
+	for _, entry := range strings.Split(os.Getenv("LOG_REDACT_KEYS"), ",") {
+		key, mode, _ := strings.Cut(strings.TrimSpace(entry), ":")
+		if key == "" {
+			continue
+		}
+		switch RedactMode(strings.ToLower(mode)) {
+		case RedactHash:
+			policy.Keys[strings.ToLower(key)] = RedactHash
+		case RedactRemove:
+			policy.Keys[strings.ToLower(key)] = RedactRemove
+		default:
+			policy.Keys[strings.ToLower(key)] = RedactMask
+		}
+	}
+
+	return policy
+}
+
+// redactionEnabled reads LOG_REDACT. Redaction is on unless APP_ENV names a
+// development environment, so shipped logs never carry credentials by default.
+func redactionEnabled() bool {
+	if v, err := strconv.ParseBool(os.Getenv("LOG_REDACT")); err == nil {
+		return v
+	}
+
+	switch strings.ToLower(os.Getenv("APP_ENV")) {
+	case "local", "dev", "development":
+		return false
+	default:
+		return true
+	}
+}
+
+// RedactHandler is a slog.Handler that redacts sensitive attributes before
+// passing records to the wrapped handler
+type RedactHandler struct {
+	next   slog.Handler
+	policy RedactPolicy
+}
+
+// NewRedactHandler wraps next with the given policy
+func NewRedactHandler(next slog.Handler, policy RedactPolicy) *RedactHandler {
+	return &RedactHandler{next: next, policy: policy}
+}
+
+func (h *RedactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *RedactHandler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, maskEmails(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(h.policy.redact(a))
+		return true
+	})
+	return h.next.Handle(ctx, redacted)
+}
+
+func (h *RedactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = h.policy.redact(a)
+	}
+	return &RedactHandler{next: h.next.WithAttrs(redacted), policy: h.policy}
+}
+
+func (h *RedactHandler) WithGroup(name string) slog.Handler {
+	return &RedactHandler{next: h.next.WithGroup(name), policy: h.policy}
+}
+
+// redact applies the policy to one attribute, descending into groups and maps
+func (p RedactPolicy) redact(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+
+	if m, ok := a.Value.Any().(map[string]any); ok && a.Value.Kind() == slog.KindAny {
+		a.Value = mapValue(m)
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		group := a.Value.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			redacted[i] = p.redact(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+	}
+
+	if mode, ok := p.Keys[strings.ToLower(a.Key)]; ok {
+		return slog.String(a.Key, p.apply(mode, valueString(a.Value)))
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(maskEmails(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok && emailPattern.MatchString(err.Error()) {
+			a.Value = slog.StringValue(maskEmails(err.Error()))
+		}
+	}
+	return a
+}
+
+// apply replaces value according to mode
+func (p RedactPolicy) apply(mode RedactMode, value string) string {
+	if value == "" {
+		return ""
+	}
+
+	switch mode {
+	case RedactHash:
+		return p.hash(value)
+	case RedactMask:
+		if emailPattern.MatchString(value) {
+			return maskEmails(value)
+		}
+		return maskString(value)
+	default:
+		return redactedValue
+	}
+}
+
+// hash returns a short, stable digest of value
+func (p RedactPolicy) hash(value string) string {
+	var sum []byte
+	if len(p.Salt) > 0 {
+		mac := hmac.New(sha256.New, p.Salt)
+		mac.Write([]byte(value))
+		sum = mac.Sum(nil)
+	} else {
+		digest := sha256.Sum256([]byte(value))
+		sum = digest[:]
+	}
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// maskEmails keeps the first character of the local part and the domain of
+// every email address in s
+func maskEmails(s string) string {
+	if !strings.Contains(s, "@") {
+		return s
+	}
+	return emailPattern.ReplaceAllStringFunc(s, func(email string) string {
+		local, domain, _ := strings.Cut(email, "@")
+		return local[:1] + "***@" + domain
+	})
+}
+
+// maskString keeps the first and last two characters of long values only
+func maskString(s string) string {
+	if len(s) <= 8 {
+		return "***"
+	}
+	return s[:2] + "***" + s[len(s)-2:]
+}
+
+// valueString renders a non-group value as a string
+func valueString(v slog.Value) string {
+	if v.Kind() == slog.KindAny {
+		return fmt.Sprint(v.Any())
+	}
+	return v.String()
+}
+
+// mapValue turns a map into a group so its keys are redacted like attributes
+func mapValue(m map[string]any) slog.Value {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	attrs := make([]slog.Attr, len(keys))
+	for i, k := range keys {
+		attrs[i] = slog.Any(k, m[k])
+	}
+	return slog.GroupValue(attrs...)
+}
+
+var _ slog.Handler = (*RedactHandler)(nil)
@@ -0,0 +1,85 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRedactHandler(t *testing.T) {
+	var buf bytes.Buffer
+	policy := DefaultRedactPolicy()
+	policy.Keys["phone"] = RedactMask
+	l := slog.New(NewRedactHandler(slog.NewJSONHandler(&buf, nil), policy)).
+		With("session_id", "5f1c0d9e-secret-session")
+
+	l.Info("Verification code for john.doe@example.com",
+		"email", "john.doe@example.com",
+		"code", "123456",
+		"phone", "+77011234567",
+		"error", errors.New("send to jane@example.org failed"),
+		"data", map[string]any{"code": "654321", "expires_in": "10m"},
+		slog.Group("event", "recipient", "bob@example.net"),
+	)
+
+	line := buf.String()
+	for _, leaked := range []string{
+		"john.doe@example.com", "jane@example.org", "bob@example.net",
+		"123456", "654321", "+77011234567", "5f1c0d9e-secret-session",
+	} {
+		if strings.Contains(line, leaked) {
+			t.Errorf("Expected %q to be redacted in %s", leaked, line)
+		}
+	}
+	for _, want := range []string{
+		`"msg":"Verification code for j***@example.com"`,
+		`"email":"j***@example.com"`,
+		`"code":"[REDACTED]"`,
+		`"phone":"+7***67"`,
+		`"error":"send to j***@example.org failed"`,
+		`"expires_in":"10m"`,
+		`"recipient":"b***@example.net"`,
+		`"session_id":"sha256:`,
+	} {
+		if !strings.Contains(line, want) {
+			t.Errorf("Expected %s in %s", want, line)
+		}
+	}
+}
+
+func TestRedactHashIsStableAndSalted(t *testing.T) {
+	plain := DefaultRedactPolicy()
+	salted := DefaultRedactPolicy()
+	salted.Salt = []byte("pepper")
+
+	if plain.hash("abc") != plain.hash("abc") {
+		t.Error("Expected hashes of the same value to match")
+	}
+	if plain.hash("abc") == salted.hash("abc") {
+		t.Error("Expected the salt to change the hash")
+	}
+}
+
+func TestRedactionEnabled(t *testing.T) {
+	tests := []struct {
+		appEnv, logRedact string
+		want              bool
+	}{
+		{"production", "", true},
+		{"", "", true},
+		{"local", "", false},
+		{"development", "", false},
+		{"local", "true", true},
+		{"production", "false", false},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("APP_ENV", tt.appEnv)
+		t.Setenv("LOG_REDACT", tt.logRedact)
+		if got := redactionEnabled(); got != tt.want {
+			t.Errorf("APP_ENV=%q LOG_REDACT=%q: expected %v, got %v", tt.appEnv, tt.logRedact, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// dbPools exports pgxpool statistics for every registered pool
+type dbPools struct {
+	mu    sync.Mutex
+	pools map[string]*pgxpool.Pool
+
+	totalConns    *prometheus.Desc
+	idleConns     *prometheus.Desc
+	acquiredConns *prometheus.Desc
+	maxConns      *prometheus.Desc
+	acquires      *prometheus.Desc
+	acquireWait   *prometheus.Desc
+	emptyAcquires *prometheus.Desc
+}
+
+var pools = newDBPools()
+
+func init() {
+	prometheus.MustRegister(pools)
+}
+
+func newDBPools() *dbPools {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, []string{"pool"}, nil)
+	}
+	return &dbPools{
+		pools:         make(map[string]*pgxpool.Pool),
+		totalConns:    desc("total_conns", "Open connections in the pool."),
+		idleConns:     desc("idle_conns", "Idle connections in the pool."),
+		acquiredConns: desc("acquired_conns", "Connections currently in use."),
+		maxConns:      desc("max_conns", "Maximum size of the pool."),
+		acquires:      desc("acquires_total", "Connections acquired from the pool."),
+		acquireWait:   desc("acquire_wait_seconds_total", "Time spent waiting for a connection."),
+		emptyAcquires: desc("empty_acquires_total", "Acquires that had to wait because the pool was empty."),
+	}
+}
+
+// RegisterDBPool exports the statistics of pool under the given name,
+// replacing any pool previously registered with that name
+func RegisterDBPool(name string, pool *pgxpool.Pool) {
+	pools.mu.Lock()
+	defer pools.mu.Unlock()
+	pools.pools[name] = pool
+}
+
+// UnregisterDBPool stops exporting the pool registered under name
+func UnregisterDBPool(name string) {
+	pools.mu.Lock()
+	defer pools.mu.Unlock()
+	delete(pools.pools, name)
+}
+
+func (p *dbPools) Describe(ch chan<- *prometheus.Desc) {
+	ch <- p.totalConns
+	ch <- p.idleConns
+	ch <- p.acquiredConns
+	ch <- p.maxConns
+	ch <- p.acquires
+	ch <- p.acquireWait
+	ch <- p.emptyAcquires
+}
+
+func (p *dbPools) Collect(ch chan<- prometheus.Metric) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for name, pool := range p.pools {
+		stat := pool.Stat()
+		ch <- prometheus.MustNewConstMetric(p.totalConns, prometheus.GaugeValue, float64(stat.TotalConns()), name)
+		ch <- prometheus.MustNewConstMetric(p.idleConns, prometheus.GaugeValue, float64(stat.IdleConns()), name)
+		ch <- prometheus.MustNewConstMetric(p.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()), name)
+		ch <- prometheus.MustNewConstMetric(p.maxConns, prometheus.GaugeValue, float64(stat.MaxConns()), name)
+		ch <- prometheus.MustNewConstMetric(p.acquires, prometheus.CounterValue, float64(stat.AcquireCount()), name)
+		ch <- prometheus.MustNewConstMetric(p.acquireWait, prometheus.CounterValue, stat.AcquireDuration().Seconds(), name)
+		ch <- prometheus.MustNewConstMetric(p.emptyAcquires, prometheus.CounterValue, float64(stat.EmptyAcquireCount()), name)
+	}
+}
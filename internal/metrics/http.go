@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Path is where metrics are served
+const Path = "/metrics"
+
+// Middleware records the latency and response size of every request.
+// Requests are labeled by route template (e.g. /posts/:id) rather than the
+// raw path, so IDs don't create new series; unmatched paths share one label.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+
+		httpRequestDuration.WithLabelValues(route, method, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+		if size := c.Writer.Size(); size >= 0 {
+			httpResponseSize.WithLabelValues(route, method).Observe(float64(size))
+		}
+	}
+}
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Instrument adds the request middleware to r and serves metrics on /metrics.
+// Call it before registering routes: gin only applies middleware to routes
+// added after it.
+func Instrument(r *gin.Engine) {
+	r.Use(Middleware())
+	r.GET(Path, gin.WrapH(Handler()))
+}
@@ -0,0 +1,136 @@
+// Package metrics holds the Prometheus metrics shared by all services and
+// serves them on /metrics. Metrics are registered with the default registry,
+// which also exports Go runtime and process metrics.
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "instant"
+
+var (
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route template, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	httpResponseSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_response_size_bytes",
+		Help:      "HTTP response body size by route template and method.",
+		Buckets:   prometheus.ExponentialBuckets(128, 4, 8),
+	}, []string{"route", "method"})
+
+	cacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Cache lookups by cache name and result (hit or miss).",
+	}, []string{"cache", "result"})
+
+	kafkaProduced = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kafka_messages_produced_total",
+		Help:      "Kafka messages produced by topic and result (ok or error).",
+	}, []string{"topic", "result"})
+
+	kafkaConsumed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kafka_messages_consumed_total",
+		Help:      "Kafka messages consumed by topic and how they were handled.",
+	}, []string{"topic", "result"})
+
+	kafkaDLQ = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kafka_dlq_messages_total",
+		Help:      "Messages sent to a dead letter topic by topic and result (ok or error).",
+	}, []string{"topic", "result"})
+
+	upstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "gateway_upstream_duration_seconds",
+		Help:      "Latency of proxied requests per upstream service and outcome (status code, timeout or error), one observation per attempt.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"service", "outcome"})
+
+	openStreams = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "gateway_open_streams",
+		Help:      "WebSocket and SSE connections currently proxied per upstream service and kind.",
+	}, []string{"service", "kind"})
+
+	streamsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "gateway_streams_total",
+		Help:      "WebSocket and SSE connections proxied per upstream service and kind.",
+	}, []string{"service", "kind"})
+)
+
+// Consumed message results
+const (
+	ResultProcessed = "processed"
+	ResultDuplicate = "duplicate"
+	ResultInvalid   = "invalid"
+	ResultFailed    = "failed"
+	ResultRetry     = "retry"
+)
+
+// CacheHit counts a cache lookup that found a usable entry
+func CacheHit(cache string) {
+	cacheRequests.WithLabelValues(cache, "hit").Inc()
+}
+
+// CacheMiss counts a cache lookup that had to fall through to the source
+func CacheMiss(cache string) {
+	cacheRequests.WithLabelValues(cache, "miss").Inc()
+}
+
+// KafkaProduced counts a produced message; err is the produce or delivery error
+func KafkaProduced(topic string, err error) {
+	kafkaProduced.WithLabelValues(topic, result(err)).Inc()
+}
+
+// KafkaConsumed counts a consumed message with one of the Result* values
+func KafkaConsumed(topic, result string) {
+	kafkaConsumed.WithLabelValues(topic, result).Inc()
+}
+
+// KafkaDLQ counts a message sent to the dead letter topic
+func KafkaDLQ(topic string, err error) {
+	kafkaDLQ.WithLabelValues(topic, result(err)).Inc()
+}
+
+// ObserveUpstream records one proxied attempt to service. status is the
+// upstream response code, or 0 if no response was received.
+func ObserveUpstream(service string, status int, timedOut bool, elapsed time.Duration) {
+	outcome := "error"
+	switch {
+	case status != 0:
+		outcome = strconv.Itoa(status)
+	case timedOut:
+		outcome = "timeout"
+	}
+	upstreamDuration.WithLabelValues(service, outcome).Observe(elapsed.Seconds())
+}
+
+// StreamOpened counts a long-lived connection proxied to service; kind is
+// "websocket" or "sse". Call the returned function once when it closes.
+func StreamOpened(service, kind string) (closed func()) {
+	streamsTotal.WithLabelValues(service, kind).Inc()
+	open := openStreams.WithLabelValues(service, kind)
+	open.Inc()
+	return open.Dec
+}
+
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
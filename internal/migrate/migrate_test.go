@@ -0,0 +1,60 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"instant/migrations"
+)
+
+func TestLoad_EmbeddedMigrations(t *testing.T) {
+	loaded, err := Load(migrations.FS)
+	if err != nil {
+		t.Fatalf("Embedded migrations are invalid: %v", err)
+	}
+	if len(loaded) == 0 {
+		t.Fatal("Expected embedded migrations")
+	}
+	for i := 1; i < len(loaded); i++ {
+		if loaded[i].Version <= loaded[i-1].Version {
+			t.Errorf("Migrations not sorted: %d after %d", loaded[i].Version, loaded[i-1].Version)
+		}
+	}
+}
+
+func TestLoad_RejectsDuplicateVersions(t *testing.T) {
+	fsys := fstest.MapFS{
+		"001_users.up.sql":    {Data: []byte("SELECT 1")},
+		"001_comments.up.sql": {Data: []byte("SELECT 1")},
+	}
+	if _, err := Load(fsys); err == nil {
+		t.Error("Expected error for duplicate versions")
+	}
+}
+
+func TestLoad_RejectsBadNamesAndMissingUp(t *testing.T) {
+	if _, err := Load(fstest.MapFS{"001_users.sql": {Data: []byte("SELECT 1")}}); err == nil {
+		t.Error("Expected error for file without direction")
+	}
+	if _, err := Load(fstest.MapFS{"001_users.down.sql": {Data: []byte("SELECT 1")}}); err == nil {
+		t.Error("Expected error for migration without up file")
+	}
+}
+
+func TestLoad_PairsUpAndDown(t *testing.T) {
+	loaded, err := Load(fstest.MapFS{
+		"002_posts.up.sql":   {Data: []byte("CREATE TABLE posts ()")},
+		"002_posts.down.sql": {Data: []byte("DROP TABLE posts")},
+		"001_users.up.sql":   {Data: []byte("CREATE TABLE users ()")},
+		"README.md":          {Data: []byte("not a migration")},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(loaded) != 2 || loaded[0].Version != 1 || loaded[1].Version != 2 {
+		t.Fatalf("Unexpected migrations: %+v", loaded)
+	}
+	if loaded[1].Down != "DROP TABLE posts" || loaded[0].Down != "" {
+		t.Errorf("Down files not paired correctly")
+	}
+}
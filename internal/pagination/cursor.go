@@ -0,0 +1,58 @@
+// Package pagination implements the keyset cursors used to page through
+// posts newest first, shared by the posts listings and the home timeline.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is the keyset position of the last post on a page. Posts are
+// ordered by (created_at, post_id) descending, so the pair is unique and
+// stable even when new posts are inserted between requests, and the next
+// page starts strictly after it.
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	PostID    int64     `json:"id"`
+}
+
+// EncodeCursor returns the opaque, URL-safe token pointing after the given post
+func EncodeCursor(createdAt time.Time, postID int64) string {
+	data, _ := json.Marshal(Cursor{CreatedAt: createdAt, PostID: postID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a token produced by EncodeCursor. Malformed or
+// tampered tokens return ErrInvalidCursor.
+func DecodeCursor(token string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.PostID <= 0 || c.CreatedAt.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
+
+// Condition returns the SQL condition selecting the rows after the cursor,
+// with the cursor's CreatedAt and PostID bound to $n and $n+1.
+// created_at <= $n bounds the index scan; the OR breaks ties on post_id.
+func (c *Cursor) Condition(createdAtColumn, postIDColumn string, n int) string {
+	return fmt.Sprintf("%[1]s <= $%[3]d AND (%[1]s < $%[3]d OR %[2]s < $%[4]d)",
+		createdAtColumn, postIDColumn, n, n+1)
+}
+
+// Includes reports whether a post comes after the cursor; it is Condition
+// for posts already in memory
+func (c *Cursor) Includes(createdAt time.Time, postID int64) bool {
+	return !createdAt.After(c.CreatedAt) && (createdAt.Before(c.CreatedAt) || postID < c.PostID)
+}
@@ -0,0 +1,143 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"sort"
+	"testing"
+	"time"
+)
+
+var base = time.Date(2025, 3, 1, 9, 30, 0, 123456000, time.UTC)
+
+func TestCursorRoundTrip(t *testing.T) {
+	token := EncodeCursor(base, 42)
+	c, err := DecodeCursor(token)
+	if err != nil {
+		t.Fatalf("Expected the token to decode, got %v", err)
+	}
+	if !c.CreatedAt.Equal(base) || c.PostID != 42 {
+		t.Errorf("Expected (%s, 42), got (%s, %d)", base, c.CreatedAt, c.PostID)
+	}
+}
+
+func TestDecodeCursorRejectsInvalidTokens(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	valid := EncodeCursor(base, 42)
+	flipped := []byte(valid)
+	flipped[3] ^= 0x01
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"not base64", "!!!"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte(`{"t":"2025-03-01T09:30:00Z","id":1}`))},
+		{"not json", encode("hello")},
+		{"tampered", string(flipped)},
+		{"truncated", valid[:len(valid)-4]},
+		{"zero post id", encode(`{"t":"2025-03-01T09:30:00Z","id":0}`)},
+		{"negative post id", encode(`{"t":"2025-03-01T09:30:00Z","id":-5}`)},
+		{"post id as string", encode(`{"t":"2025-03-01T09:30:00Z","id":"7"}`)},
+		{"missing time", encode(`{"id":7}`)},
+		{"malformed time", encode(`{"t":"yesterday","id":7}`)},
+	}
+	for _, tt := range tests {
+		if _, err := DecodeCursor(tt.token); err != ErrInvalidCursor {
+			t.Errorf("%s: expected ErrInvalidCursor, got %v", tt.name, err)
+		}
+	}
+}
+
+func TestCursorCondition(t *testing.T) {
+	c := &Cursor{CreatedAt: base, PostID: 42}
+	tests := []struct {
+		createdAt, postID string
+		n                 int
+		want              string
+	}{
+		{"created_at", "post_id", 1, "created_at <= $1 AND (created_at < $1 OR post_id < $2)"},
+		{"created_at", "post_id", 2, "created_at <= $2 AND (created_at < $2 OR post_id < $3)"},
+		{"p.created_at", "p.post_id", 3, "p.created_at <= $3 AND (p.created_at < $3 OR p.post_id < $4)"},
+	}
+	for _, tt := range tests {
+		if got := c.Condition(tt.createdAt, tt.postID, tt.n); got != tt.want {
+			t.Errorf("Expected %q, got %q", tt.want, got)
+		}
+	}
+}
+
+func TestCursorIncludes(t *testing.T) {
+	c := &Cursor{CreatedAt: base, PostID: 42}
+	tests := []struct {
+		name      string
+		createdAt time.Time
+		postID    int64
+		want      bool
+	}{
+		{"older", base.Add(-time.Microsecond), 99, true},
+		{"same time, lower id", base, 41, true},
+		{"the cursor's post", base, 42, false},
+		{"same time, higher id", base, 43, false},
+		{"newer", base.Add(time.Microsecond), 1, false},
+	}
+	for _, tt := range tests {
+		if got := c.Includes(tt.createdAt, tt.postID); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestPagingAcrossEqualTimestamps(t *testing.T) {
+	type row struct {
+		createdAt time.Time
+		postID    int64
+	}
+
+	// Runs of posts sharing a timestamp straddle every page boundary
+	var rows []row
+	for id := int64(1); id <= 23; id++ {
+		rows = append(rows, row{createdAt: base.Add(time.Duration(id/5) * time.Second), postID: id})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].createdAt.Equal(rows[j].createdAt) {
+			return rows[i].postID > rows[j].postID
+		}
+		return rows[i].createdAt.After(rows[j].createdAt)
+	})
+
+	for _, pageSize := range []int{1, 2, 3, 4, 5, 7} {
+		seen := make(map[int64]bool)
+		var c *Cursor
+		for pages := 0; ; pages++ {
+			if pages > len(rows) {
+				t.Fatalf("page size %d: paging did not end", pageSize)
+			}
+
+			var page []row
+			for _, r := range rows {
+				if (c == nil || c.Includes(r.createdAt, r.postID)) && len(page) < pageSize {
+					page = append(page, r)
+				}
+			}
+			if len(page) == 0 {
+				break
+			}
+			for _, r := range page {
+				if seen[r.postID] {
+					t.Fatalf("page size %d: post %d returned twice", pageSize, r.postID)
+				}
+				seen[r.postID] = true
+			}
+
+			last := page[len(page)-1]
+			if c, _ = DecodeCursor(EncodeCursor(last.createdAt, last.postID)); c == nil {
+				t.Fatalf("page size %d: cursor did not round trip", pageSize)
+			}
+		}
+		if len(seen) != len(rows) {
+			t.Errorf("page size %d: expected %d posts, got %d", pageSize, len(rows), len(seen))
+		}
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"strconv"
 
+	"instant/internal/identity"
+
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -33,8 +35,8 @@ func NewHandler(service *Service) *Handler {
 // @Security SessionAuth
 // @Router /api/posts [post]
 func (h *Handler) CreatePost(c *gin.Context) {
-	// Get authenticated user ID from context (set by AuthMiddleware)
-	userID, ok := GetUserID(c)
+	// Get authenticated user ID from context (set by identity.RequireUser)
+	userID, ok := identity.UserUUID(c)
 	if !ok {
 		c.JSON(http.StatusUnauthorized, ErrorResponse{
 			Success: false,
@@ -119,12 +121,14 @@ func (h *Handler) GetPost(c *gin.Context) {
 
 // GetAllPosts handles GET /posts with pagination
 // @Summary Get all posts
-// @Description Retrieve all posts with pagination support
+// @Description Retrieve all posts with pagination support. Pass cursor (empty for the first page) to use keyset paging instead of page numbers.
 // @Tags posts
 // @Produce json
+// @Param cursor query string false "Opaque cursor from next_cursor"
 // @Param page query int false "Page number" default(1)
 // @Param page_size query int false "Page size" default(20)
 // @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/posts [get]
 func (h *Handler) GetAllPosts(c *gin.Context) {
@@ -139,7 +143,22 @@ func (h *Handler) GetAllPosts(c *gin.Context) {
 		pageSize = 20
 	}
 
-	posts, err := h.service.GetAllPosts(c.Request.Context(), page, pageSize)
+	var (
+		posts *PaginatedPostsResponse
+		err   error
+	)
+	if cursor, ok := c.GetQuery("cursor"); ok {
+		posts, err = h.service.GetAllPostsByCursor(c.Request.Context(), cursor, pageSize)
+	} else {
+		posts, err = h.service.GetAllPosts(c.Request.Context(), page, pageSize)
+	}
+	if errors.Is(err, ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Error:   "Invalid cursor",
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Success: false,
@@ -156,10 +175,11 @@ func (h *Handler) GetAllPosts(c *gin.Context) {
 
 // GetUserPosts handles GET /users/:user_id/posts
 // @Summary Get posts by user
-// @Description Retrieve all posts created by a specific user with pagination
+// @Description Retrieve all posts created by a specific user with pagination. Pass cursor (empty for the first page) to use keyset paging instead of page numbers.
 // @Tags posts
 // @Produce json
 // @Param user_id path string true "User ID (UUID)"
+// @Param cursor query string false "Opaque cursor from next_cursor"
 // @Param page query int false "Page number" default(1)
 // @Param page_size query int false "Page size" default(20)
 // @Success 200 {object} map[string]interface{}
@@ -187,7 +207,19 @@ func (h *Handler) GetUserPosts(c *gin.Context) {
 		pageSize = 20
 	}
 
-	posts, err := h.service.GetUserPosts(c.Request.Context(), userID, page, pageSize)
+	var posts *PaginatedPostsResponse
+	if cursor, ok := c.GetQuery("cursor"); ok {
+		posts, err = h.service.GetUserPostsByCursor(c.Request.Context(), userID, cursor, pageSize)
+	} else {
+		posts, err = h.service.GetUserPosts(c.Request.Context(), userID, page, pageSize)
+	}
+	if errors.Is(err, ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Error:   "Invalid cursor",
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Success: false,
@@ -220,7 +252,7 @@ func (h *Handler) GetUserPosts(c *gin.Context) {
 // @Router /api/posts/{id} [patch]
 func (h *Handler) UpdatePost(c *gin.Context) {
 	// Get authenticated user ID from context
-	userID, ok := GetUserID(c)
+	userID, ok := identity.UserUUID(c)
 	if !ok {
 		c.JSON(http.StatusUnauthorized, ErrorResponse{
 			Success: false,
@@ -293,7 +325,7 @@ func (h *Handler) UpdatePost(c *gin.Context) {
 // @Router /api/posts/{id} [delete]
 func (h *Handler) DeletePost(c *gin.Context) {
 	// Get authenticated user ID from context
-	userID, ok := GetUserID(c)
+	userID, ok := identity.UserUUID(c)
 	if !ok {
 		c.JSON(http.StatusUnauthorized, ErrorResponse{
 			Success: false,
@@ -17,7 +17,7 @@ type Post struct {
 }
 
 // CreatePostRequest represents the request body for creating a new post
-// Note: user_id is extracted from authentication context (X-User-ID header), not from request body
+// Note: user_id is extracted from authentication context (signed X-User-ID header), not from request body
 type CreatePostRequest struct {
 	Caption  string `json:"caption" binding:"required,max=1000"`
 	ImageURL string `json:"image_url" binding:"required"` // Can be file_key from MinIO or full URL
@@ -29,13 +29,17 @@ type UpdatePostRequest struct {
 	ImageURL *string `json:"image_url,omitempty"` // Can be file_key from MinIO or full URL
 }
 
-// PaginatedPostsResponse represents paginated posts response
+// PaginatedPostsResponse represents paginated posts response.
+// Page, TotalCount and TotalPages are only filled for offset paging;
+// NextCursor is set whenever another page exists.
 type PaginatedPostsResponse struct {
 	Posts      []Post `json:"posts"`
 	Page       int    `json:"page"`
 	PageSize   int    `json:"page_size"`
 	TotalCount int64  `json:"total_count"`
 	TotalPages int    `json:"total_pages"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
 }
 
 // PostResponse is a standard response wrapper
@@ -5,25 +5,42 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
 
 	"github.com/google/uuid"
 	"instant/internal/database"
+	"instant/internal/logger"
+	"instant/internal/pagination"
 )
 
 var (
-	ErrPostNotFound = errors.New("post not found")
-	ErrUnauthorized = errors.New("unauthorized to modify this post")
+	ErrPostNotFound  = errors.New("post not found")
+	ErrUnauthorized  = errors.New("unauthorized to modify this post")
+	ErrInvalidCursor = pagination.ErrInvalidCursor
 )
 
 // Repository handles all database operations for posts
 type Repository struct {
-	db database.Service
+	// db is the pool, or a transaction for repositories returned by WithTx
+	db   database.Querier
+	pool database.Service
 }
 
 // NewRepository creates a new posts repository
 func NewRepository(db database.Service) *Repository {
-	return &Repository{db: db}
+	return &Repository{db: db, pool: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx
+func (r *Repository) WithTx(tx database.Tx) *Repository {
+	return &Repository{db: tx, pool: r.pool}
+}
+
+// InTx runs fn with a repository bound to a new transaction.
+// The transaction is committed if fn returns nil and rolled back otherwise.
+func (r *Repository) InTx(ctx context.Context, fn func(repo *Repository) error) error {
+	return r.pool.WithTx(ctx, nil, func(tx database.Tx) error {
+		return fn(r.WithTx(tx))
+	})
 }
 
 // Create inserts a new post into the database
@@ -45,7 +62,7 @@ func (r *Repository) Create(ctx context.Context, userID uuid.UUID, caption, imag
 	)
 
 	if err != nil {
-		log.Printf("Error creating post: %v", err)
+		logger.FromContext(ctx).Error("Failed to create post", "error", err)
 		return nil, fmt.Errorf("failed to create post: %w", err)
 	}
 
@@ -54,11 +71,20 @@ func (r *Repository) Create(ctx context.Context, userID uuid.UUID, caption, imag
 
 // GetByID retrieves a single post by ID
 func (r *Repository) GetByID(ctx context.Context, postID int64) (*Post, error) {
+	return r.getByID(ctx, postID, false)
+}
+
+// getByID retrieves a post, optionally locking its row until the
+// surrounding transaction ends
+func (r *Repository) getByID(ctx context.Context, postID int64, forUpdate bool) (*Post, error) {
 	query := `
 		SELECT post_id, user_id, caption, image_url, created_at, updated_at
 		FROM posts
 		WHERE post_id = $1
 	`
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
 
 	post := &Post{}
 	err := r.db.QueryRow(ctx, query, postID).Scan(
@@ -74,7 +100,7 @@ func (r *Repository) GetByID(ctx context.Context, postID int64) (*Post, error) {
 		return nil, ErrPostNotFound
 	}
 	if err != nil {
-		log.Printf("Error getting post by ID: %v", err)
+		logger.FromContext(ctx).Error("Failed to get post", "post_id", postID, "error", err)
 		return nil, fmt.Errorf("failed to get post: %w", err)
 	}
 
@@ -98,7 +124,7 @@ func (r *Repository) GetAll(ctx context.Context, page, pageSize int) ([]Post, in
 	countQuery := `SELECT COUNT(*) FROM posts`
 	err := r.db.QueryRow(ctx, countQuery).Scan(&totalCount)
 	if err != nil {
-		log.Printf("Error counting posts: %v", err)
+		logger.FromContext(ctx).Error("Failed to count posts", "error", err)
 		return nil, 0, fmt.Errorf("failed to count posts: %w", err)
 	}
 
@@ -106,7 +132,7 @@ func (r *Repository) GetAll(ctx context.Context, page, pageSize int) ([]Post, in
 	query := `
 		SELECT post_id, user_id, caption, image_url, created_at, updated_at
 		FROM posts
-		ORDER BY created_at DESC
+		ORDER BY created_at DESC, post_id DESC
 		LIMIT $1 OFFSET $2
 	`
 
@@ -135,7 +161,7 @@ func (r *Repository) GetByUserID(ctx context.Context, userID uuid.UUID, page, pa
 	countQuery := `SELECT COUNT(*) FROM posts WHERE user_id = $1`
 	err := r.db.QueryRow(ctx, countQuery, userID).Scan(&totalCount)
 	if err != nil {
-		log.Printf("Error counting user posts: %v", err)
+		logger.FromContext(ctx).Error("Failed to count user posts", "author_id", userID, "error", err)
 		return nil, 0, fmt.Errorf("failed to count user posts: %w", err)
 	}
 
@@ -144,7 +170,7 @@ func (r *Repository) GetByUserID(ctx context.Context, userID uuid.UUID, page, pa
 		SELECT post_id, user_id, caption, image_url, created_at, updated_at
 		FROM posts
 		WHERE user_id = $1
-		ORDER BY created_at DESC
+		ORDER BY created_at DESC, post_id DESC
 		LIMIT $2 OFFSET $3
 	`
 
@@ -156,10 +182,73 @@ func (r *Repository) GetByUserID(ctx context.Context, userID uuid.UUID, page, pa
 	return rows, totalCount, nil
 }
 
-// Update modifies an existing post (only if user owns it)
+// GetAllAfter retrieves up to limit posts that come after the cursor (newest first).
+// A nil cursor starts from the newest post. Unlike GetAll it runs no COUNT(*)
+// and is served by idx_posts_created_at_desc however deep the page is.
+func (r *Repository) GetAllAfter(ctx context.Context, cursor *pagination.Cursor, limit int) ([]Post, error) {
+	if cursor == nil {
+		query := `
+			SELECT post_id, user_id, caption, image_url, created_at, updated_at
+			FROM posts
+			ORDER BY created_at DESC, post_id DESC
+			LIMIT $1
+		`
+		return r.queryRows(ctx, query, limit)
+	}
+
+	query := `
+		SELECT post_id, user_id, caption, image_url, created_at, updated_at
+		FROM posts
+		WHERE ` + cursor.Condition("created_at", "post_id", 1) + `
+		ORDER BY created_at DESC, post_id DESC
+		LIMIT $3
+	`
+	return r.queryRows(ctx, query, cursor.CreatedAt, cursor.PostID, limit)
+}
+
+// GetByUserIDAfter retrieves up to limit posts by a user that come after the cursor.
+// It is served by idx_posts_user_created.
+func (r *Repository) GetByUserIDAfter(ctx context.Context, userID uuid.UUID, cursor *pagination.Cursor, limit int) ([]Post, error) {
+	if cursor == nil {
+		query := `
+			SELECT post_id, user_id, caption, image_url, created_at, updated_at
+			FROM posts
+			WHERE user_id = $1
+			ORDER BY created_at DESC, post_id DESC
+			LIMIT $2
+		`
+		return r.queryRows(ctx, query, userID, limit)
+	}
+
+	query := `
+		SELECT post_id, user_id, caption, image_url, created_at, updated_at
+		FROM posts
+		WHERE user_id = $1 AND ` + cursor.Condition("created_at", "post_id", 2) + `
+		ORDER BY created_at DESC, post_id DESC
+		LIMIT $4
+	`
+	return r.queryRows(ctx, query, userID, cursor.CreatedAt, cursor.PostID, limit)
+}
+
+// Update modifies an existing post (only if user owns it).
+// The ownership check and the update run in one transaction with the row
+// locked, so the post cannot change hands or disappear in between.
 func (r *Repository) Update(ctx context.Context, postID int64, userID uuid.UUID, caption *string, imageURL *string) (*Post, error) {
+	var post *Post
+	err := r.InTx(ctx, func(repo *Repository) error {
+		var err error
+		post, err = repo.update(ctx, postID, userID, caption, imageURL)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+func (r *Repository) update(ctx context.Context, postID int64, userID uuid.UUID, caption *string, imageURL *string) (*Post, error) {
 	// First, verify the post exists and belongs to the user
-	existing, err := r.GetByID(ctx, postID)
+	existing, err := r.getByID(ctx, postID, true)
 	if err != nil {
 		return nil, err
 	}
@@ -213,17 +302,24 @@ func (r *Repository) Update(ctx context.Context, postID int64, userID uuid.UUID,
 		return nil, ErrPostNotFound
 	}
 	if err != nil {
-		log.Printf("Error updating post: %v", err)
+		logger.FromContext(ctx).Error("Failed to update post", "post_id", postID, "error", err)
 		return nil, fmt.Errorf("failed to update post: %w", err)
 	}
 
 	return post, nil
 }
 
-// Delete removes a post (only if user owns it)
+// Delete removes a post (only if user owns it).
+// Like Update, the ownership check and the delete share one transaction.
 func (r *Repository) Delete(ctx context.Context, postID int64, userID uuid.UUID) error {
+	return r.InTx(ctx, func(repo *Repository) error {
+		return repo.delete(ctx, postID, userID)
+	})
+}
+
+func (r *Repository) delete(ctx context.Context, postID int64, userID uuid.UUID) error {
 	// First verify ownership
-	existing, err := r.GetByID(ctx, postID)
+	existing, err := r.getByID(ctx, postID, true)
 	if err != nil {
 		return err
 	}
@@ -235,7 +331,7 @@ func (r *Repository) Delete(ctx context.Context, postID int64, userID uuid.UUID)
 	query := `DELETE FROM posts WHERE post_id = $1 AND user_id = $2`
 	result, err := r.db.Exec(ctx, query, postID, userID)
 	if err != nil {
-		log.Printf("Error deleting post: %v", err)
+		logger.FromContext(ctx).Error("Failed to delete post", "post_id", postID, "error", err)
 		return fmt.Errorf("failed to delete post: %w", err)
 	}
 
@@ -255,7 +351,7 @@ func (r *Repository) Delete(ctx context.Context, postID int64, userID uuid.UUID)
 func (r *Repository) queryRows(ctx context.Context, query string, args ...interface{}) ([]Post, error) {
 	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
-		log.Printf("Error querying posts: %v", err)
+		logger.FromContext(ctx).Error("Failed to query posts", "error", err)
 		return nil, fmt.Errorf("failed to query posts: %w", err)
 	}
 	defer rows.Close()
@@ -272,14 +368,14 @@ func (r *Repository) queryRows(ctx context.Context, query string, args ...interf
 			&post.UpdatedAt,
 		)
 		if err != nil {
-			log.Printf("Error scanning post row: %v", err)
+			logger.FromContext(ctx).Error("Failed to scan post row", "error", err)
 			return nil, fmt.Errorf("failed to scan post: %w", err)
 		}
 		posts = append(posts, post)
 	}
 
 	if err = rows.Err(); err != nil {
-		log.Printf("Error iterating posts: %v", err)
+		logger.FromContext(ctx).Error("Failed to iterate posts", "error", err)
 		return nil, fmt.Errorf("failed to iterate posts: %w", err)
 	}
 
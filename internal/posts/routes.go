@@ -4,20 +4,19 @@ import (
 	"net/http"
 	"os"
 
-	"github.com/gin-contrib/cors"
+	"instant/internal/identity"
+	"instant/internal/logger"
+	"instant/internal/metrics"
+	"instant/internal/tracing"
+
 	"github.com/gin-gonic/gin"
 )
 
 func (s *Server) RegisterRoutes() http.Handler {
 	r := gin.Default()
-
-	// CORS configuration
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:5173", "http://localhost:3000", "http://localhost:8080"}, // Add frontend and gateway URLs
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowHeaders:     []string{"Accept", "Authorization", "Content-Type", "X-User-ID", "X-User-Email"},
-		AllowCredentials: true, // Enable cookies/auth
-	}))
+	r.Use(logger.Middleware())
+	r.Use(tracing.Middleware())
+	metrics.Instrument(r) // request metrics and /metrics
 
 	// Initialize repository, service, and handler
 	repo := NewRepository(s.db)
@@ -26,26 +25,35 @@ func (s *Server) RegisterRoutes() http.Handler {
 	redisPassword := getEnv("REDIS_PASSWORD", "")
 	redisDB := 0
 
-	service := NewService(repo, redisAddr, redisPassword, redisDB)
+	var service *Service
+	if s.kafkaProducer != nil {
+		service = NewServiceWithKafka(repo, redisAddr, redisPassword, redisDB, s.kafkaProducer, s.kafkaConfig.PostEventsTopic)
+	} else {
+		service = NewService(repo, redisAddr, redisPassword, redisDB)
+	}
 	handler := NewHandler(service)
 
 	// Health check endpoint (public, no auth required)
 	r.GET("/health", handler.Health)
 
-	// Posts API endpoints - all require authentication via Gateway
+	// Reads are public: the gateway forwards the viewer's identity when they
+	// are signed in. Changes require authentication via Gateway.
+	requireUser := identity.RequireUser(s.signer) // Verify identity signed by the gateway
+	optionalUser := identity.OptionalUser(s.signer)
+	readYourWrites := identity.ReadYourWrites(s.db.WriteTracker())
+
 	postsGroup := r.Group("/posts")
-	postsGroup.Use(AuthMiddleware()) // Validate X-User-ID header from gateway
 	{
-		postsGroup.GET("", handler.GetAllPosts)           // GET /posts?page=1&page_size=20
-		postsGroup.POST("", handler.CreatePost)           // POST /posts
-		postsGroup.GET("/:id", handler.GetPost)           // GET /posts/:id
-		postsGroup.PATCH("/:id", handler.UpdatePost)      // PATCH /posts/:id
-		postsGroup.DELETE("/:id", handler.DeletePost)     // DELETE /posts/:id
+		postsGroup.GET("", optionalUser, readYourWrites, handler.GetAllPosts)      // GET /posts?page=1&page_size=20
+		postsGroup.POST("", requireUser, readYourWrites, handler.CreatePost)       // POST /posts
+		postsGroup.GET("/:id", optionalUser, readYourWrites, handler.GetPost)      // GET /posts/:id
+		postsGroup.PATCH("/:id", requireUser, readYourWrites, handler.UpdatePost)  // PATCH /posts/:id
+		postsGroup.DELETE("/:id", requireUser, readYourWrites, handler.DeletePost) // DELETE /posts/:id
 	}
 
-	// User posts endpoint - requires auth
+	// User posts endpoint - public
 	users := r.Group("/users")
-	users.Use(AuthMiddleware())
+	users.Use(optionalUser, readYourWrites)
 	{
 		users.GET("/:user_id/posts", handler.GetUserPosts) // GET /users/:user_id/posts?page=1&page_size=20
 	}
@@ -3,7 +3,9 @@
 package posts
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"strconv"
@@ -12,20 +14,55 @@ import (
 	_ "github.com/joho/godotenv/autoload"
 
 	"instant/internal/database"
+	"instant/internal/identity"
+	kafkapkg "instant/internal/kafka"
+	"instant/internal/migrate"
 )
 
 type Server struct {
 	port int
 
-	db database.Service
+	db     database.Service
+	signer *identity.Signer
+
+	kafkaProducer *kafkapkg.Producer
+	kafkaConfig   *kafkapkg.Config
 }
 
 func NewServer() *http.Server {
 	port, _ := strconv.Atoi(os.Getenv("PORT"))
+
+	// Identity headers are signed by the gateway
+	signer, err := identity.LoadSigner()
+	if err != nil {
+		slog.Error("Failed to load identity config", "error", err)
+		os.Exit(1)
+	}
+
 	NewServer := &Server{
 		port: port,
 
-		db: database.New(),
+		db:     database.New(),
+		signer: signer,
+	}
+
+	// Apply pending schema migrations when DB_AUTO_MIGRATE=true
+	if err := migrate.AutoMigrate(context.Background(), NewServer.db.DB()); err != nil {
+		slog.Error("Failed to run database migrations", "error", err)
+		os.Exit(1)
+	}
+
+	// Initialize Kafka producer (optional) for post-created events
+	if os.Getenv("KAFKA_BROKERS") != "" && getEnv("ENABLE_KAFKA", "true") == "true" {
+		kafkaConfig, err := kafkapkg.LoadConfig()
+		if err != nil {
+			slog.Warn("Failed to load Kafka config, post events disabled", "error", err)
+		} else if producer, err := kafkapkg.NewProducer(kafkaConfig, slog.Default()); err != nil {
+			slog.Warn("Failed to create Kafka producer, post events disabled", "error", err)
+		} else {
+			NewServer.kafkaProducer = producer
+			NewServer.kafkaConfig = kafkaConfig
+		}
 	}
 
 	// Declare Server config
@@ -37,5 +74,9 @@ func NewServer() *http.Server {
 		WriteTimeout: 30 * time.Second,
 	}
 
+	if NewServer.kafkaProducer != nil {
+		server.RegisterOnShutdown(NewServer.kafkaProducer.Close)
+	}
+
 	return server
 }
@@ -4,17 +4,27 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
+
+	"instant/internal/database"
+	kafkapkg "instant/internal/kafka"
+	"instant/internal/logger"
+	"instant/internal/metrics"
+	"instant/internal/pagination"
+	"instant/internal/tracing"
 )
 
 // Service handles business logic for posts with caching
 type Service struct {
 	repo  *Repository
 	cache *redis.Client
+
+	kafkaProducer *kafkapkg.Producer
+	postsTopic    string
 }
 
 // NewService creates a new posts service with Redis caching
@@ -25,16 +35,17 @@ func NewService(repo *Repository, redisAddr, redisPassword string, redisDB int)
 		Password: redisPassword,
 		DB:       redisDB,
 	})
+	tracing.InstrumentRedis(rdb)
 
 	// Test connection
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := rdb.Ping(ctx).Err(); err != nil {
-		log.Printf("Warning: Redis connection failed: %v. Caching disabled.", err)
+		slog.Warn("Redis connection failed, caching disabled", "error", err)
 		rdb = nil
 	} else {
-		log.Println("Redis cache connected for posts service")
+		slog.Info("Redis cache connected for posts service")
 	}
 
 	return &Service{
@@ -43,6 +54,15 @@ func NewService(repo *Repository, redisAddr, redisPassword string, redisDB int)
 	}
 }
 
+// NewServiceWithKafka creates a posts service that also publishes post-created
+// events for the feed service
+func NewServiceWithKafka(repo *Repository, redisAddr, redisPassword string, redisDB int, kafkaProducer *kafkapkg.Producer, postsTopic string) *Service {
+	svc := NewService(repo, redisAddr, redisPassword, redisDB)
+	svc.kafkaProducer = kafkaProducer
+	svc.postsTopic = postsTopic
+	return svc
+}
+
 // CreatePost creates a new post and invalidates relevant caches
 func (s *Service) CreatePost(ctx context.Context, userID uuid.UUID, caption, imageURL string) (*Post, error) {
 	post, err := s.repo.Create(ctx, userID, caption, imageURL)
@@ -54,22 +74,48 @@ func (s *Service) CreatePost(ctx context.Context, userID uuid.UUID, caption, ima
 	s.invalidateUserPostsCache(ctx, userID)
 	s.invalidateAllPostsCache(ctx)
 
+	s.publishPostCreated(ctx, post)
+
 	return post, nil
 }
 
+// publishPostCreated queues a post-created event for timeline fan-out.
+// Failures are only logged: followers still get the post from the database.
+func (s *Service) publishPostCreated(ctx context.Context, post *Post) {
+	if s.kafkaProducer == nil {
+		return
+	}
+
+	event := kafkapkg.PostCreatedEvent{
+		EventID:   uuid.New().String(),
+		PostID:    post.PostID,
+		AuthorID:  post.UserID.String(),
+		CreatedAt: post.CreatedAt,
+	}
+
+	// Keyed by author so an author's posts reach each timeline in order
+	if err := s.kafkaProducer.PublishEvent(ctx, s.postsTopic, event.AuthorID, event); err != nil {
+		logger.FromContext(ctx).Error("Failed to publish post-created event", "post_id", post.PostID, "error", err)
+	}
+}
+
 // GetPost retrieves a post by ID with caching
 func (s *Service) GetPost(ctx context.Context, postID int64) (*Post, error) {
+	cache := s.readCache(ctx)
+
 	// Try cache first
-	if s.cache != nil {
+	if cache != nil {
 		cacheKey := fmt.Sprintf("post:%d", postID)
-		cached, err := s.cache.Get(ctx, cacheKey).Result()
+		cached, err := cache.Get(ctx, cacheKey).Result()
 		if err == nil {
 			var post Post
 			if err := json.Unmarshal([]byte(cached), &post); err == nil {
-				log.Printf("Cache hit for post %d", postID)
+				logger.FromContext(ctx).Debug("Cache hit for post", "post_id", postID)
+				metrics.CacheHit("post")
 				return &post, nil
 			}
 		}
+		metrics.CacheMiss("post")
 	}
 
 	// Cache miss - fetch from database
@@ -79,10 +125,10 @@ func (s *Service) GetPost(ctx context.Context, postID int64) (*Post, error) {
 	}
 
 	// Store in cache (5 minute TTL)
-	if s.cache != nil {
+	if cache != nil {
 		cacheKey := fmt.Sprintf("post:%d", postID)
 		data, _ := json.Marshal(post)
-		s.cache.Set(ctx, cacheKey, data, 5*time.Minute)
+		cache.Set(ctx, cacheKey, data, 5*time.Minute)
 	}
 
 	return post, nil
@@ -90,17 +136,21 @@ func (s *Service) GetPost(ctx context.Context, postID int64) (*Post, error) {
 
 // GetAllPosts retrieves all posts with pagination and caching
 func (s *Service) GetAllPosts(ctx context.Context, page, pageSize int) (*PaginatedPostsResponse, error) {
+	cache := s.readCache(ctx)
+
 	// Try cache first
-	if s.cache != nil {
+	if cache != nil {
 		cacheKey := fmt.Sprintf("posts:all:page:%d:size:%d", page, pageSize)
-		cached, err := s.cache.Get(ctx, cacheKey).Result()
+		cached, err := cache.Get(ctx, cacheKey).Result()
 		if err == nil {
 			var response PaginatedPostsResponse
 			if err := json.Unmarshal([]byte(cached), &response); err == nil {
-				log.Printf("Cache hit for posts page %d", page)
+				logger.FromContext(ctx).Debug("Cache hit for posts page", "page", page)
+				metrics.CacheHit("post_list")
 				return &response, nil
 			}
 		}
+		metrics.CacheMiss("post_list")
 	}
 
 	// Cache miss - fetch from database
@@ -120,13 +170,19 @@ func (s *Service) GetAllPosts(ctx context.Context, page, pageSize int) (*Paginat
 		PageSize:   pageSize,
 		TotalCount: totalCount,
 		TotalPages: totalPages,
+		HasMore:    page < totalPages,
+	}
+
+	// Let offset clients switch to cursor paging from any page
+	if response.HasMore && len(posts) > 0 {
+		response.NextCursor = pagination.EncodeCursor(posts[len(posts)-1].CreatedAt, posts[len(posts)-1].PostID)
 	}
 
 	// Store in cache (2 minute TTL for lists)
-	if s.cache != nil {
+	if cache != nil {
 		cacheKey := fmt.Sprintf("posts:all:page:%d:size:%d", page, pageSize)
 		data, _ := json.Marshal(response)
-		s.cache.Set(ctx, cacheKey, data, 2*time.Minute)
+		cache.Set(ctx, cacheKey, data, 2*time.Minute)
 	}
 
 	return response, nil
@@ -134,17 +190,21 @@ func (s *Service) GetAllPosts(ctx context.Context, page, pageSize int) (*Paginat
 
 // GetUserPosts retrieves posts by user ID with pagination and caching
 func (s *Service) GetUserPosts(ctx context.Context, userID uuid.UUID, page, pageSize int) (*PaginatedPostsResponse, error) {
+	cache := s.readCache(ctx)
+
 	// Try cache first
-	if s.cache != nil {
+	if cache != nil {
 		cacheKey := fmt.Sprintf("posts:user:%s:page:%d:size:%d", userID.String(), page, pageSize)
-		cached, err := s.cache.Get(ctx, cacheKey).Result()
+		cached, err := cache.Get(ctx, cacheKey).Result()
 		if err == nil {
 			var response PaginatedPostsResponse
 			if err := json.Unmarshal([]byte(cached), &response); err == nil {
-				log.Printf("Cache hit for user %s posts page %d", userID.String(), page)
+				logger.FromContext(ctx).Debug("Cache hit for user posts page", "author_id", userID.String(), "page", page)
+				metrics.CacheHit("post_list")
 				return &response, nil
 			}
 		}
+		metrics.CacheMiss("post_list")
 	}
 
 	// Cache miss - fetch from database
@@ -164,18 +224,131 @@ func (s *Service) GetUserPosts(ctx context.Context, userID uuid.UUID, page, page
 		PageSize:   pageSize,
 		TotalCount: totalCount,
 		TotalPages: totalPages,
+		HasMore:    page < totalPages,
+	}
+
+	// Let offset clients switch to cursor paging from any page
+	if response.HasMore && len(posts) > 0 {
+		response.NextCursor = pagination.EncodeCursor(posts[len(posts)-1].CreatedAt, posts[len(posts)-1].PostID)
 	}
 
 	// Store in cache (2 minute TTL for lists)
-	if s.cache != nil {
+	if cache != nil {
 		cacheKey := fmt.Sprintf("posts:user:%s:page:%d:size:%d", userID.String(), page, pageSize)
 		data, _ := json.Marshal(response)
-		s.cache.Set(ctx, cacheKey, data, 2*time.Minute)
+		cache.Set(ctx, cacheKey, data, 2*time.Minute)
+	}
+
+	return response, nil
+}
+
+// GetAllPostsByCursor retrieves the page of posts after the cursor with caching.
+// An empty cursor returns the first page.
+func (s *Service) GetAllPostsByCursor(ctx context.Context, cursor string, pageSize int) (*PaginatedPostsResponse, error) {
+	after, err := parseCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheKey := fmt.Sprintf("posts:all:cursor:%s:size:%d", cursor, pageSize)
+	if response, ok := s.getCachedPage(ctx, cacheKey); ok {
+		return response, nil
+	}
+
+	// One extra row tells whether another page exists
+	posts, err := s.repo.GetAllAfter(ctx, after, pageSize+1)
+	if err != nil {
+		return nil, err
 	}
 
+	response := newCursorPage(posts, pageSize)
+	s.setCachedPage(ctx, cacheKey, response)
+
 	return response, nil
 }
 
+// GetUserPostsByCursor retrieves the page of a user's posts after the cursor with caching
+func (s *Service) GetUserPostsByCursor(ctx context.Context, userID uuid.UUID, cursor string, pageSize int) (*PaginatedPostsResponse, error) {
+	after, err := parseCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheKey := fmt.Sprintf("posts:user:%s:cursor:%s:size:%d", userID.String(), cursor, pageSize)
+	if response, ok := s.getCachedPage(ctx, cacheKey); ok {
+		return response, nil
+	}
+
+	posts, err := s.repo.GetByUserIDAfter(ctx, userID, after, pageSize+1)
+	if err != nil {
+		return nil, err
+	}
+
+	response := newCursorPage(posts, pageSize)
+	s.setCachedPage(ctx, cacheKey, response)
+
+	return response, nil
+}
+
+// parseCursor decodes a cursor token, treating an empty token as the first page
+func parseCursor(cursor string) (*pagination.Cursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	return pagination.DecodeCursor(cursor)
+}
+
+// newCursorPage trims a pageSize+1 result to one page and sets the next cursor
+func newCursorPage(posts []Post, pageSize int) *PaginatedPostsResponse {
+	response := &PaginatedPostsResponse{
+		Posts:    posts,
+		PageSize: pageSize,
+	}
+	if len(posts) > pageSize {
+		response.Posts = posts[:pageSize]
+		response.HasMore = true
+		response.NextCursor = pagination.EncodeCursor(response.Posts[pageSize-1].CreatedAt, response.Posts[pageSize-1].PostID)
+	}
+	return response
+}
+
+func (s *Service) getCachedPage(ctx context.Context, cacheKey string) (*PaginatedPostsResponse, bool) {
+	cache := s.readCache(ctx)
+	if cache == nil {
+		return nil, false
+	}
+	cached, err := cache.Get(ctx, cacheKey).Result()
+	if err != nil {
+		metrics.CacheMiss("post_list")
+		return nil, false
+	}
+	var response PaginatedPostsResponse
+	if err := json.Unmarshal([]byte(cached), &response); err != nil {
+		metrics.CacheMiss("post_list")
+		return nil, false
+	}
+	metrics.CacheHit("post_list")
+	return &response, true
+}
+
+// setCachedPage stores a list page (2 minute TTL for lists)
+func (s *Service) setCachedPage(ctx context.Context, cacheKey string, response *PaginatedPostsResponse) {
+	if cache := s.readCache(ctx); cache != nil {
+		data, _ := json.Marshal(response)
+		cache.Set(ctx, cacheKey, data, 2*time.Minute)
+	}
+}
+
+// readCache returns the cache for reads, or nil when the request is pinned to
+// the primary after the user's own write: a cached copy may predate the write,
+// having been filled from a lagging replica, and is neither read nor replaced
+func (s *Service) readCache(ctx context.Context) *redis.Client {
+	if s.cache == nil || database.IsPrimary(ctx) {
+		return nil
+	}
+	return s.cache
+}
+
 // UpdatePost updates a post and invalidates caches
 func (s *Service) UpdatePost(ctx context.Context, postID int64, userID uuid.UUID, caption *string, imageURL *string) (*Post, error) {
 	post, err := s.repo.Update(ctx, postID, userID, caption, imageURL)
@@ -236,6 +409,6 @@ func (s *Service) deleteByPattern(ctx context.Context, pattern string) {
 		s.cache.Del(ctx, iter.Val())
 	}
 	if err := iter.Err(); err != nil {
-		log.Printf("Error scanning cache keys: %v", err)
+		logger.FromContext(ctx).Error("Failed to scan cache keys", "pattern", pattern, "error", err)
 	}
 }
@@ -0,0 +1,88 @@
+package session
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Config controls how long sessions live and how they are refreshed
+type Config struct {
+	// IdleTimeout expires a session that has not been used for this long.
+	// Every refresh pushes the expiry out again.
+	IdleTimeout time.Duration
+	// AbsoluteTimeout caps the lifetime of a session from login, however
+	// active it is
+	AbsoluteTimeout time.Duration
+	// RefreshInterval throttles refreshes, so an active session is written
+	// to Redis at most once per interval rather than on every request
+	RefreshInterval time.Duration
+	// RotationInterval replaces the session ID with a new one after this
+	// long, limiting how long a leaked or fixated ID is useful; 0 disables it
+	RotationInterval time.Duration
+}
+
+// DefaultConfig returns the session lifetimes used when nothing is configured
+func DefaultConfig() Config {
+	return Config{
+		IdleTimeout:      time.Hour,
+		AbsoluteTimeout:  7 * 24 * time.Hour,
+		RefreshInterval:  time.Minute,
+		RotationInterval: 15 * time.Minute,
+	}
+}
+
+// ConfigFromEnv builds a Config from SESSION_IDLE_TIMEOUT,
+// SESSION_ABSOLUTE_TIMEOUT, SESSION_REFRESH_INTERVAL and
+// SESSION_ROTATION_INTERVAL. SESSION_MAX_AGE, in seconds, is still honored
+// as the idle timeout when SESSION_IDLE_TIMEOUT is not set. Unset variables
+// keep the DefaultConfig values.
+func ConfigFromEnv() (Config, error) {
+	cfg := DefaultConfig()
+
+	if v := os.Getenv("SESSION_MAX_AGE"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds <= 0 {
+			return cfg, fmt.Errorf("SESSION_MAX_AGE: invalid value %q", v)
+		}
+		cfg.IdleTimeout = time.Duration(seconds) * time.Second
+	}
+
+	durations := []struct {
+		env   string
+		value *time.Duration
+	}{
+		{"SESSION_IDLE_TIMEOUT", &cfg.IdleTimeout},
+		{"SESSION_ABSOLUTE_TIMEOUT", &cfg.AbsoluteTimeout},
+		{"SESSION_REFRESH_INTERVAL", &cfg.RefreshInterval},
+		{"SESSION_ROTATION_INTERVAL", &cfg.RotationInterval},
+	}
+	for _, d := range durations {
+		v := os.Getenv(d.env)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed < 0 {
+			return cfg, fmt.Errorf("%s: invalid duration %q", d.env, v)
+		}
+		*d.value = parsed
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Validate checks that the lifetimes are consistent
+func (c Config) Validate() error {
+	if c.IdleTimeout <= 0 {
+		return fmt.Errorf("session idle timeout must be positive")
+	}
+	if c.AbsoluteTimeout < c.IdleTimeout {
+		return fmt.Errorf("session absolute timeout (%s) is shorter than the idle timeout (%s)", c.AbsoluteTimeout, c.IdleTimeout)
+	}
+	if c.RefreshInterval >= c.IdleTimeout {
+		return fmt.Errorf("session refresh interval (%s) must be shorter than the idle timeout (%s)", c.RefreshInterval, c.IdleTimeout)
+	}
+	return nil
+}
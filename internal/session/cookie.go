@@ -0,0 +1,63 @@
+package session
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// CookieName is the cookie that carries the session ID
+const CookieName = "session_id"
+
+// NewCookie returns the signed session cookie for sess. It expires together
+// with the session, so it has to be issued again whenever the session is
+// refreshed.
+func (s *CookieSigner) NewCookie(sess *Session) *http.Cookie {
+	maxAge := int(time.Until(sess.ExpiresAt).Seconds())
+	if maxAge < 1 {
+		maxAge = -1
+	}
+
+	return &http.Cookie{
+		Name:     CookieName,
+		Value:    s.Sign(sess.ID),
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   secureCookies(),
+		HttpOnly: true,
+		SameSite: sameSite(),
+	}
+}
+
+// ClearCookie returns a cookie that removes the session cookie
+func ClearCookie() *http.Cookie {
+	return &http.Cookie{
+		Name:     CookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   secureCookies(),
+		HttpOnly: true,
+		SameSite: sameSite(),
+	}
+}
+
+// secureCookies restricts cookies to HTTPS in production. SameSite=None
+// cookies are only accepted by browsers when they are secure.
+func secureCookies() bool {
+	return os.Getenv("APP_ENV") == "production" || sameSite() == http.SameSiteNoneMode
+}
+
+// sameSite reads SESSION_COOKIE_SAMESITE: strict, lax (default) or none.
+// Lax keeps the cookie off cross-site POSTs while following top-level links.
+func sameSite() http.SameSite {
+	switch strings.ToLower(os.Getenv("SESSION_COOKIE_SAMESITE")) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
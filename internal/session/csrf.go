@@ -0,0 +1,27 @@
+package session
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+)
+
+// CSRFHeader is the request header that must carry the CSRF token of the
+// session on state-changing requests
+const CSRFHeader = "X-CSRF-Token"
+
+// newCSRFToken returns a random synchronizer token for a session
+func newCSRFToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// CheckCSRFToken reports whether token is the CSRF token of the session.
+// Sessions without a token accept none.
+func (s *Session) CheckCSRFToken(token string) bool {
+	if s.CSRFToken == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.CSRFToken)) == 1
+}
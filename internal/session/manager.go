@@ -5,9 +5,12 @@ package session
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
@@ -22,61 +25,136 @@ var (
 	ErrInvalidSession = errors.New("invalid session")
 )
 
+// rotationGrace keeps a rotated session ID valid for requests that were
+// already in flight with the old cookie
+const rotationGrace = 30 * time.Second
+
+// maxUserAgentLength bounds the user agent stored with a session
+const maxUserAgentLength = 256
+
 // Manager defines the interface for session management operations
 type Manager interface {
-	Create(ctx context.Context, userID, email string, maxAge int) (string, error)
+	Create(ctx context.Context, userID, email string, client ClientInfo) (*Session, error)
 	Get(ctx context.Context, sessionID string) (*Session, error)
 	Delete(ctx context.Context, sessionID string) error
 	Validate(ctx context.Context, sessionID string) (bool, error)
+	// Refresh slides the idle expiry of an active session and rotates its ID
+	// when due. It writes at most once per RefreshInterval and reports whether
+	// the session changed, in which case its cookie must be issued again.
+	Refresh(ctx context.Context, session *Session) (*Session, bool, error)
+	// List returns the active sessions of a user, newest first
+	List(ctx context.Context, userID string) ([]*Session, error)
+	// RevokeAll deletes every session of a user and returns how many there were
+	RevokeAll(ctx context.Context, userID string) (int, error)
+	// CSRFToken returns the CSRF token of a session, assigning one to
+	// sessions created before tokens existed
+	CSRFToken(ctx context.Context, session *Session) (string, error)
 }
 
 // manager implements Manager interface
 type manager struct {
-	store Store
+	store  Store
+	config Config
 }
 
 // NewManager creates a new session manager
-func NewManager(store Store) Manager {
+func NewManager(store Store, config Config) Manager {
 	return &manager{
-		store: store,
+		store:  store,
+		config: config,
 	}
 }
 
-// Create creates a new session and returns the session ID
-func (m *manager) Create(ctx context.Context, userID, email string, maxAge int) (string, error) {
-	// Generate unique session ID
-	sessionID := uuid.New().String()
+// sessionKey is the Redis key of a session
+func sessionKey(sessionID string) string {
+	return fmt.Sprintf("session:%s", sessionID)
+}
+
+// userSessionsKey is the Redis set indexing the session IDs of a user
+func userSessionsKey(userID string) string {
+	return fmt.Sprintf("user_sessions:%s", userID)
+}
+
+// PublicID returns an identifier for a session that is safe to show to
+// clients. Session IDs are bearer credentials, so APIs that list sessions
+// refer to them by this digest instead.
+func PublicID(sessionID string) string {
+	sum := sha256.Sum256([]byte(sessionID))
+	return hex.EncodeToString(sum[:12])
+}
 
-	// Create session object
+// Create creates a new session for a user
+func (m *manager) Create(ctx context.Context, userID, email string, client ClientInfo) (*Session, error) {
+	userAgent := client.UserAgent
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	// Create session object with a unique ID
 	now := time.Now()
 	session := &Session{
-		ID:        sessionID,
-		UserID:    userID,
-		Email:     email,
-		CreatedAt: now,
-		ExpiresAt: now.Add(time.Duration(maxAge) * time.Second),
+		ID:                uuid.New().String(),
+		UserID:            userID,
+		Email:             email,
+		UserAgent:         userAgent,
+		IP:                client.IP,
+		CreatedAt:         now,
+		LastSeenAt:        now,
+		ExpiresAt:         now.Add(m.config.IdleTimeout),
+		AbsoluteExpiresAt: now.Add(m.config.AbsoluteTimeout),
+		RotatedAt:         now,
+		CSRFToken:         newCSRFToken(),
 	}
 
-	// Serialize session to JSON
+	if err := m.save(ctx, session, ""); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// save stores a new session and indexes it under its user. The index lives
+// as long as the longest-lived session in it. With a guard, the session is
+// only stored while the guard key exists; see rotate.
+func (m *manager) save(ctx context.Context, session *Session, guard string) error {
 	sessionData, err := json.Marshal(session)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal session: %w", err)
+		return fmt.Errorf("failed to marshal session: %w", err)
 	}
 
-	// Store in Redis with TTL
-	key := fmt.Sprintf("session:%s", sessionID)
-	ttl := time.Duration(maxAge) * time.Second
+	stored, err := m.store.SetIndexed(ctx, sessionKey(session.ID), string(sessionData), time.Until(session.ExpiresAt),
+		userSessionsKey(session.UserID), session.ID, guard)
+	if err != nil {
+		return fmt.Errorf("failed to store session: %w", err)
+	}
+	if !stored {
+		return ErrSessionNotFound
+	}
+
+	return nil
+}
 
-	if err := m.store.Set(ctx, key, string(sessionData), ttl); err != nil {
-		return "", fmt.Errorf("failed to store session: %w", err)
+// update rewrites an existing session. It never recreates a session that has
+// been deleted in the meantime.
+func (m *manager) update(ctx context.Context, session *Session) error {
+	sessionData, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %w", err)
 	}
 
-	return sessionID, nil
+	ok, err := m.store.SetIfExists(ctx, sessionKey(session.ID), string(sessionData), time.Until(session.ExpiresAt))
+	if err != nil {
+		return fmt.Errorf("failed to store session: %w", err)
+	}
+	if !ok {
+		return ErrSessionNotFound
+	}
+	return nil
 }
 
 // Get retrieves a session by ID
 func (m *manager) Get(ctx context.Context, sessionID string) (*Session, error) {
-	key := fmt.Sprintf("session:%s", sessionID)
+	key := sessionKey(sessionID)
 
 	// Get from Redis
 	sessionData, err := m.store.Get(ctx, key)
@@ -90,20 +168,33 @@ func (m *manager) Get(ctx context.Context, sessionID string) (*Session, error) {
 		return nil, ErrInvalidSession
 	}
 
-	// Check if session is expired
-	if time.Now().After(session.ExpiresAt) {
+	// Check if session is expired, idle or past its absolute lifetime
+	now := time.Now()
+	if now.After(session.ExpiresAt) || now.After(m.absoluteExpiry(&session)) {
 		// Delete expired session
 		m.store.Delete(ctx, key)
+		m.store.RemoveFromSet(ctx, userSessionsKey(session.UserID), sessionID)
 		return nil, ErrSessionExpired
 	}
 
 	return &session, nil
 }
 
-// Delete removes a session
+// Delete removes a session. Deleting a rotated session ID also deletes the
+// session that replaced it, so logging out with a stale cookie still works.
 func (m *manager) Delete(ctx context.Context, sessionID string) error {
-	key := fmt.Sprintf("session:%s", sessionID)
-	return m.store.Delete(ctx, key)
+	session, err := m.Get(ctx, sessionID)
+	if err == nil {
+		if err := m.store.RemoveFromSet(ctx, userSessionsKey(session.UserID), sessionID); err != nil {
+			return err
+		}
+		if session.ReplacedBy != "" {
+			if err := m.Delete(ctx, session.ReplacedBy); err != nil {
+				return err
+			}
+		}
+	}
+	return m.store.Delete(ctx, sessionKey(sessionID))
 }
 
 // Validate checks if a session exists and is valid
@@ -115,3 +206,137 @@ func (m *manager) Validate(ctx context.Context, sessionID string) (bool, error)
 
 	return session != nil, nil
 }
+
+// Refresh extends the idle expiry of a session up to its absolute expiry and
+// rotates its ID once RotationInterval has passed. The old ID of a rotated
+// session keeps working for rotationGrace and is then gone.
+func (m *manager) Refresh(ctx context.Context, session *Session) (*Session, bool, error) {
+	now := time.Now()
+	if session.ReplacedBy != "" || now.Sub(session.LastSeenAt) < m.config.RefreshInterval {
+		return session, false, nil
+	}
+
+	refreshed := *session
+	refreshed.LastSeenAt = now
+	refreshed.ExpiresAt = now.Add(m.config.IdleTimeout)
+	if absolute := m.absoluteExpiry(session); refreshed.ExpiresAt.After(absolute) {
+		refreshed.ExpiresAt = absolute
+	}
+
+	if m.config.RotationInterval > 0 && now.Sub(session.RotatedAt) >= m.config.RotationInterval {
+		return m.rotate(ctx, session, &refreshed)
+	}
+
+	if err := m.update(ctx, &refreshed); err != nil {
+		return nil, false, err
+	}
+	if err := m.store.AddToSet(ctx, userSessionsKey(refreshed.UserID), refreshed.ID, time.Until(refreshed.ExpiresAt)); err != nil {
+		return nil, false, fmt.Errorf("failed to index session: %w", err)
+	}
+
+	return &refreshed, true, nil
+}
+
+// rotate moves a session to a new ID. Only one concurrent request gets to
+// rotate a session; the others carry on with the old ID.
+func (m *manager) rotate(ctx context.Context, old, refreshed *Session) (*Session, bool, error) {
+	claimed, err := m.store.Incr(ctx, fmt.Sprintf("session_rotation:%s", old.ID), rotationGrace)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to claim session rotation: %w", err)
+	}
+	if claimed != 1 {
+		return old, false, nil
+	}
+
+	// Revoked sessions must not come back under a new ID: the new session is
+	// only stored, and indexed, while the old one exists. RevokeAll deletes
+	// the index and its sessions in one step, so either it sees the new
+	// session or the new session is never stored.
+	refreshed.ID = uuid.New().String()
+	refreshed.RotatedAt = refreshed.LastSeenAt
+	if err := m.save(ctx, refreshed, sessionKey(old.ID)); err != nil {
+		return nil, false, err
+	}
+
+	// The old ID stays valid briefly. It remains indexed so RevokeAll still
+	// reaches it, but is no longer listed.
+	retired := *old
+	retired.ReplacedBy = refreshed.ID
+	if grace := time.Now().Add(rotationGrace); grace.Before(retired.ExpiresAt) {
+		retired.ExpiresAt = grace
+	}
+	if err := m.update(ctx, &retired); err != nil && !errors.Is(err, ErrSessionNotFound) {
+		return nil, false, err
+	}
+
+	return refreshed, true, nil
+}
+
+// CSRFToken returns the CSRF token of a session. Sessions created before
+// CSRF protection get a token on first use.
+func (m *manager) CSRFToken(ctx context.Context, session *Session) (string, error) {
+	if session.CSRFToken != "" {
+		return session.CSRFToken, nil
+	}
+
+	updated := *session
+	updated.CSRFToken = newCSRFToken()
+	if err := m.update(ctx, &updated); err != nil {
+		return "", err
+	}
+
+	session.CSRFToken = updated.CSRFToken
+	return updated.CSRFToken, nil
+}
+
+// absoluteExpiry returns when a session ends at the latest. Sessions created
+// before the cap existed are capped relative to their creation.
+func (m *manager) absoluteExpiry(session *Session) time.Time {
+	if session.AbsoluteExpiresAt.IsZero() {
+		return session.CreatedAt.Add(m.config.AbsoluteTimeout)
+	}
+	return session.AbsoluteExpiresAt
+}
+
+// List returns the active sessions of a user. Index entries of sessions that
+// expired or were deleted are pruned on the way.
+func (m *manager) List(ctx context.Context, userID string) ([]*Session, error) {
+	ids, err := m.store.SetMembers(ctx, userSessionsKey(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	sessions := make([]*Session, 0, len(ids))
+	var stale []string
+	for _, id := range ids {
+		session, err := m.Get(ctx, id)
+		if err != nil || session.UserID != userID {
+			stale = append(stale, id)
+			continue
+		}
+		if session.ReplacedBy != "" {
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+
+	if err := m.store.RemoveFromSet(ctx, userSessionsKey(userID), stale...); err != nil {
+		return nil, fmt.Errorf("failed to prune sessions: %w", err)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.After(sessions[j].CreatedAt)
+	})
+
+	return sessions, nil
+}
+
+// RevokeAll deletes every session of a user along with the index
+func (m *manager) RevokeAll(ctx context.Context, userID string) (int, error) {
+	revoked, err := m.store.DeleteIndexed(ctx, userSessionsKey(userID), sessionKey(""))
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	return revoked, nil
+}
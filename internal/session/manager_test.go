@@ -0,0 +1,326 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// memoryStore is an in-memory Store; TTLs are ignored
+type memoryStore struct {
+	values map[string]string
+	sets   map[string]map[string]bool
+
+	// before, when set, is called at the start of every operation
+	before func()
+}
+
+func (s *memoryStore) call() {
+	if s.before != nil {
+		s.before()
+	}
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{values: map[string]string{}, sets: map[string]map[string]bool{}}
+}
+
+func (s *memoryStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	s.call()
+	s.values[key] = value
+	return nil
+}
+
+func (s *memoryStore) SetIfExists(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	s.call()
+	if _, ok := s.values[key]; !ok {
+		return false, nil
+	}
+	s.values[key] = value
+	return true, nil
+}
+
+func (s *memoryStore) Get(ctx context.Context, key string) (string, error) {
+	s.call()
+	value, ok := s.values[key]
+	if !ok {
+		return "", errors.New("not found")
+	}
+	return value, nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, keys ...string) error {
+	s.call()
+	for _, key := range keys {
+		delete(s.values, key)
+		delete(s.sets, key)
+	}
+	return nil
+}
+
+func (s *memoryStore) Exists(ctx context.Context, key string) (bool, error) {
+	s.call()
+	_, ok := s.values[key]
+	return ok, nil
+}
+
+func (s *memoryStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	s.call()
+	n, _ := strconv.ParseInt(s.values[key], 10, 64)
+	n++
+	s.values[key] = strconv.FormatInt(n, 10)
+	return n, nil
+}
+
+func (s *memoryStore) AddToSet(ctx context.Context, key, member string, ttl time.Duration) error {
+	s.call()
+	if s.sets[key] == nil {
+		s.sets[key] = map[string]bool{}
+	}
+	s.sets[key][member] = true
+	return nil
+}
+
+func (s *memoryStore) RemoveFromSet(ctx context.Context, key string, members ...string) error {
+	s.call()
+	for _, member := range members {
+		delete(s.sets[key], member)
+	}
+	return nil
+}
+
+func (s *memoryStore) SetMembers(ctx context.Context, key string) ([]string, error) {
+	s.call()
+	members := make([]string, 0, len(s.sets[key]))
+	for member := range s.sets[key] {
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+func (s *memoryStore) SetIndexed(ctx context.Context, key, value string, ttl time.Duration, setKey, member, guard string) (bool, error) {
+	s.call()
+	if _, ok := s.values[guard]; guard != "" && !ok {
+		return false, nil
+	}
+	s.values[key] = value
+	if s.sets[setKey] == nil {
+		s.sets[setKey] = map[string]bool{}
+	}
+	s.sets[setKey][member] = true
+	return true, nil
+}
+
+func (s *memoryStore) DeleteIndexed(ctx context.Context, setKey, keyPrefix string) (int, error) {
+	s.call()
+	members := s.sets[setKey]
+	for member := range members {
+		delete(s.values, keyPrefix+member)
+	}
+	delete(s.sets, setKey)
+	return len(members), nil
+}
+
+func TestManagerListAndRevoke(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	m := NewManager(store, DefaultConfig())
+
+	client := ClientInfo{UserAgent: "test-agent", IP: "10.0.0.1"}
+	first, _ := m.Create(ctx, "alice", "alice@example.com", client)
+	second, _ := m.Create(ctx, "alice", "alice@example.com", client)
+	other, _ := m.Create(ctx, "bob", "bob@example.com", client)
+
+	sessions, err := m.List(ctx, "alice")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("Expected 2 sessions for alice, got %d", len(sessions))
+	}
+	if sessions[0].UserAgent != "test-agent" || sessions[0].IP != "10.0.0.1" {
+		t.Errorf("Expected client info to be recorded, got %+v", sessions[0])
+	}
+
+	// Deleting one session drops it from the index
+	if err := m.Delete(ctx, first.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if sessions, _ := m.List(ctx, "alice"); len(sessions) != 1 || sessions[0].ID != second.ID {
+		t.Errorf("Expected only the second session to remain, got %+v", sessions)
+	}
+
+	revoked, err := m.RevokeAll(ctx, "alice")
+	if err != nil {
+		t.Fatalf("RevokeAll failed: %v", err)
+	}
+	if revoked != 1 {
+		t.Errorf("Expected 1 revoked session, got %d", revoked)
+	}
+	if _, err := m.Get(ctx, second.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected revoked session to be gone, got %v", err)
+	}
+	if _, err := m.Get(ctx, other.ID); err != nil {
+		t.Errorf("Expected other users' sessions to survive, got %v", err)
+	}
+}
+
+func TestManagerRefresh(t *testing.T) {
+	ctx := context.Background()
+	cfg := Config{
+		IdleTimeout:      time.Hour,
+		AbsoluteTimeout:  90 * time.Minute,
+		RefreshInterval:  time.Minute,
+		RotationInterval: time.Hour,
+	}
+	m := NewManager(newMemoryStore(), cfg)
+
+	sess, _ := m.Create(ctx, "alice", "alice@example.com", ClientInfo{})
+
+	// Recent activity is not written again
+	if _, changed, err := m.Refresh(ctx, sess); err != nil || changed {
+		t.Fatalf("Expected a throttled refresh, got changed=%v err=%v", changed, err)
+	}
+
+	// Pretend the session has been in use for an hour
+	aged := *sess
+	aged.LastSeenAt = time.Now().Add(-2 * time.Minute)
+	aged.ExpiresAt = time.Now().Add(30 * time.Minute)
+	aged.AbsoluteExpiresAt = time.Now().Add(30 * time.Minute)
+	aged.RotatedAt = time.Now().Add(-2 * time.Hour)
+
+	refreshed, changed, err := m.Refresh(ctx, &aged)
+	if err != nil || !changed {
+		t.Fatalf("Expected the session to be refreshed, got changed=%v err=%v", changed, err)
+	}
+	if refreshed.ID == sess.ID {
+		t.Error("Expected the session ID to be rotated")
+	}
+	if refreshed.CSRFToken == "" || refreshed.CSRFToken != sess.CSRFToken {
+		t.Error("Expected the CSRF token to survive rotation")
+	}
+	if refreshed.ExpiresAt.After(aged.AbsoluteExpiresAt) {
+		t.Errorf("Expected expiry to be capped at %v, got %v", aged.AbsoluteExpiresAt, refreshed.ExpiresAt)
+	}
+
+	// The old ID works during the grace period but is no longer listed
+	old, err := m.Get(ctx, sess.ID)
+	if err != nil || old.ReplacedBy != refreshed.ID {
+		t.Fatalf("Expected the old ID to point at the new one, got %+v, %v", old, err)
+	}
+	if sessions, _ := m.List(ctx, "alice"); len(sessions) != 1 || sessions[0].ID != refreshed.ID {
+		t.Errorf("Expected only the rotated session to be listed, got %+v", sessions)
+	}
+
+	// Logging out with the old cookie ends the rotated session too
+	if err := m.Delete(ctx, sess.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := m.Get(ctx, refreshed.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected the rotated session to be deleted, got %v", err)
+	}
+}
+
+func TestManagerRefreshDoesNotResurrect(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(newMemoryStore(), DefaultConfig())
+
+	sess, _ := m.Create(ctx, "alice", "alice@example.com", ClientInfo{})
+	if err := m.Delete(ctx, sess.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	stale := *sess
+	stale.LastSeenAt = time.Now().Add(-2 * time.Minute)
+	if _, _, err := m.Refresh(ctx, &stale); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected refreshing a revoked session to fail, got %v", err)
+	}
+
+	stale.RotatedAt = time.Now().Add(-time.Hour)
+	if _, _, err := m.Refresh(ctx, &stale); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected rotating a revoked session to fail, got %v", err)
+	}
+	if sessions, _ := m.List(ctx, "alice"); len(sessions) != 0 {
+		t.Errorf("Expected no sessions, got %+v", sessions)
+	}
+}
+
+func TestManagerRotateRacingRevokeAll(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.RefreshInterval = time.Minute
+	cfg.RotationInterval = time.Hour
+
+	// Revoke every session at each step of a rotation in turn
+	for step := 0; ; step++ {
+		store := newMemoryStore()
+		m := NewManager(store, cfg)
+		sess, _ := m.Create(ctx, "alice", "alice@example.com", ClientInfo{})
+
+		aged := *sess
+		aged.LastSeenAt = time.Now().Add(-2 * time.Minute)
+		aged.RotatedAt = time.Now().Add(-2 * time.Hour)
+
+		calls, revoked := 0, false
+		store.before = func() {
+			if calls == step {
+				store.before = nil
+				revoked = true
+				if _, err := m.RevokeAll(ctx, "alice"); err != nil {
+					t.Fatalf("RevokeAll failed: %v", err)
+				}
+			}
+			calls++
+		}
+		rotated, _, err := m.Refresh(ctx, &aged)
+		store.before = nil
+		if !revoked {
+			break // the rotation has fewer steps
+		}
+
+		if err == nil {
+			if _, err := m.Get(ctx, rotated.ID); err == nil {
+				t.Errorf("step %d: expected the rotated session to be revoked", step)
+			}
+		} else if !errors.Is(err, ErrSessionNotFound) {
+			t.Errorf("step %d: expected ErrSessionNotFound, got %v", step, err)
+		}
+		if sessions, _ := m.List(ctx, "alice"); len(sessions) != 0 {
+			t.Errorf("step %d: expected no sessions, got %+v", step, sessions)
+		}
+	}
+}
+
+func TestManagerCSRFToken(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(newMemoryStore(), DefaultConfig())
+
+	sess, _ := m.Create(ctx, "alice", "alice@example.com", ClientInfo{})
+	if !sess.CheckCSRFToken(sess.CSRFToken) || sess.CheckCSRFToken("") || sess.CheckCSRFToken("other") {
+		t.Fatal("Expected only the session's own CSRF token to be accepted")
+	}
+
+	// Sessions from before CSRF tokens get one on first use
+	legacy := *sess
+	legacy.CSRFToken = ""
+	if err := m.(*manager).update(ctx, &legacy); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+
+	token, err := m.CSRFToken(ctx, &legacy)
+	if err != nil || token == "" {
+		t.Fatalf("Expected a token to be assigned, got %q, %v", token, err)
+	}
+	if stored, _ := m.Get(ctx, sess.ID); stored.CSRFToken != token {
+		t.Errorf("Expected the token to be stored, got %q", stored.CSRFToken)
+	}
+}
+
+func TestPublicIDHidesSessionID(t *testing.T) {
+	id := "5f1c0d9e-7b1d-4c55-9a0e-2f6d8c1b7e40"
+	if PublicID(id) == id || PublicID(id) != PublicID(id) {
+		t.Error("Expected a stable public ID different from the session ID")
+	}
+}
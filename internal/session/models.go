@@ -4,9 +4,29 @@ import "time"
 
 // Session represents a user session
 type Session struct {
-	ID        string    `json:"id"`
-	UserID    string    `json:"user_id"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"created_at"`
-	ExpiresAt time.Time `json:"expires_at"`
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	Email      string    `json:"email"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// AbsoluteExpiresAt is the hard end of the session; ExpiresAt slides
+	// forward with activity but never past it
+	AbsoluteExpiresAt time.Time `json:"absolute_expires_at"`
+	// RotatedAt is when the session got its current ID
+	RotatedAt time.Time `json:"rotated_at"`
+	// ReplacedBy is set on a session whose ID has been rotated. It stays
+	// valid for a short grace period for requests already in flight.
+	ReplacedBy string `json:"replaced_by,omitempty"`
+	// CSRFToken must accompany state-changing requests made with the
+	// session cookie. It is kept when the session ID rotates.
+	CSRFToken string `json:"csrf_token,omitempty"`
+}
+
+// ClientInfo describes the client a session is created for
+type ClientInfo struct {
+	UserAgent string
+	IP        string
 }
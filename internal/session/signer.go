@@ -0,0 +1,118 @@
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+
+	"instant/internal/config"
+)
+
+// ErrInvalidCookie is returned for session cookies that are malformed, not
+// signed, or signed with an unknown or wrong key
+var ErrInvalidCookie = errors.New("invalid session cookie")
+
+// CookieSigner signs session IDs for the session cookie with SESSION_SECRET.
+// Each signature names the key it was made with, so after the secret is
+// rotated cookies signed with a previous secret stay valid until they expire.
+type CookieSigner struct {
+	current signingKey
+	keys    map[string]signingKey
+}
+
+// signingKey is a secret and the key ID derived from it
+type signingKey struct {
+	id     string
+	secret []byte
+}
+
+// newSigningKey derives a short key ID from the secret, so no separate
+// identifier has to be configured and kept in sync
+func newSigningKey(secret string) signingKey {
+	sum := sha256.Sum256([]byte("session-key-id:" + secret))
+	return signingKey{id: hex.EncodeToString(sum[:4]), secret: []byte(secret)}
+}
+
+// NewCookieSigner signs with current and also accepts cookies signed with
+// any of the previous secrets
+func NewCookieSigner(current string, previous ...string) *CookieSigner {
+	s := &CookieSigner{
+		current: newSigningKey(current),
+		keys:    make(map[string]signingKey, len(previous)+1),
+	}
+	for _, secret := range previous {
+		key := newSigningKey(secret)
+		s.keys[key.id] = key
+	}
+	s.keys[s.current.id] = s.current
+	return s
+}
+
+// LoadCookieSigner creates a signer from SESSION_SECRET and
+// SESSION_SECRET_PREVIOUS, a comma-separated list of secrets still accepted
+// during rotation
+func LoadCookieSigner() (*CookieSigner, error) {
+	if err := config.ValidateSessionSecret(); err != nil {
+		return nil, err
+	}
+
+	var previous []string
+	for _, secret := range strings.Split(os.Getenv("SESSION_SECRET_PREVIOUS"), ",") {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			previous = append(previous, secret)
+		}
+	}
+
+	return NewCookieSigner(os.Getenv("SESSION_SECRET"), previous...), nil
+}
+
+// Sign returns the cookie value for a session ID: "<id>.<key id>.<signature>"
+func (s *CookieSigner) Sign(sessionID string) string {
+	return sessionID + "." + s.current.id + "." + s.current.sign(sessionID)
+}
+
+// Verify checks a cookie value and returns the session ID it carries
+func (s *CookieSigner) Verify(value string) (string, error) {
+	parts := strings.Split(value, ".")
+	if len(parts) != 3 || parts[0] == "" {
+		return "", ErrInvalidCookie
+	}
+	sessionID, keyID, sig := parts[0], parts[1], parts[2]
+
+	key, ok := s.keys[keyID]
+	if !ok {
+		return "", ErrInvalidCookie
+	}
+
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+	expected, _ := base64.RawURLEncoding.DecodeString(key.sign(sessionID))
+	if !hmac.Equal(got, expected) {
+		return "", ErrInvalidCookie
+	}
+
+	return sessionID, nil
+}
+
+// SessionID returns the verified session ID from the request's session cookie
+func (s *CookieSigner) SessionID(r *http.Request) (string, error) {
+	cookie, err := r.Cookie(CookieName)
+	if err != nil {
+		return "", err
+	}
+	return s.Verify(cookie.Value)
+}
+
+// sign computes the base64url HMAC over the key ID and session ID
+func (k signingKey) sign(sessionID string) string {
+	mac := hmac.New(sha256.New, k.secret)
+	mac.Write([]byte(k.id + "." + sessionID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
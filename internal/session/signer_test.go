@@ -0,0 +1,65 @@
+package session
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const (
+	testSecret     = "current-session-secret-with-32-characters"
+	previousSecret = "previous-session-secret-with-32-characters"
+)
+
+func TestCookieSignerRoundTrip(t *testing.T) {
+	s := NewCookieSigner(testSecret)
+
+	id, err := s.Verify(s.Sign("5f1c0d9e-7b1d-4c55-9a0e-2f6d8c1b7e40"))
+	if err != nil || id != "5f1c0d9e-7b1d-4c55-9a0e-2f6d8c1b7e40" {
+		t.Fatalf("Expected the session ID back, got %q, %v", id, err)
+	}
+}
+
+func TestCookieSignerRejectsBadCookies(t *testing.T) {
+	s := NewCookieSigner(testSecret)
+	signed := s.Sign("session-a")
+	_, keyID, sig := splitCookie(t, signed)
+
+	for name, value := range map[string]string{
+		"unsigned":     "session-a",
+		"empty":        "",
+		"swapped id":   "session-b." + keyID + "." + sig,
+		"bad sig":      "session-a." + keyID + "." + strings.Repeat("A", len(sig)),
+		"unknown key":  "session-a.deadbeef." + sig,
+		"extra part":   signed + ".x",
+		"other secret": NewCookieSigner(previousSecret).Sign("session-a"),
+	} {
+		if _, err := s.Verify(value); !errors.Is(err, ErrInvalidCookie) {
+			t.Errorf("%s: expected ErrInvalidCookie, got %v", name, err)
+		}
+	}
+}
+
+func TestCookieSignerRotation(t *testing.T) {
+	old := NewCookieSigner(previousSecret)
+	rotated := NewCookieSigner(testSecret, previousSecret)
+
+	// Cookies signed before the rotation are still accepted
+	if id, err := rotated.Verify(old.Sign("session-a")); err != nil || id != "session-a" {
+		t.Errorf("Expected a cookie signed with the previous secret to verify, got %q, %v", id, err)
+	}
+
+	// New cookies are signed with the current secret only
+	if _, err := old.Verify(rotated.Sign("session-a")); err == nil {
+		t.Error("Expected new cookies to be signed with the current secret")
+	}
+}
+
+func splitCookie(t *testing.T, value string) (string, string, string) {
+	t.Helper()
+	parts := strings.Split(value, ".")
+	if len(parts) != 3 {
+		t.Fatalf("Expected id.key.sig, got %q", value)
+	}
+	return parts[0], parts[1], parts[2]
+}
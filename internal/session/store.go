@@ -4,6 +4,8 @@ import (
 	"context"
 	"time"
 
+	"instant/internal/tracing"
+
 	"github.com/redis/go-redis/v9"
 )
 
@@ -11,10 +13,55 @@ import (
 type Store interface {
 	Set(ctx context.Context, key string, value string, ttl time.Duration) error
 	Get(ctx context.Context, key string) (string, error)
-	Delete(ctx context.Context, key string) error
+	// SetIfExists replaces the value of an existing key and reports whether
+	// it existed. Deleted keys are never recreated.
+	SetIfExists(ctx context.Context, key string, value string, ttl time.Duration) (bool, error)
+	Delete(ctx context.Context, keys ...string) error
 	Exists(ctx context.Context, key string) (bool, error)
+	// Incr atomically increments a counter. The TTL is set when the counter
+	// is created and is not extended by later increments.
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	// AddToSet adds member to the set at key and extends the set's TTL to at
+	// least ttl
+	AddToSet(ctx context.Context, key, member string, ttl time.Duration) error
+	RemoveFromSet(ctx context.Context, key string, members ...string) error
+	SetMembers(ctx context.Context, key string) ([]string, error)
+	// SetIndexed stores value at key and adds member to the set at setKey as
+	// AddToSet does, in one atomic step. With a guard, nothing is written
+	// unless the guard key exists. It reports whether the value was stored.
+	SetIndexed(ctx context.Context, key, value string, ttl time.Duration, setKey, member, guard string) (bool, error)
+	// DeleteIndexed deletes the set at setKey and the key keyPrefix+member of
+	// every member in one atomic step, returning how many members there were
+	DeleteIndexed(ctx context.Context, setKey, keyPrefix string) (int, error)
 }
 
+// setIndexedScript backs SetIndexed. The set's TTL is only ever extended,
+// like AddToSet's.
+var setIndexedScript = redis.NewScript(`
+if KEYS[3] and redis.call('EXISTS', KEYS[3]) == 0 then
+	return 0
+end
+local ttl = tonumber(ARGV[2])
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ttl)
+redis.call('SADD', KEYS[2], ARGV[3])
+local current = redis.call('PTTL', KEYS[2])
+if current == -1 or current < ttl then
+	redis.call('PEXPIRE', KEYS[2], ttl)
+end
+return 1
+`)
+
+// deleteIndexedScript backs DeleteIndexed. The member keys are derived inside
+// the script, so it needs every key on one node (no Redis Cluster).
+var deleteIndexedScript = redis.NewScript(`
+local members = redis.call('SMEMBERS', KEYS[1])
+for _, member in ipairs(members) do
+	redis.call('DEL', ARGV[1] .. member)
+end
+redis.call('DEL', KEYS[1])
+return #members
+`)
+
 // redisStore implements Store interface using Redis
 type redisStore struct {
 	client *redis.Client
@@ -27,6 +74,7 @@ func NewRedisStore(addr, password string, db int) Store {
 		Password: password,
 		DB:       db,
 	})
+	tracing.InstrumentRedis(client)
 
 	return &redisStore{
 		client: client,
@@ -43,9 +91,17 @@ func (s *redisStore) Get(ctx context.Context, key string) (string, error) {
 	return s.client.Get(ctx, key).Result()
 }
 
-// Delete removes a key from the store
-func (s *redisStore) Delete(ctx context.Context, key string) error {
-	return s.client.Del(ctx, key).Err()
+// SetIfExists replaces the value of an existing key
+func (s *redisStore) SetIfExists(ctx context.Context, key string, value string, ttl time.Duration) (bool, error) {
+	return s.client.SetXX(ctx, key, value, ttl).Result()
+}
+
+// Delete removes keys from the store
+func (s *redisStore) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return s.client.Del(ctx, keys...).Err()
 }
 
 // Exists checks if a key exists in the store
@@ -53,3 +109,61 @@ func (s *redisStore) Exists(ctx context.Context, key string) (bool, error) {
 	count, err := s.client.Exists(ctx, key).Result()
 	return count > 0, err
 }
+
+// Incr atomically increments a counter, starting its TTL on first use
+func (s *redisStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	pipe := s.client.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.ExpireNX(ctx, key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+// AddToSet adds member to a set, creating or extending its TTL as needed
+func (s *redisStore) AddToSet(ctx context.Context, key, member string, ttl time.Duration) error {
+	pipe := s.client.TxPipeline()
+	pipe.SAdd(ctx, key, member)
+	pipe.ExpireNX(ctx, key, ttl)
+	pipe.ExpireGT(ctx, key, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// RemoveFromSet removes members from a set
+func (s *redisStore) RemoveFromSet(ctx context.Context, key string, members ...string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	args := make([]interface{}, len(members))
+	for i, member := range members {
+		args[i] = member
+	}
+	return s.client.SRem(ctx, key, args...).Err()
+}
+
+// SetMembers returns all members of a set
+func (s *redisStore) SetMembers(ctx context.Context, key string) ([]string, error) {
+	return s.client.SMembers(ctx, key).Result()
+}
+
+// SetIndexed stores a value and indexes it in a set atomically, optionally
+// only while a guard key exists
+func (s *redisStore) SetIndexed(ctx context.Context, key, value string, ttl time.Duration, setKey, member, guard string) (bool, error) {
+	keys := []string{key, setKey}
+	if guard != "" {
+		keys = append(keys, guard)
+	}
+	ms := ttl.Milliseconds()
+	if ms < 1 {
+		ms = 1
+	}
+	stored, err := setIndexedScript.Run(ctx, s.client, keys, value, ms, member).Int()
+	return stored == 1, err
+}
+
+// DeleteIndexed deletes a set and the keys of its members atomically
+func (s *redisStore) DeleteIndexed(ctx context.Context, setKey, keyPrefix string) (int, error) {
+	return deleteIndexedScript.Run(ctx, s.client, []string{setKey}, keyPrefix).Int()
+}
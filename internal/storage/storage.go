@@ -6,10 +6,12 @@ package storage
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"time"
 
+	"instant/internal/logger"
+
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
@@ -69,9 +71,9 @@ func New(ctx context.Context) (Service, error) {
 	// Use internal endpoint for presigned URLs if public endpoint not specified
 	if publicEndpoint == "" {
 		publicEndpoint = endpoint
-		log.Printf("[Storage] Using internal endpoint for presigned URLs: %s", endpoint)
+		slog.Info("Using internal endpoint for presigned URLs", "endpoint", endpoint)
 	} else {
-		log.Printf("[Storage] Using public endpoint for presigned URLs: %s", publicEndpoint)
+		slog.Info("Using public endpoint for presigned URLs", "endpoint", publicEndpoint)
 	}
 
 	protocol := "http"
@@ -156,7 +158,7 @@ func New(ctx context.Context) (Service, error) {
 
 	// Ensure bucket exists on initialization
 	if err := s.EnsureBucketExists(ctx); err != nil {
-		log.Printf("Warning: failed to ensure bucket exists: %v", err)
+		logger.FromContext(ctx).Warn("Failed to ensure bucket exists", "bucket", bucketName, "error", err)
 	}
 
 	return s, nil
@@ -181,7 +183,7 @@ func (s *service) EnsureBucketExists(ctx context.Context) error {
 		return fmt.Errorf("failed to create bucket: %w", err)
 	}
 
-	log.Printf("Created S3 bucket: %s", s.bucketName)
+	logger.FromContext(ctx).Info("Created S3 bucket", "bucket", s.bucketName)
 	return nil
 }
 
@@ -0,0 +1,57 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestMiddlewareContinuesIncomingTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+
+	outgoing := http.Header{}
+	r.GET("/posts/:id", func(c *gin.Context) {
+		Inject(c.Request.Context(), outgoing)
+		c.Status(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/posts/7", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "GET /posts/:id" {
+		t.Errorf("Expected span named after the route, got %q", span.Name())
+	}
+	if span.SpanContext().TraceID().String() != traceID {
+		t.Errorf("Expected trace %s to continue, got %s", traceID, span.SpanContext().TraceID())
+	}
+	if span.Status().Code.String() != "Error" {
+		t.Errorf("Expected 5xx to mark the span as failed, got %s", span.Status().Code)
+	}
+
+	// Upstream calls continue the trace with the server span as parent
+	traceparent := outgoing.Get("traceparent")
+	if !strings.Contains(traceparent, traceID) || !strings.Contains(traceparent, span.SpanContext().SpanID().String()) {
+		t.Errorf("Expected outgoing traceparent with the server span, got %q", traceparent)
+	}
+}
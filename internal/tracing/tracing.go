@@ -0,0 +1,122 @@
+// Package tracing sets up OpenTelemetry tracing for the services.
+// Trace context is propagated with the W3C traceparent header over HTTP and
+// in Kafka message headers, so one request can be followed from the gateway
+// through the services and Kafka consumers.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+)
+
+// Exporters
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// Config configures the tracer provider
+type Config struct {
+	// ServiceName identifies the service in traces
+	ServiceName string
+	// Exporter is where spans are sent: otlp, stdout or none
+	Exporter string
+	// SampleRatio is the fraction of new traces that are recorded. Requests
+	// that arrive with a sampled parent are always recorded.
+	SampleRatio float64
+}
+
+// ConfigFromEnv builds a Config from OTEL_SERVICE_NAME (defaults to
+// serviceName), OTEL_TRACES_EXPORTER (default none) and
+// OTEL_TRACES_SAMPLER_ARG (default 1). The OTLP exporter itself is
+// configured with the standard OTEL_EXPORTER_OTLP_* variables.
+func ConfigFromEnv(serviceName string) (Config, error) {
+	cfg := Config{
+		ServiceName: serviceName,
+		Exporter:    ExporterNone,
+		SampleRatio: 1,
+	}
+
+	if v := os.Getenv("OTEL_SERVICE_NAME"); v != "" {
+		cfg.ServiceName = v
+	}
+
+	if v := os.Getenv("OTEL_TRACES_EXPORTER"); v != "" {
+		switch v {
+		case ExporterNone, ExporterOTLP, ExporterStdout:
+			cfg.Exporter = v
+		default:
+			return cfg, fmt.Errorf("OTEL_TRACES_EXPORTER: unknown exporter %q", v)
+		}
+	}
+
+	if v := os.Getenv("OTEL_TRACES_SAMPLER_ARG"); v != "" {
+		ratio, err := strconv.ParseFloat(v, 64)
+		if err != nil || ratio < 0 || ratio > 1 {
+			return cfg, fmt.Errorf("OTEL_TRACES_SAMPLER_ARG: invalid ratio %q", v)
+		}
+		cfg.SampleRatio = ratio
+	}
+
+	return cfg, nil
+}
+
+// Init installs the global tracer provider and W3C trace context propagator.
+// The returned function flushes pending spans and must be called on shutdown.
+// With the none exporter no spans are recorded, but incoming trace context is
+// still passed on to upstream services and Kafka messages.
+func Init(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case ExporterOTLP:
+		exporter, err = otlptracehttp.New(ctx)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return func(context.Context) error { return nil }, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s exporter: %w", cfg.Exporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(),
+		resource.NewSchemaless(semconv.ServiceName(cfg.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Setup loads the config from the environment and calls Init
+func Setup(ctx context.Context, serviceName string) (func(context.Context) error, error) {
+	cfg, err := ConfigFromEnv(serviceName)
+	if err != nil {
+		return nil, err
+	}
+	return Init(ctx, cfg)
+}
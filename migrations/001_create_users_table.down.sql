@@ -0,0 +1,3 @@
+-- Drop users table (CASCADE also drops foreign keys from posts, likes, comments and follows)
+DROP INDEX IF EXISTS idx_users_email;
+DROP TABLE IF EXISTS users CASCADE;
@@ -1,9 +1,7 @@
 -- Add username column to users table
--- Migration: 002_add_username_column_up.sql
+-- Migration: 002_add_username_column.up.sql
 -- Description: Adds unique username field to support user profiles
 
-BEGIN;
-
 -- Add username column (nullable initially for backward compatibility)
 ALTER TABLE users ADD COLUMN IF NOT EXISTS username VARCHAR(50);
 
@@ -15,5 +13,3 @@ CREATE INDEX IF NOT EXISTS idx_users_username ON users(username) WHERE username
 
 -- Add documentation
 COMMENT ON COLUMN users.username IS 'Unique username (3-50 characters, alphanumeric + underscore/dash). Optional field.';
-
-COMMIT;
@@ -1,9 +1,7 @@
 -- Make username column NOT NULL
--- Migration: 003_make_username_not_null_up.sql
+-- Migration: 003_make_username_not_null.up.sql
 -- Description: Makes username field required for all users
 
-BEGIN;
-
 -- First, update any existing users with NULL username to have a default username
 -- This generates a username based on email prefix + random suffix
 UPDATE users 
@@ -19,6 +17,3 @@ ALTER TABLE users ALTER COLUMN username SET NOT NULL;
 
 -- Add documentation
 COMMENT ON COLUMN users.username IS 'Required unique username (3-50 characters, alphanumeric + underscore/dash)';
-
-COMMIT;
-
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_likes_created;
+DROP INDEX IF EXISTS idx_likes_user_id;
+DROP INDEX IF EXISTS idx_likes_post_id;
+
+DROP TABLE IF EXISTS likes;
@@ -0,0 +1,4 @@
+-- Nothing to undo: 006 recreates likes with the same schema as 005,
+-- so rolling back leaves the table as 005 created it
+COMMENT ON TABLE likes IS NULL;
+COMMENT ON COLUMN likes.post_id IS NULL;
@@ -0,0 +1,7 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_follow_follower;
+DROP INDEX IF EXISTS idx_follow_followee;
+DROP INDEX IF EXISTS idx_follow_created;
+
+-- Drop table
+DROP TABLE IF EXISTS follow CASCADE;
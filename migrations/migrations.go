@@ -0,0 +1,11 @@
+// Package migrations embeds the SQL schema migrations so they ship inside
+// the service binaries. Files are named <version>_<name>.up.sql and
+// <version>_<name>.down.sql and are applied by internal/migrate.
+package migrations
+
+import "embed"
+
+// FS holds every migration file in this directory
+//
+//go:embed *.sql
+var FS embed.FS